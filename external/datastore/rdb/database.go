@@ -1,6 +1,7 @@
 package rdb
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
 	"sync"
@@ -23,7 +24,11 @@ type Database interface {
 	Queryx(query string, args ...any) (*sqlx.Rows, error)
 	QueryRowx(query string, args ...any) *sqlx.Row
 	NamedQuery(query string, arg any) (*sqlx.Rows, error)
+	NamedExec(query string, arg any) (sql.Result, error)
 	PrepareNamedGet(result any, query string, arg any) error
+	// Transaction runs fn with a Database whose queries are in one transaction,
+	// it is committed when fn returns nil and rolled back otherwise
+	Transaction(fn func(tx Database) error) error
 }
 
 func DatabaseInstance() (*singletonDatabase, error) {
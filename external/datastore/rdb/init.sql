@@ -37,16 +37,41 @@ COMMENT ON EXTENSION "uuid-ossp" IS 'generate universally unique identifiers (UU
 CREATE TABLE IF NOT EXISTS public.user(
   id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
   email character varying(255) NOT NULL,
-  origin_name character varying(50) DEFAULT NULL,
+  email_verified_at timestamp with time zone DEFAULT NULL,
+  origin_name character varying(255) DEFAULT NULL,
   username character varying(50) NOT NULL,
   photo_url character varying(255) DEFAULT NULL,
+  sync_origin_name boolean NOT NULL DEFAULT true,
+  sync_photo_url boolean NOT NULL DEFAULT true,
+  profile_synced_at timestamp with time zone DEFAULT NULL,
   created_at timestamp with time zone DEFAULT now() NOT NULL,
   updated_at timestamp with time zone DEFAULT now() NOT NULL,
   PRIMARY KEY (id)
 );
 
-CREATE UNIQUE INDEX IF NOT EXISTS user_ix_email ON public.user USING btree (email);
-CREATE UNIQUE INDEX IF NOT EXISTS user_ix_username ON public.user USING btree (username);
+-- profile fields refreshed from the identity provider on sign-in, unless the user customised them
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS sync_origin_name boolean NOT NULL DEFAULT true;
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS sync_photo_url boolean NOT NULL DEFAULT true;
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS profile_synced_at timestamp with time zone DEFAULT NULL;
+
+-- emails are verified by the provider on sign-up, the existing users are verified when the column is added
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS email_verified_at timestamp with time zone DEFAULT now();
+ALTER TABLE public.user ALTER COLUMN email_verified_at SET DEFAULT NULL;
+
+-- the full display name of the provider is kept
+ALTER TABLE public.user ALTER COLUMN origin_name TYPE character varying(255);
+
+-- a suspension ends at suspended_until, a ban does not end
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS status character varying(16) NOT NULL DEFAULT 'active';
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS status_reason character varying(255) DEFAULT NULL;
+ALTER TABLE public.user ADD COLUMN IF NOT EXISTS suspended_until timestamp with time zone DEFAULT NULL;
+
+-- emails are unique regardless of case, they are looked up with lower(email)
+DROP INDEX IF EXISTS user_ix_email;
+CREATE UNIQUE INDEX IF NOT EXISTS user_ix_lower_email ON public.user USING btree (lower(email));
+-- usernames are unique regardless of case
+DROP INDEX IF EXISTS user_ix_username;
+CREATE UNIQUE INDEX IF NOT EXISTS user_ix_lower_username ON public.user USING btree (lower(username));
 
 -- ALTER TABLE public.user OWNER TO madre;
 
@@ -58,17 +83,280 @@ CREATE TABLE IF NOT EXISTS public.social_account (
   id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
   user_id uuid NOT NULL,
   social_id character varying(255) NOT NULL,
-  provider character varying(10) NOT NULL DEFAULT 'GOOGLE',
+  provider character varying(50) NOT NULL DEFAULT 'GOOGLE',
   created_at timestamp with time zone DEFAULT now() NOT NULL,
   updated_at timestamp with time zone DEFAULT now() NOT NULL,
   PRIMARY KEY (id)
 );
 
+-- oidc provider names are configured by OIDC_PROVIDERS, so GOOGLE is no longer the longest one
+ALTER TABLE public.social_account ALTER COLUMN provider TYPE character varying(50);
+
 CREATE UNIQUE INDEX IF NOT EXISTS social_account_ix_user_id ON public.social_account USING btree (user_id);
 CREATE UNIQUE INDEX IF NOT EXISTS social_account_ix_social_id_provider ON public.social_account USING btree (social_id, provider);
 
 -- ALTER TABLE public.social_account OWNER TO madre;
 
+--
+-- credential
+--
+
+CREATE TABLE IF NOT EXISTS public.credential (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  password_hash character varying(255) NOT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  updated_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS credential_ix_user_id ON public.credential USING btree (user_id);
+
+-- ALTER TABLE public.credential OWNER TO madre;
+
+--
+-- user_mfa
+--
+
+CREATE TABLE IF NOT EXISTS public.user_mfa (
+  user_id uuid NOT NULL,
+  totp_secret character varying(255) NOT NULL,
+  enabled_at timestamp with time zone DEFAULT NULL,
+  last_used_step bigint NOT NULL DEFAULT 0,
+  failed_attempts integer NOT NULL DEFAULT 0,
+  locked_until timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  updated_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (user_id)
+);
+
+-- ALTER TABLE public.user_mfa OWNER TO madre;
+
+--
+-- mfa_recovery_code
+--
+
+CREATE TABLE IF NOT EXISTS public.mfa_recovery_code (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  code_hash character varying(64) NOT NULL,
+  used_at timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS mfa_recovery_code_ix_user_id_code_hash ON public.mfa_recovery_code USING btree (user_id, code_hash);
+
+-- ALTER TABLE public.mfa_recovery_code OWNER TO madre;
+
+--
+-- session
+--
+
+CREATE TABLE IF NOT EXISTS public.session (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  revoked_at timestamp with time zone DEFAULT NULL,
+  revoked_reason character varying(32) DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  updated_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+-- the device of the session, refreshed with last_seen_at at most once per SESSION_CACHE_TTL
+ALTER TABLE public.session ADD COLUMN IF NOT EXISTS user_agent character varying(512) DEFAULT NULL;
+ALTER TABLE public.session ADD COLUMN IF NOT EXISTS ip character varying(64) DEFAULT NULL;
+ALTER TABLE public.session ADD COLUMN IF NOT EXISTS last_seen_at timestamp with time zone DEFAULT now() NOT NULL;
+
+CREATE INDEX IF NOT EXISTS session_ix_user_id ON public.session USING btree (user_id);
+
+-- ALTER TABLE public.session OWNER TO madre;
+
+--
+-- session_refresh_token
+--
+
+CREATE TABLE IF NOT EXISTS public.session_refresh_token (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  session_id uuid NOT NULL,
+  token_uuid uuid NOT NULL,
+  used_at timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS session_refresh_token_ix_token_uuid ON public.session_refresh_token USING btree (token_uuid);
+CREATE INDEX IF NOT EXISTS session_refresh_token_ix_session_id ON public.session_refresh_token USING btree (session_id);
+
+-- ALTER TABLE public.session_refresh_token OWNER TO madre;
+
+--
+-- user_role
+-- a user without role is a regular user, the first admin is granted with
+-- INSERT INTO public.user_role(user_id, role) VALUES('<user id>', 'admin');
+--
+
+CREATE TABLE IF NOT EXISTS public.user_role (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  role character varying(16) NOT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_role_ix_user_id_role ON public.user_role USING btree (user_id, role);
+
+-- ALTER TABLE public.user_role OWNER TO madre;
+
+--
+-- personal_access_token
+-- only the sha256 of the token is stored, token_prefix is its start kept to identify it
+--
+
+CREATE TABLE IF NOT EXISTS public.personal_access_token (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  name character varying(64) NOT NULL,
+  token_prefix character varying(32) NOT NULL,
+  token_hash character varying(64) NOT NULL,
+  scopes character varying(255) NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  last_used_at timestamp with time zone DEFAULT NULL,
+  revoked_at timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS personal_access_token_ix_token_hash ON public.personal_access_token USING btree (token_hash);
+CREATE INDEX IF NOT EXISTS personal_access_token_ix_user_id ON public.personal_access_token USING btree (user_id);
+
+-- ALTER TABLE public.personal_access_token OWNER TO madre;
+
+--
+-- login_event
+-- the sign-in attempts, user_id is null when the login matched no user
+--
+
+CREATE TABLE IF NOT EXISTS public.login_event (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid DEFAULT NULL,
+  provider character varying(32) NOT NULL,
+  outcome character varying(16) NOT NULL,
+  reason character varying(64) DEFAULT NULL,
+  user_agent character varying(512) DEFAULT NULL,
+  ip character varying(64) DEFAULT NULL,
+  device_fingerprint character varying(64) NOT NULL,
+  new_device boolean NOT NULL DEFAULT false,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS login_event_ix_user_id_created_at ON public.login_event USING btree (user_id, created_at DESC);
+-- the devices a user already signed in from
+CREATE INDEX IF NOT EXISTS login_event_ix_user_id_device_fingerprint ON public.login_event USING btree (user_id, device_fingerprint) WHERE outcome = 'success';
+
+-- ALTER TABLE public.login_event OWNER TO madre;
+
+--
+-- webauthn_credential
+--
+
+CREATE TABLE IF NOT EXISTS public.webauthn_credential (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  credential_id character varying(1366) NOT NULL,
+  public_key bytea NOT NULL,
+  sign_count bigint NOT NULL DEFAULT 0,
+  aaguid bytea DEFAULT NULL,
+  name character varying(64) NOT NULL,
+  last_used_at timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS webauthn_credential_ix_credential_id ON public.webauthn_credential USING btree (credential_id);
+CREATE INDEX IF NOT EXISTS webauthn_credential_ix_user_id ON public.webauthn_credential USING btree (user_id);
+
+-- ALTER TABLE public.webauthn_credential OWNER TO madre;
+
+--
+-- webauthn_challenge
+--
+
+CREATE TABLE IF NOT EXISTS public.webauthn_challenge (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  challenge character varying(64) NOT NULL,
+  ceremony character varying(16) NOT NULL,
+  user_id uuid DEFAULT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS webauthn_challenge_ix_challenge ON public.webauthn_challenge USING btree (challenge);
+CREATE INDEX IF NOT EXISTS webauthn_challenge_ix_expires_at ON public.webauthn_challenge USING btree (expires_at);
+
+-- ALTER TABLE public.webauthn_challenge OWNER TO madre;
+
+--
+-- email_verification
+--
+
+CREATE TABLE IF NOT EXISTS public.email_verification (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  email character varying(255) NOT NULL,
+  token_hash character varying(64) NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  used_at timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS email_verification_ix_token_hash ON public.email_verification USING btree (token_hash);
+CREATE INDEX IF NOT EXISTS email_verification_ix_user_id ON public.email_verification USING btree (user_id);
+
+-- ALTER TABLE public.email_verification OWNER TO madre;
+
+--
+-- email_sign_in
+--
+
+CREATE TABLE IF NOT EXISTS public.email_sign_in (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  email character varying(255) NOT NULL,
+  token_hash character varying(64) NOT NULL,
+  code_hash character varying(64) NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  expires_at timestamp with time zone NOT NULL,
+  used_at timestamp with time zone DEFAULT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS email_sign_in_ix_token_hash ON public.email_sign_in USING btree (token_hash);
+CREATE INDEX IF NOT EXISTS email_sign_in_ix_lower_email_created_at ON public.email_sign_in USING btree (lower(email), created_at DESC);
+
+-- ALTER TABLE public.email_sign_in OWNER TO madre;
+
+--
+-- username_history
+--
+
+CREATE TABLE IF NOT EXISTS public.username_history (
+  id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+  user_id uuid NOT NULL,
+  username character varying(20) NOT NULL,
+  created_at timestamp with time zone DEFAULT now() NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS username_history_ix_lower_username_created_at ON public.username_history USING btree (lower(username), created_at DESC);
+CREATE INDEX IF NOT EXISTS username_history_ix_user_id ON public.username_history USING btree (user_id);
+
+-- ALTER TABLE public.username_history OWNER TO madre;
+
 --
 -- data
 --
@@ -87,6 +375,8 @@ CREATE TABLE IF NOT EXISTS public.data (
 
 CREATE INDEX IF NOT EXISTS data_ix_user_id ON public.data USING btree (user_id);
 CREATE INDEX IF NOT EXISTS data_ix_created_at ON public.data USING btree(created_at);
+-- public data listing of a user, newest first
+CREATE INDEX IF NOT EXISTS data_ix_user_id_created_at_public ON public.data USING btree (user_id, created_at DESC) WHERE is_public = true;
 
 -- ALTER TABLE public.data OWNER TO madre;
 
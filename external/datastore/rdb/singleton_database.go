@@ -1,13 +1,14 @@
 package rdb
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
-// TODO: the structure to use transaction flexibly should be considered and rewritten
 type singletonDatabase struct {
 	DB *sqlx.DB
 	l  *zerolog.Logger
@@ -28,6 +29,11 @@ func (sd *singletonDatabase) NamedQuery(query string, arg any) (*sqlx.Rows, erro
 	return sd.DB.NamedQuery(query, arg)
 }
 
+func (sd *singletonDatabase) NamedExec(query string, arg any) (sql.Result, error) {
+	sd.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, arg)).Send()
+	return sd.DB.NamedExec(query, arg)
+}
+
 func (sd *singletonDatabase) PrepareNamedGet(result any, query string, arg any) error {
 	sd.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, arg)).Send()
 	stmt, err := sd.DB.PrepareNamed(query)
@@ -38,21 +44,23 @@ func (sd *singletonDatabase) PrepareNamedGet(result any, query string, arg any)
 	return stmt.Get(result, arg)
 }
 
-// func (sd *singletonDatabase) WithTimeoutTxx() (*sqlx.Tx, error) {
-// 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
-// 	defer cancel()
-
-// 	tx, err := sd.DB.BeginTxx(ctx, nil)
-// 	if err != nil {
-// 		return nil, errors.Wrap(err, "database instance WithTimeoutTxx BeginTxx error")
-// 	}
-
-// 	return tx, nil
-// }
+func (sd *singletonDatabase) Transaction(fn func(tx Database) error) error {
+	tx, err := sd.DB.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "singletonDatabase Transaction Beginx")
+	}
+	// a no-op once committed
+	defer tx.Rollback()
 
-// func(sd *singletonDatabase) Queryx(query string, args ...any) (*sqlx.Rows, error) {
+	if err := fn(&txDatabase{tx, sd.l}); err != nil {
+		return err
+	}
 
-// }
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "singletonDatabase Transaction Commit")
+	}
+	return nil
+}
 
 // ####### Test #######
 // ctx := context.Background()
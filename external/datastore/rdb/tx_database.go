@@ -0,0 +1,50 @@
+package rdb
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
+)
+
+// txDatabase is the Database of a running transaction
+type txDatabase struct {
+	tx *sqlx.Tx
+	l  *zerolog.Logger
+}
+
+func (td *txDatabase) Queryx(query string, args ...any) (*sqlx.Rows, error) {
+	td.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, args)).Send()
+	return td.tx.Queryx(query, args...)
+}
+
+func (td *txDatabase) QueryRowx(query string, args ...any) *sqlx.Row {
+	td.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, args)).Send()
+	return td.tx.QueryRowx(query, args...)
+}
+
+func (td *txDatabase) NamedQuery(query string, arg any) (*sqlx.Rows, error) {
+	td.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, arg)).Send()
+	return td.tx.NamedQuery(query, arg)
+}
+
+func (td *txDatabase) NamedExec(query string, arg any) (sql.Result, error) {
+	td.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, arg)).Send()
+	return td.tx.NamedExec(query, arg)
+}
+
+func (td *txDatabase) PrepareNamedGet(result any, query string, arg any) error {
+	td.l.Log().Timestamp().Str("query", fmt.Sprintf("%s,%+v", query, arg)).Send()
+	stmt, err := td.tx.PrepareNamed(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.Get(result, arg)
+}
+
+// Transaction joins the running transaction
+func (td *txDatabase) Transaction(fn func(tx Database) error) error {
+	return fn(td)
+}
@@ -15,9 +15,12 @@ import (
 	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
 	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
 	"github.com/rlawnsxo131/madre-server-v3/internal/api/apiv1"
+	commandservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/command"
+	queryservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/query"
 
 	"github.com/rlawnsxo131/madre-server-v3/lib/env"
 	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
 )
 
 const (
@@ -46,8 +49,22 @@ func NewHTTPEngine(db rdb.Database) *httpEngine {
 			Handler:      r,
 		},
 	}
+	opts, err := token.LoadOptions()
+	if err != nil {
+		logger.DefaultLogger().Fatal().Timestamp().Err(err).Send()
+	}
+	token.SetOptions(opts)
+	token.SetSessionStore(commandservice.NewSessionStore(db))
+	if f := env.JWTSigningKeyFile(); f != "" {
+		ks, err := token.LoadKeySet(f, env.JWTVerificationKeyFiles())
+		if err != nil {
+			logger.DefaultLogger().Fatal().Timestamp().Err(err).Send()
+		}
+		token.SetKeySet(ks)
+	}
 	e.RegisterHTTPMiddleware()
 	e.RegisterHealthRoute()
+	e.RegisterWellKnownRoute()
 	e.RegisterAPIRoute()
 	return e
 }
@@ -101,7 +118,11 @@ func (e *httpEngine) RegisterHTTPMiddleware() {
 	e.r.Use(httpmiddleware.Recovery)
 	e.r.Use(httpmiddleware.AllowHost)
 	e.r.Use(httpmiddleware.Cors)
+	e.r.Use(httpmiddleware.CSRF)
 	e.r.Use(httpmiddleware.JWT)
+	e.r.Use(httpmiddleware.PersonalAccessToken(commandservice.NewPersonalAccessTokenResolver(e.db)))
+	e.r.Use(httpmiddleware.UserStatus(queryservice.DefaultUserStatusChecker(e.db)))
+	e.r.Use(httpmiddleware.Session(commandservice.DefaultSessionChecker(e.db)))
 	e.r.Use(httpmiddleware.ContentTypeToJson)
 	e.r.Use(chi_middleware.Compress(5))
 }
@@ -120,6 +141,20 @@ func (e *httpEngine) RegisterHealthRoute() {
 	})
 }
 
+// RegisterWellKnownRoute publishes the public keys of the auth tokens,
+// the other services verify the access tokens with them instead of sharing a secret
+func (e *httpEngine) RegisterWellKnownRoute() {
+	e.r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		jwks := &token.JWKS{Keys: []token.JWK{}}
+		if ks := token.DefaultKeySet(); ks != nil {
+			jwks = ks.JWKS()
+		}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		rw.Write(jwks)
+	})
+}
+
 func (e *httpEngine) RegisterAPIRoute() {
 	e.r.Route("/api", func(r chi.Router) {
 		apiv1.NewAPI(r, e.db).Register()
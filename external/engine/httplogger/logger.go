@@ -79,9 +79,7 @@ func (hl *logger) Write(t time.Time) {
 		f(e)
 	}
 
-	if ip := clientIP(hl.r.Header); ip != "" {
-		e.Str("client-ip", ip)
-	} else if ip, _, err := net.SplitHostPort(strings.TrimSpace(hl.r.RemoteAddr)); err == nil {
+	if ip := ClientIP(hl.r); ip != "" {
 		e.Str("client-ip", ip)
 	}
 
@@ -96,6 +94,17 @@ var (
 	xEnvoyExternalAddress = http.CanonicalHeaderKey("X-Envoy-External-Address")
 )
 
+// ClientIP returns the IP of the client, the remote address is used when no header identifies it
+func ClientIP(r *http.Request) string {
+	if ip := clientIP(r.Header); ip != "" {
+		return ip
+	}
+	if ip, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr)); err == nil {
+		return ip
+	}
+	return ""
+}
+
 // clientIP returns the IP of the client.
 // If a header identifying the real IP exists, the value of the header will be used.
 func clientIP(h http.Header) string {
@@ -0,0 +1,77 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+// RequireAuth answers 401 unless the JWT middleware set a profile,
+// the handlers behind it can use token.ProfileCtx without checking it.
+// A personal access token is refused with 403, it is only accepted behind RequireScope.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := token.ProfileCtx(r.Context())
+		if p == nil {
+			rw := httpresponse.NewWriter(w, r)
+			rw.ErrorUnauthorized(
+				errors.New("not found token profile"),
+			)
+			return
+		}
+		if p.IsPersonalAccessToken() {
+			rw := httpresponse.NewWriter(w, r)
+			rw.ErrorForbidden(
+				errors.New("RequireAuth personal access token is not allowed"),
+			)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope answers 401 without a profile and 403 when a personal access token lacks the scope,
+// the profiles of a sign-in have every scope
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := token.ProfileCtx(r.Context())
+			if p == nil {
+				rw := httpresponse.NewWriter(w, r)
+				rw.ErrorUnauthorized(
+					errors.New("not found token profile"),
+				)
+				return
+			}
+			if !p.HasScope(scope) {
+				rw := httpresponse.NewWriter(w, r)
+				rw.ErrorForbidden(
+					errors.New("RequireScope missing scope " + scope),
+				)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole answers 401 without a profile and 403 when the profile has none of the roles
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := token.ProfileCtx(r.Context())
+			for _, role := range roles {
+				if p.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			rw := httpresponse.NewWriter(w, r)
+			rw.ErrorForbidden(
+				errors.New("RequireRole forbidden role"),
+			)
+		}))
+	}
+}
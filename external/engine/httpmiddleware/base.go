@@ -52,7 +52,7 @@ func Cors(next http.Handler) http.Handler {
 					if method == "OPTIONS" {
 						w.Header().Set(
 							"Access-Control-Allow-Headers",
-							"Content-Type, Access-Control-Allow-Headers, Authorization, X-Requested-With, Cookie, X-CSRF-Token",
+							"Content-Type, Access-Control-Allow-Headers, Authorization, X-Requested-With, Cookie, X-CSRF-Token, X-Device-ID",
 						)
 						w.Header().Set(
 							"Access-Control-Allow-Methods",
@@ -0,0 +1,58 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+var (
+	csrfSafeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace}
+)
+
+// CSRF checks the double submit token of the state-changing requests authenticated by the cookies.
+// The requests with an Authorization header are exempt, another site can not make the browser send one.
+// A client without the csrf cookie gets one with the response.
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		csrfToken := token.CSRFCookie(r)
+		if csrfToken == "" {
+			v, err := token.NewManager().SetCSRFCookie(w)
+			if err != nil {
+				rw := httpresponse.NewWriter(w, r)
+				rw.Error(
+					errors.Wrap(err, "CSRF set cookie error"),
+				)
+				return
+			}
+			csrfToken = v
+		}
+		r = r.WithContext(token.SetCSRFToken(r.Context(), csrfToken))
+
+		if isCSRFSafeMethod(r.Method) || token.BearerToken(r) != "" || !token.HasAuthCookies(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !token.CSRFTokenMatches(r) {
+			rw := httpresponse.NewWriter(w, r)
+			rw.ErrorForbidden(
+				errors.New("CSRF token mismatch"),
+			)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isCSRFSafeMethod(method string) bool {
+	for _, m := range csrfSafeMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -3,108 +3,45 @@ package httpmiddleware
 import (
 	"net/http"
 
-	"github.com/golang-jwt/jwt"
-	"github.com/pkg/errors"
-	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
 	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
 	"github.com/rlawnsxo131/madre-server-v3/lib/token"
 )
 
-// When the token already exists,
-// if an error occurs when reissuing the token,
-// only logging is processed so that other functions can be used.
+// JWT sets the profile of the auth tokens, the request goes on anonymously when they can not be used
+// and only logging is processed so that the other functions can be used.
+// An access token in the Authorization header is used instead of the cookies and is never
+// refreshed here, its client refreshes it with the refresh token it keeps.
+// The cookies are refreshed by token.Authenticate, see token.AuthOutcome.
 func JWT(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		actk, err := r.Cookie(token.ACCESS_TOKEN)
 		tokenManager := token.NewManager()
-		if err != nil {
-			if err != http.ErrNoCookie {
-				rw := httpresponse.NewWriter(w, r)
-				rw.Error(
-					errors.Wrap(err, "JWT get Access_token error"),
-				)
-				return
-			}
+
+		bearer := token.BearerToken(r)
+		// the personal access tokens are resolved by the PersonalAccessToken middleware
+		if token.IsPersonalAccessToken(bearer) {
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		if actk != nil {
-			claims, err := tokenManager.Decode(actk.Value)
+		if bearer != "" {
+			p, err := tokenManager.ProfileFromAccessToken(bearer)
 			if err != nil {
-				_, ok := err.(*jwt.ValidationError)
-				if ok {
-					rftk, err := r.Cookie(token.REFRESH_TOKEN)
-					if err != nil {
-						if err != http.ErrNoCookie {
-							rw := httpresponse.NewWriter(w, r)
-							rw.Error(
-								errors.Wrap(err, "JWT get Refresh_token error"),
-							)
-							return
-						}
-					}
-
-					if rftk != nil {
-						claims, err := tokenManager.Decode(rftk.Value)
-						if err != nil {
-							// remove cookies
-							tokenManager.ResetCookies(w)
-						} else {
-							p := token.NewProfile(
-								claims.UserID,
-								claims.Username,
-								claims.PhotoUrl,
-							)
-							err = tokenManager.GenerateAndSetCookies(p, w)
-							if err != nil {
-								logger.DefaultLogger().Err(err).Timestamp().Str("action", "JWT").Send()
-							} else {
-								ctx = token.SetProfile(ctx, p)
-							}
-						}
-					}
-				}
+				logger.DefaultLogger().Err(err).Timestamp().Str("action", "JWT").Send()
 			} else {
-				p := token.NewProfile(
-					claims.UserID,
-					claims.Username,
-					claims.PhotoUrl,
-				)
 				ctx = token.SetProfile(ctx, p)
 			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
 		}
 
-		if actk == nil {
-			rftk, err := r.Cookie(token.ACCESS_TOKEN)
-			if err != nil {
-				if err != http.ErrNoCookie {
-					rw := httpresponse.NewWriter(w, r)
-					rw.Error(
-						errors.Wrap(err, "JWT get Refresh_token error"),
-					)
-					return
-				}
-			}
-
-			if rftk != nil {
-				claims, err := tokenManager.Decode(rftk.Value)
-				if err != nil {
-					// remove cookies
-					tokenManager.ResetCookies(w)
-				} else {
-					p := token.NewProfile(
-						claims.UserID,
-						claims.Username,
-						claims.PhotoUrl,
-					)
-					err = tokenManager.GenerateAndSetCookies(p, w)
-					if err != nil {
-						logger.DefaultLogger().Err(err).Timestamp().Str("action", "JWT").Send()
-					} else {
-						ctx = token.SetProfile(ctx, p)
-					}
-				}
-			}
+		a := tokenManager.Authenticate(r, w)
+		if a.Err != nil {
+			logger.DefaultLogger().Err(a.Err).Timestamp().
+				Str("action", "JWT").Str("outcome", string(a.Outcome)).Send()
+		}
+		if a.Profile != nil {
+			ctx = token.SetProfile(ctx, a.Profile)
 		}
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,37 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+type PersonalAccessTokenResolver interface {
+	ResolvePersonalAccessToken(raw string) (*token.PersonalAccessTokenOwner, error)
+}
+
+// PersonalAccessToken sets the profile of a personal access token of the Authorization header,
+// the routes it can use are the ones behind RequireScope with one of its scopes.
+// When the token can not be resolved, only logging is processed like in JWT.
+func PersonalAccessToken(resolver PersonalAccessTokenResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := token.BearerToken(r)
+			if !token.IsPersonalAccessToken(raw) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			o, err := resolver.ResolvePersonalAccessToken(raw)
+			if err != nil {
+				logger.DefaultLogger().Err(err).Timestamp().Str("action", "PersonalAccessToken").Send()
+			}
+			if o != nil {
+				r = r.WithContext(token.SetProfile(r.Context(), token.NewPersonalAccessTokenProfile(o)))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+type SessionChecker interface {
+	CheckSession(sessionId, userAgent, ip string) (bool, error)
+}
+
+// Session signs out the profile set by JWT when its session was revoked from another device.
+// When the session can not be read, only logging is processed like in JWT.
+func Session(sc SessionChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := token.ProfileCtx(r.Context())
+			// the personal access tokens are not signed in a session
+			if p == nil || p.IsPersonalAccessToken() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, err := sc.CheckSession(p.SessionID, r.UserAgent(), httplogger.ClientIP(r))
+			if err != nil {
+				logger.DefaultLogger().Err(err).Timestamp().Str("action", "Session").Send()
+			}
+			if !allowed {
+				token.NewManager().ResetCookies(w)
+				r = r.WithContext(token.SetProfile(r.Context(), nil))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package httpmiddleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chi_middleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(h http.Handler, roles []string, signedIn bool) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	ctx := httplogger.SetLoggerCtx(r.Context(), httplogger.NewLogger(r, chi_middleware.NewWrapResponseWriter(w, r.ProtoMajor)))
+	if signedIn {
+		ctx = token.SetProfile(ctx, token.NewProfile("user-id", "madre", "", roles))
+	}
+	h.ServeHTTP(w, r.WithContext(ctx))
+	return w.Code
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func Test_RequireAuth(t *testing.T) {
+	assert := assert.New(t)
+	h := httpmiddleware.RequireAuth(ok)
+
+	assert.Equal(http.StatusUnauthorized, serve(h, nil, false))
+	assert.Equal(http.StatusOK, serve(h, nil, true))
+}
+
+func Test_RequireRole(t *testing.T) {
+	assert := assert.New(t)
+	h := httpmiddleware.RequireRole("admin", "moderator")(ok)
+
+	assert.Equal(http.StatusUnauthorized, serve(h, nil, false))
+	assert.Equal(http.StatusForbidden, serve(h, nil, true))
+	assert.Equal(http.StatusForbidden, serve(h, []string{"editor"}, true))
+	assert.Equal(http.StatusOK, serve(h, []string{"admin"}, true))
+	assert.Equal(http.StatusOK, serve(h, []string{"editor", "moderator"}, true))
+}
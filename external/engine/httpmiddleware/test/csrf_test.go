@@ -0,0 +1,84 @@
+package httpmiddleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chi_middleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CSRF(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		name       string
+		method     string
+		authCookie bool
+		csrfCookie string
+		csrfHeader string
+		bearer     string
+		code       int
+	}{
+		{"safe method", http.MethodGet, true, "", "", "", http.StatusOK},
+		{"without auth cookies", http.MethodPost, false, "", "", "", http.StatusOK},
+		{"matching token", http.MethodPost, true, "csrf", "csrf", "", http.StatusOK},
+		{"matching token on delete", http.MethodDelete, true, "csrf", "csrf", "", http.StatusOK},
+		{"missing header", http.MethodPut, true, "csrf", "", "", http.StatusForbidden},
+		{"missing cookie", http.MethodPatch, true, "", "csrf", "", http.StatusForbidden},
+		{"mismatch", http.MethodPost, true, "csrf", "other", "", http.StatusForbidden},
+		{"bearer", http.MethodPost, true, "", "", "access-token", http.StatusOK},
+		{"personal access token", http.MethodDelete, false, "", "", "madre_pat_token", http.StatusOK},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", nil)
+		if c.authCookie {
+			r.AddCookie(&http.Cookie{Name: token.ACCESS_TOKEN, Value: "access-token"})
+		}
+		if c.csrfCookie != "" {
+			r.AddCookie(&http.Cookie{Name: token.CSRF_TOKEN, Value: c.csrfCookie})
+		}
+		if c.csrfHeader != "" {
+			r.Header.Set(token.CSRF_HEADER, c.csrfHeader)
+		}
+		if c.bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+c.bearer)
+		}
+		w := httptest.NewRecorder()
+		ctx := httplogger.SetLoggerCtx(r.Context(), httplogger.NewLogger(r, chi_middleware.NewWrapResponseWriter(w, r.ProtoMajor)))
+		httpmiddleware.CSRF(ok).ServeHTTP(w, r.WithContext(ctx))
+
+		assert.Equal(c.code, w.Code, c.name)
+	}
+}
+
+func Test_CSRF_IssuesCookie(t *testing.T) {
+	assert := assert.New(t)
+
+	var issued string
+	h := httpmiddleware.CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued = token.CSRFTokenCtx(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cookies := w.Result().Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal(token.CSRF_TOKEN, cookies[0].Name)
+	assert.False(cookies[0].HttpOnly)
+	assert.NotEmpty(issued)
+	assert.Equal(issued, cookies[0].Value)
+
+	// the token of the cookie is kept
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: token.CSRF_TOKEN, Value: "csrf"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Empty(w.Result().Cookies())
+	assert.Equal("csrf", issued)
+}
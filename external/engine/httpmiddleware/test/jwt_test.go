@@ -0,0 +1,118 @@
+package httpmiddleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionStore accepts every session, the rules of the sessions are tested in lib/token
+type sessionStore struct{}
+
+func (sessionStore) CreateSession(userId, refreshTokenUUID string, client token.SessionClient, expiresAt time.Time) (string, error) {
+	return "session-id", nil
+}
+
+func (sessionStore) RotateSession(sessionId, refreshTokenUUID string, expiresAt time.Time) error {
+	return nil
+}
+
+func (sessionStore) UseRefreshToken(sessionId, refreshTokenUUID string) error {
+	return nil
+}
+
+func (sessionStore) RevokeSession(sessionId string) error {
+	return nil
+}
+
+func (sessionStore) SessionUser(sessionId, userId string) (*token.SessionUser, error) {
+	return &token.SessionUser{Username: "madre"}, nil
+}
+
+func Test_JWT_Bearer(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	token.SetSessionStore(sessionStore{})
+
+	tokens, err := token.NewManager().GenerateTokens(token.NewProfile("user-id", "madre", "", nil), token.SessionClient{})
+	assert.Nil(err)
+
+	cases := []struct {
+		name     string
+		header   string
+		signedIn bool
+	}{
+		{"access token", "Bearer " + tokens.AccessToken, true},
+		{"refresh token", "Bearer " + tokens.RefreshToken, false},
+		{"invalid token", "Bearer invalid", false},
+	}
+	for _, c := range cases {
+		var p any
+		h := httpmiddleware.JWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tp := token.ProfileCtx(r.Context()); tp != nil {
+				assert.True(tp.Bearer, c.name)
+				assert.Equal("session-id", tp.SessionID, c.name)
+				p = tp
+			}
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", c.header)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		assert.Equal(c.signedIn, p != nil, c.name)
+		assert.Empty(w.Result().Cookies(), c.name)
+	}
+}
+
+func Test_JWT_Cookies(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	token.SetSessionStore(sessionStore{})
+
+	w := httptest.NewRecorder()
+	assert.Nil(token.NewManager().GenerateAndSetCookies(token.NewProfile("user-id", "madre", "", nil), token.SessionClient{}, w))
+	issued := map[string]string{}
+	for _, c := range w.Result().Cookies() {
+		issued[c.Name] = c.Value
+	}
+
+	cases := []struct {
+		name      string
+		access    string
+		refresh   string
+		signedIn  bool
+		setCookie bool
+	}{
+		{"no cookies", "", "", false, false},
+		{"access token", issued[token.ACCESS_TOKEN], "", true, false},
+		// the refresh cookie outlives the access cookie in the browser
+		{"refresh token only", "", issued[token.REFRESH_TOKEN], true, true},
+		{"malformed access token", "invalid", issued[token.REFRESH_TOKEN], false, true},
+	}
+	for _, c := range cases {
+		signedIn := false
+		h := httpmiddleware.JWT(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			signedIn = token.ProfileCtx(r.Context()) != nil
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.access != "" {
+			r.AddCookie(&http.Cookie{Name: token.ACCESS_TOKEN, Value: c.access})
+		}
+		if c.refresh != "" {
+			r.AddCookie(&http.Cookie{Name: token.REFRESH_TOKEN, Value: c.refresh})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		assert.Equal(c.signedIn, signedIn, c.name)
+		assert.Equal(c.setCookie, len(w.Result().Cookies()) > 0, c.name)
+	}
+}
@@ -0,0 +1,65 @@
+package httpmiddleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chi_middleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// patResolver knows the tokens of its map with their scopes
+type patResolver map[string][]string
+
+func (pr patResolver) ResolvePersonalAccessToken(raw string) (*token.PersonalAccessTokenOwner, error) {
+	scopes, ok := pr[raw]
+	if !ok {
+		return nil, nil
+	}
+	return &token.PersonalAccessTokenOwner{
+		TokenID: "token-id",
+		UserID:  "user-id",
+		Scopes:  scopes,
+	}, nil
+}
+
+func servePAT(h http.Handler, header string) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", header)
+	w := httptest.NewRecorder()
+	ctx := httplogger.SetLoggerCtx(r.Context(), httplogger.NewLogger(r, chi_middleware.NewWrapResponseWriter(w, r.ProtoMajor)))
+	httpmiddleware.JWT(httpmiddleware.PersonalAccessToken(patResolver{
+		"madre_pat_known":  {"data:read"},
+		"madre_pat_writer": {"data:write"},
+	})(h)).ServeHTTP(w, r.WithContext(ctx))
+	return w.Code
+}
+
+func Test_PersonalAccessToken(t *testing.T) {
+	assert := assert.New(t)
+
+	read := httpmiddleware.RequireScope("data:read")(ok)
+	write := httpmiddleware.RequireScope("data:write")(ok)
+
+	assert.Equal(http.StatusOK, servePAT(read, "Bearer madre_pat_known"))
+	assert.Equal(http.StatusForbidden, servePAT(write, "Bearer madre_pat_known"))
+	assert.Equal(http.StatusOK, servePAT(write, "Bearer madre_pat_writer"))
+	assert.Equal(http.StatusForbidden, servePAT(read, "Bearer madre_pat_writer"))
+	assert.Equal(http.StatusUnauthorized, servePAT(read, "Bearer madre_pat_unknown"))
+	assert.Equal(http.StatusUnauthorized, servePAT(read, ""))
+
+	// the routes of the account stay behind a sign-in
+	assert.Equal(http.StatusForbidden, servePAT(httpmiddleware.RequireAuth(ok), "Bearer madre_pat_known"))
+}
+
+func Test_RequireScope_SignIn(t *testing.T) {
+	assert := assert.New(t)
+	h := httpmiddleware.RequireScope("data:write")(ok)
+
+	assert.Equal(http.StatusUnauthorized, serve(h, nil, false))
+	assert.Equal(http.StatusOK, serve(h, nil, true))
+}
@@ -0,0 +1,57 @@
+package httpmiddleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionChecker struct {
+	allowed bool
+	err     error
+	seen    *[]string
+}
+
+func (c sessionChecker) CheckSession(sessionId, userAgent, ip string) (bool, error) {
+	*c.seen = []string{sessionId, userAgent, ip}
+	return c.allowed, c.err
+}
+
+func Test_Session(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		name     string
+		allowed  bool
+		err      error
+		signedIn bool
+	}{
+		{"active", true, nil, true},
+		{"revoked", false, nil, false},
+		{"unreadable session keeps the profile", true, errors.New("db down"), true},
+	}
+	for _, c := range cases {
+		var checked []string
+		var seen bool
+		h := httpmiddleware.Session(sessionChecker{c.allowed, c.err, &checked})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = token.ProfileCtx(r.Context()) != nil
+		}))
+
+		p := token.NewProfile("user-id", "madre", "", nil)
+		p.SessionID = "session-id"
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("User-Agent", "Mozilla/5.0")
+		r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r.WithContext(token.SetProfile(r.Context(), p)))
+
+		assert.Equal([]string{"session-id", "Mozilla/5.0", "203.0.113.7"}, checked, c.name)
+		assert.Equal(c.signedIn, seen, c.name)
+		assert.Equal(!c.signedIn, len(w.Result().Cookies()) > 0, c.name)
+	}
+}
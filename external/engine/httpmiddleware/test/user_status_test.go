@@ -0,0 +1,48 @@
+package httpmiddleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+type userStatusChecker struct {
+	allowed bool
+	err     error
+}
+
+func (c userStatusChecker) CheckUserStatus(userId string) (bool, error) {
+	return c.allowed, c.err
+}
+
+func Test_UserStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		name     string
+		checker  userStatusChecker
+		signedIn bool
+	}{
+		{"active", userStatusChecker{true, nil}, true},
+		{"suspended", userStatusChecker{false, nil}, false},
+		{"unreadable status keeps the profile", userStatusChecker{true, errors.New("db down")}, true},
+	}
+	for _, c := range cases {
+		var seen bool
+		h := httpmiddleware.UserStatus(c.checker)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = token.ProfileCtx(r.Context()) != nil
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r.WithContext(token.SetProfile(r.Context(), token.NewProfile("user-id", "madre", "", nil))))
+
+		assert.Equal(c.signedIn, seen, c.name)
+		assert.Equal(!c.signedIn, len(w.Result().Cookies()) > 0, c.name)
+	}
+}
@@ -0,0 +1,37 @@
+package httpmiddleware
+
+import (
+	"net/http"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+type UserStatusChecker interface {
+	CheckUserStatus(userId string) (bool, error)
+}
+
+// UserStatus signs out the profile set by JWT when the user was suspended or banned meanwhile.
+// When the status can not be read, only logging is processed like in JWT.
+func UserStatus(sc UserStatusChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := token.ProfileCtx(r.Context())
+			if p == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, err := sc.CheckUserStatus(p.UserID)
+			if err != nil {
+				logger.DefaultLogger().Err(err).Timestamp().Str("action", "UserStatus").Send()
+			}
+			if !allowed {
+				token.NewManager().ResetCookies(w)
+				r = r.WithContext(token.SetProfile(r.Context(), nil))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
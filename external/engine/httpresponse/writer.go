@@ -17,7 +17,9 @@ const (
 	HTTP_CODE_NOT_FOUND             = "NotFound"            // 404
 	HTTP_CODE_CONFLICT              = "Conflict"            // 409
 	HTTP_CODE_UNPROCESSABLE_ENTITY  = "UnprocessableEntity" // 422
+	HTTP_CODE_TOO_MANY_REQUESTS     = "TooManyRequests"     // 429
 	HTTP_CODE_INTERNAL_SERVER_ERROR = "InternalServerError" // 500
+	HTTP_CODE_BAD_GATEWAY           = "BadGateway"          // 502
 )
 
 type Writer interface {
@@ -29,6 +31,8 @@ type Writer interface {
 	ErrorNotFound(err error)
 	ErrorConflict(err error)
 	ErrorUnprocessableEntity(err error)
+	ErrorTooManyRequests(err error)
+	ErrorBadGateway(err error)
 	standardError(status int, code string, err error)
 }
 
@@ -116,6 +120,22 @@ func (wt *writer) ErrorUnprocessableEntity(err error) {
 	)
 }
 
+func (wt *writer) ErrorTooManyRequests(err error) {
+	wt.standardError(
+		http.StatusTooManyRequests,
+		HTTP_CODE_TOO_MANY_REQUESTS,
+		err,
+	)
+}
+
+func (wt *writer) ErrorBadGateway(err error) {
+	wt.standardError(
+		http.StatusBadGateway,
+		HTTP_CODE_BAD_GATEWAY,
+		err,
+	)
+}
+
 func (wt *writer) standardError(status int, code string, err error) {
 	res, _ := json.Marshal(map[string]any{
 		"status": status,
@@ -0,0 +1,252 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	commandservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/command"
+	queryservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/query"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+type adminRoute struct {
+	accountCommandService account.AccountCommandService
+	accountQueryService   account.AccountQueryService
+	userStatusChecker     *queryservice.UserStatusChecker
+	sessionChecker        *commandservice.SessionChecker
+}
+
+func NewAdminRoute(db rdb.Database) *adminRoute {
+	return &adminRoute{
+		commandservice.NewAccountCommandService(db),
+		queryservice.NewAccountQueryService(db),
+		queryservice.DefaultUserStatusChecker(db),
+		commandservice.DefaultSessionChecker(db),
+	}
+}
+
+func (ar *adminRoute) Register(r chi.Router) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(httpmiddleware.RequireRole(account.ROLE_ADMIN))
+		r.Get("/users/{id}/roles", ar.GetUserRoles())
+		r.Put("/users/{id}/roles/{role}", ar.PutUserRole())
+		r.Delete("/users/{id}/roles/{role}", ar.DeleteUserRole())
+		r.Get("/users/{id}/status", ar.GetUserStatus())
+		r.Put("/users/{id}/status", ar.PutUserStatus())
+	})
+}
+
+func (ar *adminRoute) GetUserRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		u, ok := ar.findUser(rw, r)
+		if !ok {
+			return
+		}
+
+		roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"user_id": u.ID,
+			"roles":   roles,
+		})
+	}
+}
+
+// PutUserRole grants the role, the sessions of the user are ended so that it signs in again with the role
+func (ar *adminRoute) PutUserRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		role := chi.URLParam(r, "role")
+		if !account.IsRole(role) {
+			rw.ErrorNotFound(
+				errors.New("not found role"),
+			)
+			return
+		}
+
+		u, ok := ar.findUser(rw, r)
+		if !ok {
+			return
+		}
+
+		granted, sessionIds, err := ar.accountCommandService.GrantRole(u.ID, role)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.sessionChecker.Forget(sessionIds...)
+
+		rw.Write(map[string]any{
+			"granted": granted,
+		})
+	}
+}
+
+// DeleteUserRole revokes the role, the sessions of the user are ended so that no access token keeps it
+func (ar *adminRoute) DeleteUserRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		role := chi.URLParam(r, "role")
+		if !account.IsRole(role) {
+			rw.ErrorNotFound(
+				errors.New("not found role"),
+			)
+			return
+		}
+
+		u, ok := ar.findUser(rw, r)
+		if !ok {
+			return
+		}
+		// an admin can not lock everyone out by revoking its own role
+		if u.ID == p.UserID && role == account.ROLE_ADMIN {
+			rw.ErrorUnprocessableEntity(
+				errors.New("can not revoke own admin role"),
+			)
+			return
+		}
+
+		revoked, sessionIds, err := ar.accountCommandService.RevokeRole(u.ID, role)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.sessionChecker.Forget(sessionIds...)
+
+		rw.Write(map[string]any{
+			"revoked": revoked,
+		})
+	}
+}
+
+func (ar *adminRoute) GetUserStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		u, ok := ar.findUser(rw, r)
+		if !ok {
+			return
+		}
+
+		rw.Write(userStatus(u))
+	}
+}
+
+// PutUserStatus suspends, bans or reactivates a user, the tokens already issued
+// stop working once the cached status expires
+func (ar *adminRoute) PutUserStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Status         string     `json:"status" validate:"required,oneof=active suspended banned"`
+			Reason         string     `json:"reason" validate:"max=255"`
+			SuspendedUntil *time.Time `json:"suspended_until" validate:"required_if=Status suspended"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		until := time.Time{}
+		if params.Status == account.USER_STATUS_SUSPENDED {
+			until = *params.SuspendedUntil
+			if !until.After(time.Now()) {
+				rw.ErrorUnprocessableEntity(
+					errors.New("suspended_until is not in the future"),
+				)
+				return
+			}
+		}
+
+		u, ok := ar.findUser(rw, r)
+		if !ok {
+			return
+		}
+		if u.ID == p.UserID {
+			rw.ErrorUnprocessableEntity(
+				errors.New("can not change own status"),
+			)
+			return
+		}
+
+		u, err = ar.accountCommandService.ChangeUserStatus(u, params.Status, params.Reason, until)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.userStatusChecker.Forget(u.ID)
+
+		rw.Write(userStatus(u))
+	}
+}
+
+func userStatus(u *account.User) map[string]any {
+	res := map[string]any{
+		"user_id":         u.ID,
+		"status":          u.Status,
+		"reason":          utils.NormalizeNullString(u.StatusReason),
+		"suspended_until": nil,
+	}
+	if u.SuspendedUntil.Valid {
+		res["suspended_until"] = u.SuspendedUntil.Time
+	}
+	return res
+}
+
+// findUser returns false when the response was written
+func (ar *adminRoute) findUser(rw httpresponse.Writer, r *http.Request) (*account.User, bool) {
+	id := chi.URLParam(r, "id")
+	err := validator.New().Var(id, "required,uuid")
+	if err != nil {
+		rw.ErrorBadRequest(
+			errors.Wrap(err, "params validate error"),
+		)
+		return nil, false
+	}
+
+	u, err := ar.accountQueryService.GetUserById(id)
+	exist, err := u.IsExist(err)
+	if err != nil {
+		rw.Error(err)
+		return nil, false
+	}
+	if !exist {
+		rw.ErrorNotFound(
+			errors.New("not found user"),
+		)
+		return nil, false
+	}
+
+	return u, true
+}
@@ -17,5 +17,9 @@ func NewAPI(r chi.Router, db rdb.Database) *apiv1 {
 func (v1 *apiv1) Register() {
 	v1.r.Route("/v1", func(r chi.Router) {
 		NewAuthRoute(v1.db).Register(r)
+		NewMeRoute(v1.db).Register(r)
+		NewUserRoute(v1.db).Register(r)
+		NewDataRoute(v1.db).Register(r)
+		NewAdminRoute(v1.db).Register(r)
 	})
 }
@@ -0,0 +1,244 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/mailer"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// PostEmailStart mails a sign-in link and code, the response does not tell
+// whether the email belongs to a user
+func (ar *authRoute) PostEmailStart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			Email string `json:"email" validate:"required,email,max=255"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		pending, err := ar.accountQueryService.GetPendingEmailSignInByEmail(params.Email)
+		exist, err := pending.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if exist && !pending.CanResend(time.Now()) {
+			rw.ErrorTooManyRequests(
+				errors.New("email sign-in was sent recently"),
+			)
+			return
+		}
+
+		es, raw, code, err := ar.accountCommandService.StartEmailSignIn(params.Email)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		err = mailer.DefaultMailer().Send(r.Context(), &mailer.Message{
+			To:      es.Email,
+			Subject: "Your sign-in code is " + code,
+			Text: "Open the link below to sign in.\n\n" +
+				emailSignInURL(raw) + "\n\n" +
+				"Or enter the code " + code + ".\n\n" +
+				"The link and code expire at " + es.ExpiresAt.UTC().Format(time.RFC1123) + ".\n" +
+				"If you did not try to sign in, you can ignore this mail.\n",
+		})
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"email":      es.Email,
+			"expires_at": es.ExpiresAt,
+		})
+	}
+}
+
+// PostEmailFinish takes the token of the link, or the email and the code of the mail.
+// A user with the email is signed in, otherwise a sign-up ticket is set for PostOAuthSignUp.
+func (ar *authRoute) PostEmailFinish() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			Token string `json:"token" validate:"required_without=Code,max=255"`
+			Email string `json:"email" validate:"required_with=Code,omitempty,email,max=255"`
+			Code  string `json:"code" validate:"required_without=Token,omitempty,numeric,len=6"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		var es *account.EmailSignIn
+		code := ""
+		if params.Token != "" {
+			es, err = ar.accountQueryService.GetEmailSignInByToken(params.Token)
+		} else {
+			es, err = ar.accountQueryService.GetPendingEmailSignInByEmail(params.Email)
+			code = params.Code
+		}
+		exist, err := es.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			ar.recordEmailSignInFailure(w, r, params.Email)
+			rw.ErrorBadRequest(account.ErrEmailSignInInvalid)
+			return
+		}
+
+		err = ar.accountCommandService.FinishEmailSignIn(es, code)
+		if err != nil {
+			if errors.Is(err, account.ErrEmailSignInInvalid) || errors.Is(err, account.ErrEmailSignInExpired) {
+				ar.recordEmailSignInFailure(w, r, es.Email)
+			}
+			writeEmailSignInError(rw, err)
+			return
+		}
+
+		tokenManager := token.NewManager()
+
+		u, err := ar.accountQueryService.GetUserByEmail(es.Email)
+		exist, err = u.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		if !exist {
+			err = tokenManager.SetSignUpTicketCookie(w, &token.SignUpTicket{
+				Provider:      account.SOCIAL_ACCOUNT_PROVIDER_EMAIL,
+				SocialID:      strings.ToLower(es.Email),
+				Email:         es.Email,
+				EmailVerified: true,
+			})
+			if err != nil {
+				rw.Error(err)
+				return
+			}
+			rw.Write(map[string]any{
+				"registered": false,
+				"email":      es.Email,
+			})
+			return
+		}
+		if !ar.checkSignInStatus(w, r, rw, account.SOCIAL_ACCOUNT_PROVIDER_EMAIL, u) {
+			return
+		}
+		if !u.IsEmailVerified() {
+			// the account was signed up with this email by someone who never proved it
+			var sessionIds []string
+			u, sessionIds, err = ar.accountCommandService.ClaimUnverifiedUser(u)
+			if err != nil {
+				rw.Error(err)
+				return
+			}
+			ar.sessionChecker.Forget(sessionIds...)
+		}
+		if ar.requireMFA(w, rw, u) {
+			return
+		}
+
+		roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		p := token.NewProfile(
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			roles,
+		)
+		tokens, err := tokenManager.Issue(p, sessionClient(r), w, tokensInBody(r))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.recordLoginSuccess(w, r, account.SOCIAL_ACCOUNT_PROVIDER_EMAIL, u)
+
+		res := map[string]any{
+			"registered": true,
+			"profile":    p,
+		}
+		if tokens != nil {
+			res["tokens"] = tokens
+		}
+		rw.Write(res)
+	}
+}
+
+// recordEmailSignInFailure records a wrong or expired code or link against the user of the email,
+// the email is empty for an unknown link and a new email has no user
+func (ar *authRoute) recordEmailSignInFailure(w http.ResponseWriter, r *http.Request, email string) {
+	userId := ""
+	if email != "" {
+		u, err := ar.accountQueryService.GetUserByEmail(email)
+		exist, err := u.IsExist(err)
+		if err != nil {
+			logLoginEventError(r, err)
+		}
+		if exist {
+			userId = u.ID
+		}
+	}
+	ar.recordLoginFailure(w, r, account.SOCIAL_ACCOUNT_PROVIDER_EMAIL, userId, account.LOGIN_EVENT_REASON_INVALID_CODE)
+}
+
+func writeEmailSignInError(rw httpresponse.Writer, err error) {
+	switch {
+	case errors.Is(err, account.ErrEmailSignInInvalid):
+		rw.ErrorBadRequest(err)
+	case errors.Is(err, account.ErrEmailSignInExpired):
+		rw.ErrorUnprocessableEntity(err)
+	default:
+		rw.Error(err)
+	}
+}
+
+// emailSignInURL is the page of the client which posts the token back to PostEmailFinish
+func emailSignInURL(raw string) string {
+	return strings.TrimSuffix(env.ClientURL(), "/") + "/sign-in/email?token=" + url.QueryEscape(raw)
+}
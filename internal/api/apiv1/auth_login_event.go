@@ -0,0 +1,76 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/social"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rs/zerolog"
+)
+
+// recordLoginSuccess keeps the sign-in in the login history of u,
+// a failure is only logged so that it never blocks the sign-in
+func (ar *authRoute) recordLoginSuccess(w http.ResponseWriter, r *http.Request, provider string, u *account.User) {
+	e, ok := loginEvent(w, r, provider)
+	if !ok {
+		return
+	}
+	if err := ar.loginRecorder.RecordSuccess(u, e); err != nil {
+		logLoginEventError(r, err)
+	}
+}
+
+// recordLoginFailure keeps a failed sign-in, userId is empty when the login matched no user
+func (ar *authRoute) recordLoginFailure(w http.ResponseWriter, r *http.Request, provider, userId, reason string) {
+	e, ok := loginEvent(w, r, provider)
+	if !ok {
+		return
+	}
+	if err := ar.loginRecorder.RecordFailure(userId, reason, e); err != nil {
+		logLoginEventError(r, err)
+	}
+}
+
+// recordSocialFailure records a sign-in refused by an identity provider, err is the one of lib/social
+func (ar *authRoute) recordSocialFailure(w http.ResponseWriter, r *http.Request, provider string, err error) {
+	reason := account.LOGIN_EVENT_REASON_PROVIDER_ERROR
+	if errors.Is(err, social.ErrInvalidIDToken) ||
+		errors.Is(err, social.ErrProviderUnauthorized) ||
+		errors.Is(err, social.ErrProviderForbidden) ||
+		errors.Is(err, social.ErrEmailNotVerified) {
+		reason = account.LOGIN_EVENT_REASON_INVALID_TOKEN
+	}
+	ar.recordLoginFailure(w, r, provider, "", reason)
+}
+
+// checkSignInStatus is checkUserStatus recording the refused sign-in
+func (ar *authRoute) checkSignInStatus(w http.ResponseWriter, r *http.Request, rw httpresponse.Writer, provider string, u *account.User) bool {
+	if !checkUserStatus(rw, u) {
+		ar.recordLoginFailure(w, r, provider, u.ID, account.LOGIN_EVENT_REASON_ACCOUNT+u.Status)
+		return false
+	}
+	return true
+}
+
+// loginEvent is the attempt of the request, its device gets an id cookie when it has none
+func loginEvent(w http.ResponseWriter, r *http.Request, provider string) (*account.LoginEvent, bool) {
+	deviceId, err := token.NewManager().DeviceID(r, w)
+	if err != nil {
+		logLoginEventError(r, err)
+		return nil, false
+	}
+
+	e := &account.LoginEvent{Provider: provider}
+	e.SetDevice(r.UserAgent(), httplogger.ClientIP(r), token.DeviceFingerprint(deviceId))
+	return e, true
+}
+
+func logLoginEventError(r *http.Request, err error) {
+	httplogger.LoggerCtx(r.Context()).Add(func(e *zerolog.Event) {
+		e.Err(err)
+	})
+}
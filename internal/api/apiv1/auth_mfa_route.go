@@ -0,0 +1,161 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// PostMFAVerify completes a sign-in answered with a mfa pending token, the token is taken
+// from the body or from the cookie set by the oauth callback
+func (ar *authRoute) PostMFAVerify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		tokenManager := token.NewManager()
+
+		var params struct {
+			MFAToken string `json:"mfa_token" validate:"max=2048"`
+			mfaCodeParams
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		mfaToken := params.MFAToken
+		if mfaToken == "" {
+			mfaToken = tokenManager.MFAPendingCookie(r)
+		}
+		userId, err := tokenManager.DecodeMFAPendingToken(mfaToken)
+		if err != nil {
+			rw.ErrorUnauthorized(err)
+			return
+		}
+
+		m, err := ar.accountQueryService.GetUserMFAByUserId(userId)
+		exist, err := m.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			rw.ErrorBadRequest(account.ErrMFANotEnabled)
+			return
+		}
+
+		if params.Code != "" {
+			err = ar.accountCommandService.VerifyTOTP(m, params.Code)
+		} else {
+			err = ar.accountCommandService.VerifyMFARecoveryCode(m, params.RecoveryCode)
+		}
+		if err != nil {
+			if errors.Is(err, account.ErrMFACodeInvalid) {
+				ar.recordLoginFailure(w, r, account.LOGIN_EVENT_PROVIDER_MFA, userId, account.LOGIN_EVENT_REASON_INVALID_MFA)
+			}
+			writeMFAError(rw, err)
+			return
+		}
+
+		u, err := ar.accountQueryService.GetUserById(userId)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !ar.checkSignInStatus(w, r, rw, account.LOGIN_EVENT_PROVIDER_MFA, u) {
+			return
+		}
+
+		roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		p := token.NewProfile(
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			roles,
+		)
+		tokenManager.ResetMFAPendingCookie(w)
+		tokens, err := tokenManager.Issue(p, sessionClient(r), w, tokensInBody(r))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.recordLoginSuccess(w, r, account.LOGIN_EVENT_PROVIDER_MFA, u)
+
+		rw.Write(withTokens(p, tokens))
+	}
+}
+
+// requireMFA answers with a mfa pending token instead of the auth cookies when the user enabled 2FA,
+// it returns true when the response was written
+func (ar *authRoute) requireMFA(w http.ResponseWriter, rw httpresponse.Writer, u *account.User) bool {
+	mfaToken, err := ar.mfaPendingToken(w, u)
+	if err != nil {
+		rw.Error(err)
+		return true
+	}
+	if mfaToken == "" {
+		return false
+	}
+
+	rw.Write(map[string]any{
+		"mfa_required": true,
+		"mfa_token":    mfaToken,
+	})
+	return true
+}
+
+// mfaPendingToken sets the mfa pending cookie and returns its token,
+// it is empty when the user did not enable 2FA
+func (ar *authRoute) mfaPendingToken(w http.ResponseWriter, u *account.User) (string, error) {
+	m, err := ar.accountQueryService.GetUserMFAByUserId(u.ID)
+	exist, err := m.IsExist(err)
+	if err != nil {
+		return "", err
+	}
+	if !exist || !m.IsEnabled() {
+		return "", nil
+	}
+
+	tokenManager := token.NewManager()
+	mfaToken, err := tokenManager.GenerateMFAPendingToken(u.ID)
+	if err != nil {
+		return "", err
+	}
+	tokenManager.SetMFAPendingCookie(w, mfaToken)
+
+	return mfaToken, nil
+}
+
+func writeMFAError(rw httpresponse.Writer, err error) {
+	switch {
+	case errors.Is(err, account.ErrMFANotEnabled):
+		rw.ErrorBadRequest(err)
+	case errors.Is(err, account.ErrMFACodeInvalid):
+		rw.ErrorUnauthorized(err)
+	case errors.Is(err, account.ErrMFALocked):
+		rw.ErrorTooManyRequests(err)
+	default:
+		rw.Error(err)
+	}
+}
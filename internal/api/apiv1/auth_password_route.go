@@ -0,0 +1,302 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	commandmapper "github.com/rlawnsxo131/madre-server-v3/internal/application/mapper/command"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/mailer"
+	"github.com/rlawnsxo131/madre-server-v3/lib/password"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+	"github.com/rs/zerolog"
+)
+
+var (
+	errInvalidPasswordSignIn = errors.New("invalid username or password")
+)
+
+// PostPasswordSignUp creates a local account, it is only registered when PASSWORD_AUTH_ENABLED is set
+func (ar *authRoute) PostPasswordSignUp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			Username string `json:"username" validate:"required,max=20,min=1"`
+			Email    string `json:"email" validate:"required,email,max=255"`
+			Password string `json:"password" validate:"required"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		reason, err := ar.accountQueryService.GetUsernameUnavailableReason(params.Username, "")
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if reason != "" {
+			writeUsernameUnavailable(rw, reason)
+			return
+		}
+
+		exist, err := ar.accountQueryService.GetExistsUserByEmail(params.Email)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if exist {
+			rw.ErrorConflict(
+				errors.New("email is exist"),
+			)
+			return
+		}
+
+		err = password.Validate(params.Password, env.PasswordMinLength(), params.Username, params.Email)
+		if err != nil {
+			rw.ErrorUnprocessableEntity(err)
+			return
+		}
+
+		u := commandmapper.NewCreatePasswordAccountUser(params.Email, params.Username)
+		sa := commandmapper.NewCreateAccountSocialAccount(
+			strings.ToLower(params.Email),
+			account.SOCIAL_ACCOUNT_PROVIDER_PASSWORD,
+		)
+
+		ac, err := ar.accountCommandService.CreatePasswordAccount(u, sa, params.Password)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		// nobody is signed in until the email is verified,
+		// so that an account can not be taken with the email of someone else
+		ev, raw, err := ar.accountCommandService.RequestEmailChange(u, ac.Email)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		err = mailer.DefaultMailer().Send(r.Context(), &mailer.Message{
+			To:      ev.Email,
+			Subject: "Confirm your email",
+			Text: "Open the link below to finish the sign-up of " + ac.Username + ".\n\n" +
+				passwordSignUpVerificationURL(raw) + "\n\n" +
+				"The link expires at " + ev.ExpiresAt.UTC().Format(time.RFC1123) + ".\n",
+		})
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"email":      ev.Email,
+			"expires_at": ev.ExpiresAt,
+		})
+	}
+}
+
+// PostPasswordVerify confirms the email of a password sign-up, the user signs in afterwards
+func (ar *authRoute) PostPasswordVerify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			Token string `json:"token" validate:"required,max=255"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		ev, err := ar.accountQueryService.GetEmailVerificationByToken(params.Token)
+		exist, err := ev.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			rw.ErrorBadRequest(account.ErrEmailVerificationInvalid)
+			return
+		}
+
+		u, err := ar.accountQueryService.GetUserById(ev.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		// the link of an email change is confirmed by the signed in user only
+		if u.IsEmailVerified() || !strings.EqualFold(u.Email, ev.Email) {
+			rw.ErrorBadRequest(account.ErrEmailVerificationInvalid)
+			return
+		}
+
+		if err := ev.Check(u.ID, time.Now()); err != nil {
+			writeEmailVerificationError(rw, err)
+			return
+		}
+
+		err = ar.accountCommandService.ConfirmEmailChange(ev)
+		if err != nil {
+			writeEmailVerificationError(rw, err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"email": ev.Email,
+		})
+	}
+}
+
+// PostPasswordSignIn takes the username or the email as login,
+// the hash is upgraded when the argon2id parameters were raised since it was made
+func (ar *authRoute) PostPasswordSignIn() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			Login    string `json:"login" validate:"required,max=255"`
+			Password string `json:"password" validate:"required,max=128"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		u, c, exist, err := ar.findCredentialUser(params.Login)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			password.VerifyDummy(params.Password)
+			ar.recordLoginFailure(w, r, account.SOCIAL_ACCOUNT_PROVIDER_PASSWORD, "", account.LOGIN_EVENT_REASON_UNKNOWN_USER)
+			rw.ErrorUnauthorized(errInvalidPasswordSignIn)
+			return
+		}
+
+		match, err := password.Verify(params.Password, c.PasswordHash)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !match {
+			ar.recordLoginFailure(w, r, account.SOCIAL_ACCOUNT_PROVIDER_PASSWORD, u.ID, account.LOGIN_EVENT_REASON_INVALID_PASSWORD)
+			rw.ErrorUnauthorized(errInvalidPasswordSignIn)
+			return
+		}
+		if !u.IsEmailVerified() {
+			ar.recordLoginFailure(w, r, account.SOCIAL_ACCOUNT_PROVIDER_PASSWORD, u.ID, account.LOGIN_EVENT_REASON_EMAIL_UNVERIFIED)
+			rw.ErrorForbidden(account.ErrUserEmailUnverified)
+			return
+		}
+
+		if password.NeedsRehash(c.PasswordHash, password.DefaultParams()) {
+			// the user is signed in with the old hash anyway
+			if err := ar.accountCommandService.ChangePassword(c, params.Password); err != nil {
+				httplogger.LoggerCtx(r.Context()).Add(func(e *zerolog.Event) {
+					e.Err(err)
+				})
+			}
+		}
+		if !ar.checkSignInStatus(w, r, rw, account.SOCIAL_ACCOUNT_PROVIDER_PASSWORD, u) {
+			return
+		}
+		if ar.requireMFA(w, rw, u) {
+			return
+		}
+
+		roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		p := token.NewProfile(
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			roles,
+		)
+		tokens, err := token.NewManager().Issue(p, sessionClient(r), w, tokensInBody(r))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.recordLoginSuccess(w, r, account.SOCIAL_ACCOUNT_PROVIDER_PASSWORD, u)
+
+		rw.Write(withTokens(p, tokens))
+	}
+}
+
+// findCredentialUser returns false when the user or its credential does not exist
+func (ar *authRoute) findCredentialUser(login string) (*account.User, *account.Credential, bool, error) {
+	var u *account.User
+	var err error
+	if strings.Contains(login, "@") {
+		u, err = ar.accountQueryService.GetUserByEmail(login)
+	} else {
+		u, err = ar.accountQueryService.GetUserByUsername(login)
+	}
+	exist, err := u.IsExist(err)
+	if err != nil || !exist {
+		return nil, nil, false, err
+	}
+
+	c, err := ar.accountQueryService.GetCredentialByUserId(u.ID)
+	exist, err = c.IsExist(err)
+	if err != nil || !exist {
+		return nil, nil, false, err
+	}
+
+	return u, c, true, nil
+}
+
+// passwordSignUpVerificationURL is the page of the client which posts the token back to PostPasswordVerify
+func passwordSignUpVerificationURL(raw string) string {
+	return strings.TrimSuffix(env.ClientURL(), "/") + "/sign-up/verify?token=" + url.QueryEscape(raw)
+}
@@ -1,42 +1,75 @@
 package apiv1
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/pkg/errors"
 	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httplogger"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
 	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
 	commandmapper "github.com/rlawnsxo131/madre-server-v3/internal/application/mapper/command"
 	commandservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/command"
 	queryservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/query"
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/mailer"
 	"github.com/rlawnsxo131/madre-server-v3/lib/social"
 	"github.com/rlawnsxo131/madre-server-v3/lib/token"
 	"github.com/rlawnsxo131/madre-server-v3/utils"
+	"github.com/rs/zerolog"
 )
 
 type authRoute struct {
 	accountCommandService account.AccountCommandService
 	accountQueryService   account.AccountQueryService
+	sessionChecker        *commandservice.SessionChecker
+	loginRecorder         *commandservice.LoginRecorder
 }
 
 func NewAuthRoute(db rdb.Database) *authRoute {
 	return &authRoute{
 		commandservice.NewAccountCommandService(db),
 		queryservice.NewAccountQueryService(db),
+		commandservice.DefaultSessionChecker(db),
+		commandservice.NewLoginRecorder(db, commandservice.NewMailNewDeviceNotifier(mailer.DefaultMailer())),
 	}
 }
 
 func (ar *authRoute) Register(r chi.Router) {
 	r.Route("/auth", func(r chi.Router) {
 		r.Get("/", ar.Get())
-		r.Delete("/", ar.Delete())
+		r.With(httpmiddleware.RequireAuth).Delete("/", ar.Delete())
 		r.Post("/google/check", ar.PostGoogleCheck())
 		r.Post("/google/sign-in", ar.PostGoogleSignIn())
 		r.Post("/google/sign-up", ar.PostGoogleSignUp())
+		r.Get("/oidc/{provider}/nonce", ar.GetOIDCNonce())
+		r.Post("/oidc/{provider}/check", ar.PostOIDCCheck())
+		r.Post("/oidc/{provider}/sign-in", ar.PostOIDCSignIn())
+		r.Post("/oidc/{provider}/sign-up", ar.PostOIDCSignUp())
+		r.Post("/oauth/sign-up", ar.PostOAuthSignUp())
+		r.Post("/token/refresh", ar.PostTokenRefresh())
+		r.Get("/csrf", ar.GetCSRF())
+		r.Post("/email/start", ar.PostEmailStart())
+		r.Post("/email/finish", ar.PostEmailFinish())
+		r.Post("/mfa/verify", ar.PostMFAVerify())
+		r.Post("/webauthn/sign-in/begin", ar.PostWebAuthnSignInBegin())
+		r.Post("/webauthn/sign-in/finish", ar.PostWebAuthnSignInFinish())
+		if env.PasswordAuthEnabled() {
+			r.Post("/password/sign-up", ar.PostPasswordSignUp())
+			r.Post("/password/verify", ar.PostPasswordVerify())
+			r.Post("/password/sign-in", ar.PostPasswordSignIn())
+		}
+		ar.registerSessions(r)
+		r.Get("/{provider}/authorize", ar.GetOAuthAuthorize())
+		r.Get("/{provider}/callback", ar.GetOAuthCallback())
 	})
 }
 
@@ -49,17 +82,29 @@ func (ar *authRoute) Get() http.HandlerFunc {
 	}
 }
 
+// GetCSRF answers the csrf token for the clients which can not read its cookie,
+// it is sent back in the X-CSRF-Token header of the state-changing requests
+func (ar *authRoute) GetCSRF() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		rw.Write(map[string]string{
+			"csrf_token": token.CSRFTokenCtx(r.Context()),
+		})
+	}
+}
+
 func (ar *authRoute) Delete() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rw := httpresponse.NewWriter(w, r)
 		p := token.ProfileCtx(r.Context())
-		if p == nil {
-			rw.ErrorUnauthorized(
-				errors.New("not found token profile"),
-			)
+
+		err := token.NewManager().SignOut(p, w)
+		if err != nil {
+			rw.Error(err)
 			return
 		}
-		token.NewManager().ResetCookies(w)
+		ar.sessionChecker.Forget(p.SessionID)
 
 		rw.Write(struct{}{})
 	}
@@ -70,7 +115,8 @@ func (ar *authRoute) PostGoogleCheck() http.HandlerFunc {
 		rw := httpresponse.NewWriter(w, r)
 
 		var params struct {
-			AccessToken string `json:"access_token" validate:"required,min=50"`
+			IDToken     string `json:"id_token" validate:"required_without=AccessToken"`
+			AccessToken string `json:"access_token" validate:"required_without=IDToken,omitempty,min=50"`
 		}
 		err := json.NewDecoder(r.Body).Decode(&params)
 		if err != nil {
@@ -88,33 +134,56 @@ func (ar *authRoute) PostGoogleCheck() http.HandlerFunc {
 			return
 		}
 
-		ggp, err := social.NewGooglePeopleAPI(params.AccessToken).Do()
+		ggp, ok := ar.googleProfile(w, rw, r, params.IDToken, params.AccessToken)
+		if !ok {
+			return
+		}
+
+		ar.check(rw, account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE, ggp)
+	}
+}
+
+func (ar *authRoute) PostGoogleSignIn() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			IDToken     string `json:"id_token" validate:"required_without=AccessToken"`
+			AccessToken string `json:"access_token" validate:"required_without=IDToken,omitempty,min=50"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
 		if err != nil {
-			rw.Error(err)
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
 			return
 		}
 
-		exist, err := ar.accountQueryService.GetExistsSocialAccountBySocialIdAndProvider(
-			ggp.SocialID,
-			account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE,
-		)
+		err = validator.New().Struct(&params)
 		if err != nil {
-			rw.Error(err)
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
 			return
 		}
 
-		rw.Write(map[string]bool{
-			"exist": exist,
-		})
+		ggp, ok := ar.googleProfile(w, rw, r, params.IDToken, params.AccessToken)
+		if !ok {
+			return
+		}
+
+		ar.signIn(w, r, rw, account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE, ggp)
 	}
 }
 
-func (ar *authRoute) PostGoogleSignIn() http.HandlerFunc {
+func (ar *authRoute) PostGoogleSignUp() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rw := httpresponse.NewWriter(w, r)
 
 		var params struct {
-			AccessToken string `json:"access_token" validate:"required,min=50"`
+			IDToken     string `json:"id_token" validate:"required_without=AccessToken"`
+			AccessToken string `json:"access_token" validate:"required_without=IDToken,omitempty,min=50"`
+			Username    string `json:"username" validate:"required,max=20,min=1"`
 		}
 		err := json.NewDecoder(r.Body).Decode(&params)
 		if err != nil {
@@ -132,64 +201,122 @@ func (ar *authRoute) PostGoogleSignIn() http.HandlerFunc {
 			return
 		}
 
-		ggp, err := social.NewGooglePeopleAPI(params.AccessToken).Do()
+		ggp, ok := ar.googleProfile(w, rw, r, params.IDToken, params.AccessToken)
+		if !ok {
+			return
+		}
+
+		ar.signUp(w, r, rw, account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE, ggp, params.Username)
+	}
+}
+
+// GetOIDCNonce issues the nonce the client puts in its id token request,
+// it is kept in a signed short-lived cookie and checked by the id token endpoints
+func (ar *authRoute) GetOIDCNonce() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		op, ok := social.OIDCProvider(chi.URLParam(r, "provider"))
+		if !ok {
+			rw.ErrorNotFound(
+				errors.New("not found oidc provider"),
+			)
+			return
+		}
+
+		nonce, err := social.RandomString(32)
 		if err != nil {
 			rw.Error(err)
 			return
 		}
 
-		sa, err := ar.accountQueryService.GetSocialAccountBySocialIdAndProvider(
-			ggp.SocialID,
-			account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE,
-		)
-		exist, err := sa.IsExist(err)
+		err = token.NewManager().SetOIDCNonceCookie(w, &token.OIDCNonce{
+			Provider: op.Name(),
+			Nonce:    nonce,
+		})
 		if err != nil {
 			rw.Error(err)
 			return
 		}
-		if !exist {
+
+		rw.Write(map[string]string{
+			"nonce": nonce,
+		})
+	}
+}
+
+func (ar *authRoute) PostOIDCCheck() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			IDToken string `json:"id_token" validate:"required"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
 			rw.ErrorBadRequest(
-				errors.New("not found socialaccount"),
+				errors.Wrap(err, "params validate error"),
 			)
 			return
 		}
 
-		u, err := ar.accountQueryService.GetUserById(sa.UserID)
-		exist, err = u.IsExist(err)
+		provider, op, ok := ar.verifyOIDCIDToken(w, rw, r, params.IDToken)
+		if !ok {
+			return
+		}
+
+		ar.check(rw, provider, op)
+	}
+}
+
+func (ar *authRoute) PostOIDCSignIn() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			IDToken string `json:"id_token" validate:"required"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
 		if err != nil {
-			rw.Error(err)
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
 			return
 		}
-		if !exist {
+
+		err = validator.New().Struct(&params)
+		if err != nil {
 			rw.ErrorBadRequest(
-				errors.New("not found user"),
+				errors.Wrap(err, "params validate error"),
 			)
 			return
 		}
 
-		p := token.NewProfile(
-			u.ID,
-			u.Username,
-			utils.NormalizeNullString(u.PhotoUrl),
-		)
-		tokenManager := token.NewManager()
-		err = tokenManager.GenerateAndSetCookies(p, w)
-		if err != nil {
-			rw.Error(err)
+		provider, op, ok := ar.verifyOIDCIDToken(w, rw, r, params.IDToken)
+		if !ok {
 			return
 		}
+		token.NewManager().ResetOIDCNonceCookie(w)
 
-		rw.Write(p)
+		ar.signIn(w, r, rw, provider, op)
 	}
 }
 
-func (ar *authRoute) PostGoogleSignUp() http.HandlerFunc {
+func (ar *authRoute) PostOIDCSignUp() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rw := httpresponse.NewWriter(w, r)
 
 		var params struct {
-			AccessToken string `json:"access_token" validate:"required,min=50"`
-			Username    string `json:"username" validate:"required,max=20,min=1"`
+			IDToken  string `json:"id_token" validate:"required"`
+			Username string `json:"username" validate:"required,max=20,min=1"`
 		}
 		err := json.NewDecoder(r.Body).Decode(&params)
 		if err != nil {
@@ -207,79 +334,537 @@ func (ar *authRoute) PostGoogleSignUp() http.HandlerFunc {
 			return
 		}
 
-		ggp, err := social.NewGooglePeopleAPI(params.AccessToken).Do()
+		provider, op, ok := ar.verifyOIDCIDToken(w, rw, r, params.IDToken)
+		if !ok {
+			return
+		}
+		token.NewManager().ResetOIDCNonceCookie(w)
+
+		ar.signUp(w, r, rw, provider, op, params.Username)
+	}
+}
+
+// GetOAuthAuthorize starts the authorization code flow,
+// the state, nonce and PKCE code verifier are kept in a signed short-lived cookie
+func (ar *authRoute) GetOAuthAuthorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		provider, ok := social.OAuthProviderByName(chi.URLParam(r, "provider"))
+		if !ok {
+			rw.ErrorNotFound(
+				errors.New("not found oauth provider"),
+			)
+			return
+		}
+
+		state, err := social.RandomString(32)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		nonce, err := social.RandomString(32)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		codeVerifier, codeChallenge, err := social.NewPKCE()
 		if err != nil {
 			rw.Error(err)
 			return
 		}
 
-		u := commandmapper.NewCreateAccountUser(
-			ggp.Email,
-			ggp.DisplayName,
-			params.Username,
-			ggp.PhotoUrl,
+		authCodeURL, err := provider.AuthCodeURL(
+			r.Context(),
+			state,
+			codeChallenge,
+			nonce,
+			oauthCallbackURL(provider),
 		)
-		valid, err := u.ValidateUsername()
 		if err != nil {
 			rw.Error(err)
 			return
 		}
-		if !valid {
-			rw.ErrorBadRequest(
-				errors.New("username validation error"),
-			)
-		}
 
-		exist, err := ar.accountQueryService.GetExistsUserByUsername(params.Username)
+		err = token.NewManager().SetOAuthStateCookie(w, &token.OAuthState{
+			Provider:     provider.Name(),
+			State:        state,
+			CodeVerifier: codeVerifier,
+			Nonce:        nonce,
+			RedirectPath: safeRedirectPath(r.URL.Query().Get("redirect")),
+		})
 		if err != nil {
 			rw.Error(err)
 			return
 		}
-		if exist {
-			rw.ErrorConflict(
-				errors.Wrap(err, "username is exist"),
-			)
+
+		http.Redirect(w, r, authCodeURL, http.StatusFound)
+	}
+}
+
+// GetOAuthCallback always redirects to the client,
+// with the token cookies when the social account exists
+// or with a sign-up ticket cookie to be completed by PostOAuthSignUp
+func (ar *authRoute) GetOAuthCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokenManager := token.NewManager()
+		q := r.URL.Query()
+
+		s, err := tokenManager.OAuthStateCookie(r)
+		tokenManager.ResetOAuthStateCookie(w)
+		if err != nil {
+			redirectToClientError(w, r, "invalid_state", err)
 			return
 		}
 
-		exist, err = ar.accountQueryService.GetExistsSocialAccountBySocialIdAndProvider(
-			ggp.SocialID,
-			account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE,
+		provider, ok := social.OAuthProviderByName(chi.URLParam(r, "provider"))
+		if !ok || provider.Name() != s.Provider {
+			redirectToClientError(w, r, "invalid_state", errors.New("oauth provider mismatch"))
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			redirectToClientError(w, r, "access_denied", errors.New("oauth provider error "+e))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(s.State)) != 1 {
+			redirectToClientError(w, r, "invalid_state", errors.New("oauth state mismatch"))
+			return
+		}
+
+		sp, err := provider.Authenticate(
+			r.Context(),
+			q.Get("code"),
+			s.CodeVerifier,
+			s.Nonce,
+			oauthCallbackURL(provider),
 		)
 		if err != nil {
-			rw.Error(err)
+			ar.recordSocialFailure(w, r, provider.Name(), err)
+			redirectToClientError(w, r, "provider_error", err)
 			return
 		}
-		if exist {
-			rw.ErrorUnprocessableEntity(
-				errors.Wrap(err, "socialaccount is exist"),
-			)
+
+		u, exist, err := ar.findSocialAccountUser(provider.Name(), sp.SocialID)
+		if err != nil {
+			redirectToClientError(w, r, "server_error", err)
 			return
 		}
 
-		sa := commandmapper.NewCreateAccountSocialAccount(
-			ggp.SocialID,
-			account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE,
-		)
+		if !exist {
+			err = tokenManager.SetSignUpTicketCookie(w, &token.SignUpTicket{
+				Provider:      provider.Name(),
+				SocialID:      sp.SocialID,
+				Email:         sp.Email,
+				EmailVerified: sp.EmailVerified,
+				PhotoUrl:      sp.PhotoUrl,
+				DisplayName:   sp.DisplayName,
+			})
+			if err != nil {
+				redirectToClientError(w, r, "server_error", err)
+				return
+			}
+			redirectToClient(w, r, "/sign-up", url.Values{
+				"provider": []string{strings.ToLower(provider.Name())},
+			})
+			return
+		}
+		u = ar.syncProfile(r, u, sp)
+		if err := u.CheckStatus(time.Now()); err != nil {
+			ar.recordLoginFailure(w, r, provider.Name(), u.ID, account.LOGIN_EVENT_REASON_ACCOUNT+u.Status)
+			redirectToClientError(w, r, "account_"+u.Status, err)
+			return
+		}
 
-		ac, err := ar.accountCommandService.CreateAccount(u, sa)
+		mfaToken, err := ar.mfaPendingToken(w, u)
 		if err != nil {
-			rw.Error(err)
+			redirectToClientError(w, r, "server_error", err)
+			return
+		}
+		if mfaToken != "" {
+			redirectToClient(w, r, "/sign-in/mfa", url.Values{
+				"redirect_path": []string{s.RedirectPath},
+			})
+			return
+		}
+
+		roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+		if err != nil {
+			redirectToClientError(w, r, "server_error", err)
 			return
 		}
 
 		p := token.NewProfile(
-			ac.UserID,
-			ac.Username,
-			ac.PhotoUrl,
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			roles,
 		)
-		tokenManager := token.NewManager()
-		err = tokenManager.GenerateAndSetCookies(p, w)
+		err = tokenManager.GenerateAndSetCookies(p, sessionClient(r), w)
 		if err != nil {
-			rw.Error(err)
+			redirectToClientError(w, r, "server_error", err)
 			return
 		}
+		ar.recordLoginSuccess(w, r, provider.Name(), u)
 
-		rw.Write(p)
+		redirectToClient(w, r, s.RedirectPath, nil)
+	}
+}
+
+// PostOAuthSignUp completes the sign-up of a user who came back from GetOAuthCallback
+// or PostEmailFinish without an account
+func (ar *authRoute) PostOAuthSignUp() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			Username string `json:"username" validate:"required,max=20,min=1"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		t, err := token.NewManager().SignUpTicketCookie(r)
+		if err != nil {
+			rw.ErrorUnauthorized(err)
+			return
+		}
+		ar.signUp(w, r, rw, t.Provider, &social.Profile{
+			SocialID:      t.SocialID,
+			Email:         t.Email,
+			EmailVerified: t.EmailVerified,
+			PhotoUrl:      t.PhotoUrl,
+			DisplayName:   t.DisplayName,
+		}, params.Username)
+	}
+}
+
+// googleProfile verifies the id token locally against the google jwks,
+// the People API is only called when the client sends an access token instead.
+// It writes the error response itself, records the failed sign-in and returns false when the profile is not usable.
+func (ar *authRoute) googleProfile(w http.ResponseWriter, rw httpresponse.Writer, r *http.Request, idToken, accessToken string) (*social.Profile, bool) {
+	var ggp *social.Profile
+	var err error
+
+	if idToken != "" {
+		ggp, err = social.GoogleIDTokenVerifier().Verify(r.Context(), idToken, "")
+	} else {
+		ggp, err = social.GooglePeopleAPI().Do(r.Context(), accessToken)
+	}
+
+	if err != nil {
+		ar.recordSocialFailure(w, r, account.SOCIAL_ACCOUNT_PROVIDER_GOOGLE, err)
+		writeSocialError(rw, err)
+		return nil, false
+	}
+
+	return ggp, true
+}
+
+// verifyOIDCIDToken checks the token against the nonce issued by GetOIDCNonce,
+// it writes the error response itself, records the failed sign-in and returns false when the token is not usable
+func (ar *authRoute) verifyOIDCIDToken(w http.ResponseWriter, rw httpresponse.Writer, r *http.Request, idToken string) (string, *social.Profile, bool) {
+	op, ok := social.OIDCProvider(chi.URLParam(r, "provider"))
+	if !ok {
+		rw.ErrorNotFound(
+			errors.New("not found oidc provider"),
+		)
+		return "", nil, false
+	}
+
+	n, err := token.NewManager().OIDCNonceCookie(r)
+	if err != nil {
+		ar.recordLoginFailure(w, r, op.Name(), "", account.LOGIN_EVENT_REASON_INVALID_TOKEN)
+		rw.ErrorUnauthorized(err)
+		return "", nil, false
+	}
+	if n.Provider != op.Name() {
+		ar.recordLoginFailure(w, r, op.Name(), "", account.LOGIN_EVENT_REASON_INVALID_TOKEN)
+		rw.ErrorUnauthorized(
+			errors.New("oidc nonce provider mismatch"),
+		)
+		return "", nil, false
+	}
+
+	sp, err := op.VerifyIDToken(r.Context(), idToken, n.Nonce)
+	if err != nil {
+		ar.recordSocialFailure(w, r, op.Name(), err)
+		writeSocialError(rw, err)
+		return "", nil, false
+	}
+
+	return op.Name(), sp, true
+}
+
+func (ar *authRoute) check(rw httpresponse.Writer, provider string, sp *social.Profile) {
+	exist, err := ar.accountQueryService.GetExistsSocialAccountBySocialIdAndProvider(
+		sp.SocialID,
+		provider,
+	)
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+
+	rw.Write(map[string]bool{
+		"exist": exist,
+	})
+}
+
+func (ar *authRoute) signIn(w http.ResponseWriter, r *http.Request, rw httpresponse.Writer, provider string, sp *social.Profile) {
+	u, exist, err := ar.findSocialAccountUser(provider, sp.SocialID)
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+	if !exist {
+		ar.recordLoginFailure(w, r, provider, "", account.LOGIN_EVENT_REASON_UNKNOWN_ACCOUNT)
+		rw.ErrorBadRequest(
+			errors.New("not found socialaccount"),
+		)
+		return
+	}
+	u = ar.syncProfile(r, u, sp)
+	if !ar.checkSignInStatus(w, r, rw, provider, u) {
+		return
+	}
+	if ar.requireMFA(w, rw, u) {
+		return
+	}
+
+	roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+
+	p := token.NewProfile(
+		u.ID,
+		u.Username,
+		utils.NormalizeNullString(u.PhotoUrl),
+		roles,
+	)
+	tokenManager := token.NewManager()
+	tokens, err := tokenManager.Issue(p, sessionClient(r), w, tokensInBody(r))
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+	ar.recordLoginSuccess(w, r, provider, u)
+
+	rw.Write(withTokens(p, tokens))
+}
+
+// findSocialAccountUser returns false when the social account or its user does not exist
+func (ar *authRoute) findSocialAccountUser(provider, socialId string) (*account.User, bool, error) {
+	sa, err := ar.accountQueryService.GetSocialAccountBySocialIdAndProvider(
+		socialId,
+		provider,
+	)
+	exist, err := sa.IsExist(err)
+	if err != nil || !exist {
+		return nil, false, err
+	}
+
+	u, err := ar.accountQueryService.GetUserById(sa.UserID)
+	exist, err = u.IsExist(err)
+	if err != nil || !exist {
+		return nil, false, err
+	}
+
+	return u, true, nil
+}
+
+// syncProfile refreshes the user from the provider profile on every sign-in,
+// a failure is only logged so that it never blocks the sign-in
+func (ar *authRoute) syncProfile(r *http.Request, u *account.User, sp *social.Profile) *account.User {
+	synced, err := ar.accountCommandService.SyncUserProfile(u, sp.DisplayName, sp.PhotoUrl)
+	if err != nil {
+		httplogger.LoggerCtx(r.Context()).Add(func(e *zerolog.Event) {
+			e.Err(err)
+		})
+		return u
+	}
+	return synced
+}
+
+func (ar *authRoute) signUp(w http.ResponseWriter, r *http.Request, rw httpresponse.Writer, provider string, sp *social.Profile, username string) {
+	// the email becomes the identity of the user, so the provider must have verified it
+	if sp.Email == "" || !sp.EmailVerified {
+		rw.ErrorUnprocessableEntity(
+			errors.New("social profile has no verified email"),
+		)
+		return
+	}
+
+	u := commandmapper.NewCreateAccountUser(
+		sp.Email,
+		sp.DisplayName,
+		username,
+		sp.PhotoUrl,
+	)
+	reason, err := ar.accountQueryService.GetUsernameUnavailableReason(username, "")
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+	if reason != "" {
+		writeUsernameUnavailable(rw, reason)
+		return
+	}
+
+	exist, err := ar.accountQueryService.GetExistsSocialAccountBySocialIdAndProvider(
+		sp.SocialID,
+		provider,
+	)
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+	if exist {
+		rw.ErrorUnprocessableEntity(
+			errors.New("socialaccount is exist"),
+		)
+		return
 	}
+
+	sa := commandmapper.NewCreateAccountSocialAccount(
+		sp.SocialID,
+		provider,
+	)
+
+	ac, err := ar.accountCommandService.CreateAccount(u, sa)
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+	// the sign-up ticket of PostOAuthSignUp must not be replayed once its account exists
+	tokenManager := token.NewManager()
+	tokenManager.ResetSignUpTicketCookie(w)
+
+	p := token.NewProfile(
+		ac.UserID,
+		ac.Username,
+		ac.PhotoUrl,
+		nil,
+	)
+	tokens, err := tokenManager.Issue(p, sessionClient(r), w, tokensInBody(r))
+	if err != nil {
+		rw.Error(err)
+		return
+	}
+	ar.recordLoginSuccess(w, r, provider, &account.User{ID: ac.UserID, Username: ac.Username})
+
+	rw.Write(withTokens(p, tokens))
+}
+
+func writeUsernameUnavailable(rw httpresponse.Writer, reason string) {
+	err := errors.New("username is " + reason)
+	switch reason {
+	case account.USERNAME_UNAVAILABLE_INVALID:
+		rw.ErrorBadRequest(err)
+	case account.USERNAME_UNAVAILABLE_RESERVED:
+		rw.ErrorUnprocessableEntity(err)
+	default:
+		rw.ErrorConflict(err)
+	}
+}
+
+// writeSocialError maps the errors of the providers to http responses
+func writeSocialError(rw httpresponse.Writer, err error) {
+	switch {
+	case errors.Is(err, social.ErrInvalidIDToken),
+		errors.Is(err, social.ErrProviderUnauthorized):
+		rw.ErrorUnauthorized(err)
+	case errors.Is(err, social.ErrProviderForbidden):
+		rw.ErrorForbidden(err)
+	case errors.Is(err, social.ErrEmailNotVerified):
+		rw.ErrorUnprocessableEntity(err)
+	case errors.Is(err, social.ErrProviderRateLimited):
+		rw.ErrorTooManyRequests(err)
+	case errors.Is(err, social.ErrProviderUnavailable),
+		errors.Is(err, social.ErrInvalidProfile):
+		rw.ErrorBadGateway(err)
+	default:
+		rw.Error(err)
+	}
+}
+
+func oauthCallbackURL(provider social.OAuthProvider) string {
+	return env.OAuthCallbackBaseURL() + "/" + strings.ToLower(provider.Name()) + "/callback"
+}
+
+// safeRedirectPath only allows paths of the client, so the flow can not be used as an open redirect
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+func redirectToClient(w http.ResponseWriter, r *http.Request, path string, query url.Values) {
+	target := env.ClientURL() + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// checkUserStatus returns false when the response was written, a suspended or banned user can not sign in
+func checkUserStatus(rw httpresponse.Writer, u *account.User) bool {
+	err := u.CheckStatus(time.Now())
+	if err != nil {
+		rw.ErrorForbidden(err)
+		return false
+	}
+	return true
+}
+
+// sessionClient is the device a new session is signed in from
+func sessionClient(r *http.Request) token.SessionClient {
+	return token.SessionClient{
+		UserAgent: r.UserAgent(),
+		IP:        httplogger.ClientIP(r),
+	}
+}
+
+// tokensInBody tells if the client keeps the tokens itself instead of the cookies, it asked
+// for token_delivery=body or authenticated the request with the Authorization header
+func tokensInBody(r *http.Request) bool {
+	if r.URL.Query().Get(token.TOKEN_DELIVERY) == token.TOKEN_DELIVERY_BODY {
+		return true
+	}
+	p := token.ProfileCtx(r.Context())
+	return p != nil && p.Bearer
+}
+
+// withTokens answers the profile with the tokens when they were issued in the body
+func withTokens(p any, tokens *token.Tokens) any {
+	if tokens == nil {
+		return p
+	}
+	return map[string]any{
+		"profile": p,
+		"tokens":  tokens,
+	}
+}
+
+func redirectToClientError(w http.ResponseWriter, r *http.Request, code string, err error) {
+	httplogger.LoggerCtx(r.Context()).Add(func(e *zerolog.Event) {
+		e.Err(err)
+	})
+	redirectToClient(w, r, "/sign-in", url.Values{
+		"error": []string{code},
+	})
 }
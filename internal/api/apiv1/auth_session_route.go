@@ -0,0 +1,109 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+func (ar *authRoute) registerSessions(r chi.Router) {
+	r.Route("/sessions", func(r chi.Router) {
+		r.Use(httpmiddleware.RequireAuth)
+		r.Get("/", ar.GetSessions())
+		r.Delete("/", ar.DeleteOtherSessions())
+		r.Delete("/{id}", ar.DeleteSession())
+	})
+}
+
+// GetSessions lists the devices the user is signed in on, the one of the request is current
+func (ar *authRoute) GetSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		ss, err := ar.accountQueryService.GetActiveSessionsByUserId(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		res := make([]map[string]any, 0, len(ss))
+		for _, s := range ss {
+			res = append(res, session(s, p.SessionID))
+		}
+
+		rw.Write(res)
+	}
+}
+
+// DeleteSession signs a device out, revoking the current session signs out like DELETE /auth
+func (ar *authRoute) DeleteSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		id := chi.URLParam(r, "id")
+		err := validator.New().Var(id, "required,uuid")
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		revoked, err := ar.accountCommandService.RevokeSession(p.UserID, id)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !revoked {
+			rw.ErrorNotFound(
+				errors.New("not found session"),
+			)
+			return
+		}
+		ar.sessionChecker.Forget(id)
+		if id == p.SessionID {
+			token.NewManager().ResetCookies(w)
+		}
+
+		rw.Write(struct{}{})
+	}
+}
+
+// DeleteOtherSessions signs out every device but the one of the request
+func (ar *authRoute) DeleteOtherSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		ids, err := ar.accountCommandService.RevokeOtherSessions(p.UserID, p.SessionID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.sessionChecker.Forget(ids...)
+
+		rw.Write(map[string]any{
+			"revoked": len(ids),
+		})
+	}
+}
+
+func session(s *account.Session, currentSessionId string) map[string]any {
+	return map[string]any{
+		"id":           s.ID,
+		"user_agent":   utils.NormalizeNullString(s.UserAgent),
+		"ip":           utils.NormalizeNullString(s.IP),
+		"created_at":   s.CreatedAt,
+		"last_seen_at": s.LastSeenAt,
+		"current":      s.ID == currentSessionId,
+	}
+}
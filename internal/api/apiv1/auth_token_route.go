@@ -0,0 +1,61 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+// PostTokenRefresh is the refresh of the clients without cookies, the refresh token is taken from the body
+// and the next tokens of its session are answered in the body
+func (ar *authRoute) PostTokenRefresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params struct {
+			RefreshToken string `json:"refresh_token" validate:"required,max=4096"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		// the status of the user is checked before the session is rotated
+		p, tokens, err := token.NewManager().RefreshTokens(params.RefreshToken)
+		if err != nil {
+			if errors.Is(err, account.ErrUserSuspended) || errors.Is(err, account.ErrUserBanned) {
+				rw.ErrorForbidden(err)
+				return
+			}
+			if errors.Is(err, token.ErrSessionInvalid) {
+				rw.ErrorUnauthorized(err)
+				return
+			}
+			// the client refreshed twice at once, it keeps the tokens of the other request
+			if errors.Is(err, token.ErrRefreshTokenRotated) {
+				rw.ErrorConflict(err)
+				return
+			}
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(withTokens(p, tokens))
+	}
+}
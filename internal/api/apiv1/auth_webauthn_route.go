@@ -0,0 +1,183 @@
+package apiv1
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/lib/webauthn"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// PostWebAuthnSignInBegin answers with the options of navigator.credentials.get, no credential is listed
+// so that the browser offers the passkeys of the site. Anyone can call it,
+// so the challenge is kept in a signed short-lived cookie instead of a row.
+func (ar *authRoute) PostWebAuthnSignInBegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		err = token.NewManager().SetWebAuthnSignInCookie(w, &token.WebAuthnSignIn{
+			Challenge: challenge,
+			ExpiresAt: time.Now().Add(account.WEBAUTHN_CHALLENGE_TTL),
+		})
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(
+			webauthn.DefaultRelyingParty().BeginLogin(challenge, nil),
+		)
+	}
+}
+
+// PostWebAuthnSignInFinish signs in with a passkey, the user verification is required by the ceremony
+// so the passkey already is a second factor and the totp is not asked
+func (ar *authRoute) PostWebAuthnSignInFinish() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		var params webauthn.AssertionResponse
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		challenge, err := params.Challenge()
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+		err = ar.consumeWebAuthnSignIn(w, r, challenge)
+		if err != nil {
+			writeWebAuthnChallengeError(rw, err)
+			return
+		}
+
+		rawId, err := webauthn.DecodeBase64URL(params.RawID)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "rawId decode error"),
+			)
+			return
+		}
+		wc, err := ar.accountQueryService.GetWebAuthnCredentialByCredentialId(webauthn.EncodeBase64URL(rawId))
+		exist, err := wc.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			rw.ErrorUnauthorized(
+				errors.New("not found webauthn credential"),
+			)
+			return
+		}
+
+		assertion, err := webauthn.DefaultRelyingParty().FinishLogin(challenge, &params, &webauthn.Credential{
+			ID:        wc.CredentialID,
+			PublicKey: wc.PublicKey,
+			SignCount: uint32(wc.SignCount),
+		})
+		if err != nil {
+			ar.recordLoginFailure(w, r, account.LOGIN_EVENT_PROVIDER_WEBAUTHN, wc.UserID, account.LOGIN_EVENT_REASON_INVALID_WEBAUTHN)
+			rw.ErrorUnauthorized(err)
+			return
+		}
+		if assertion.UserHandle != "" && assertion.UserHandle != wc.UserID {
+			ar.recordLoginFailure(w, r, account.LOGIN_EVENT_PROVIDER_WEBAUTHN, wc.UserID, account.LOGIN_EVENT_REASON_INVALID_WEBAUTHN)
+			rw.ErrorUnauthorized(
+				errors.New("webauthn user handle mismatch"),
+			)
+			return
+		}
+
+		err = ar.accountCommandService.UseWebAuthnCredential(wc, assertion.SignCount)
+		if err != nil {
+			if errors.Is(err, account.ErrWebAuthnSignCount) {
+				ar.recordLoginFailure(w, r, account.LOGIN_EVENT_PROVIDER_WEBAUTHN, wc.UserID, account.LOGIN_EVENT_REASON_INVALID_WEBAUTHN)
+				rw.ErrorUnauthorized(err)
+				return
+			}
+			rw.Error(err)
+			return
+		}
+
+		u, err := ar.accountQueryService.GetUserById(wc.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !ar.checkSignInStatus(w, r, rw, account.LOGIN_EVENT_PROVIDER_WEBAUTHN, u) {
+			return
+		}
+
+		roles, err := ar.accountQueryService.GetRolesByUserId(u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		p := token.NewProfile(
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			roles,
+		)
+		tokens, err := token.NewManager().Issue(p, sessionClient(r), w, tokensInBody(r))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		ar.recordLoginSuccess(w, r, account.LOGIN_EVENT_PROVIDER_WEBAUTHN, u)
+
+		rw.Write(withTokens(p, tokens))
+	}
+}
+
+// consumeWebAuthnSignIn checks the challenge against the cookie of PostWebAuthnSignInBegin,
+// the cookie is reset and the challenge is recorded as used so that a ceremony is finished at most once
+func (ar *authRoute) consumeWebAuthnSignIn(w http.ResponseWriter, r *http.Request, challenge string) error {
+	tokenManager := token.NewManager()
+	s, err := tokenManager.WebAuthnSignInCookie(r)
+	tokenManager.ResetWebAuthnSignInCookie(w)
+	if err != nil {
+		return account.ErrWebAuthnChallengeInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(s.Challenge), []byte(challenge)) != 1 {
+		return account.ErrWebAuthnChallengeInvalid
+	}
+
+	wc := &account.WebAuthnChallenge{
+		Challenge: s.Challenge,
+		Ceremony:  account.WEBAUTHN_CEREMONY_AUTHENTICATION,
+		ExpiresAt: s.ExpiresAt,
+	}
+	if err := wc.Check(account.WEBAUTHN_CEREMONY_AUTHENTICATION, "", time.Now()); err != nil {
+		return err
+	}
+	return ar.accountCommandService.UseWebAuthnSignInChallenge(s.Challenge, s.ExpiresAt)
+}
@@ -0,0 +1,112 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	querymapper "github.com/rlawnsxo131/madre-server-v3/internal/application/mapper/query"
+	commandservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/command"
+	queryservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/query"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// dataRoute serves the data of the signed-in user, the personal access tokens can use it with their scopes
+type dataRoute struct {
+	dataCommandService data.DataCommandService
+	dataQueryService   data.DataQueryService
+}
+
+func NewDataRoute(db rdb.Database) *dataRoute {
+	return &dataRoute{
+		commandservice.NewDataCommandService(db),
+		queryservice.NewDataQueryService(db),
+	}
+}
+
+func (dr *dataRoute) Register(r chi.Router) {
+	r.Route("/data", func(r chi.Router) {
+		r.With(httpmiddleware.RequireScope(account.SCOPE_DATA_READ)).Get("/", dr.Get())
+		r.With(httpmiddleware.RequireScope(account.SCOPE_DATA_WRITE)).Post("/", dr.Post())
+	})
+}
+
+// Get lists the data of the user, private ones included, newest first, paginated by page and size
+func (dr *dataRoute) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		tp := token.ProfileCtx(r.Context())
+
+		page, err := queryInt(r, "page")
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+		size, err := queryInt(r, "size")
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+		p := querymapper.NewPage(page, size)
+
+		ds, total, err := dr.dataQueryService.GetDataByUserId(tp.UserID, p.Limit(), p.Offset())
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(querymapper.NewOwnDataList(ds, p, total))
+	}
+}
+
+// Post adds a data of the user, it is private unless is_public is set
+func (dr *dataRoute) Post() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		tp := token.ProfileCtx(r.Context())
+
+		var params struct {
+			FileUrl     string `json:"file_url" validate:"required,url,max=255"`
+			Title       string `json:"title" validate:"required,max=255"`
+			Description string `json:"description" validate:"max=255"`
+			IsPublic    bool   `json:"is_public"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		d, err := dr.dataCommandService.CreateData(&data.Data{
+			UserID:      tp.UserID,
+			FileUrl:     params.FileUrl,
+			Title:       params.Title,
+			Description: utils.NewNullString(params.Description),
+			IsPublic:    params.IsPublic,
+		})
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(querymapper.NewOwnData(d))
+	}
+}
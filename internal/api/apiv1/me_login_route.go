@@ -0,0 +1,24 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+// GetLogins lists the latest sign-in attempts of the user, the failed ones included
+func (mr *meRoute) GetLogins() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		es, err := mr.accountQueryService.GetLoginEventsByUserId(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(es)
+	}
+}
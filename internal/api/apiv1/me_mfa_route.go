@@ -0,0 +1,236 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/lib/totp"
+)
+
+type mfaCodeParams struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,max=32"`
+}
+
+func (mr *meRoute) registerMFA(r chi.Router) {
+	r.Route("/mfa", func(r chi.Router) {
+		r.Get("/", mr.GetMFA())
+		r.Post("/totp", mr.PostTOTP())
+		r.Post("/totp/confirm", mr.PostTOTPConfirm())
+		r.Delete("/totp", mr.DeleteTOTP())
+		r.Post("/recovery-codes", mr.PostRecoveryCodes())
+	})
+}
+
+func (mr *meRoute) GetMFA() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		m, err := mr.accountQueryService.GetUserMFAByUserId(p.UserID)
+		exist, err := m.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist || !m.IsEnabled() {
+			rw.Write(map[string]any{
+				"enabled": false,
+			})
+			return
+		}
+
+		count, err := mr.accountQueryService.GetUnusedMFARecoveryCodesCount(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"enabled":             true,
+			"enabled_at":          m.EnabledAt.Time,
+			"recovery_codes_left": count,
+		})
+	}
+}
+
+// PostTOTP starts the enrolment, the totp is enabled once PostTOTPConfirm gets a first code
+func (mr *meRoute) PostTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		m, err := mr.accountQueryService.GetUserMFAByUserId(p.UserID)
+		exist, err := m.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if exist && m.IsEnabled() {
+			rw.ErrorConflict(
+				errors.New("mfa is enabled"),
+			)
+			return
+		}
+
+		secret, err := mr.accountCommandService.StartTOTPEnrolment(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"secret":      secret,
+			"otpauth_uri": totp.URI(env.MFAIssuer(), p.Username, secret),
+		})
+	}
+}
+
+func (mr *meRoute) PostTOTPConfirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Code string `json:"code" validate:"required,numeric,len=6"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		m, err := mr.accountQueryService.GetUserMFAByUserId(p.UserID)
+		exist, err := m.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			rw.ErrorNotFound(
+				errors.New("not found mfa enrolment"),
+			)
+			return
+		}
+		if m.IsEnabled() {
+			rw.ErrorConflict(
+				errors.New("mfa is enabled"),
+			)
+			return
+		}
+
+		codes, err := mr.accountCommandService.ConfirmTOTPEnrolment(m, params.Code)
+		if err != nil {
+			writeMFAError(rw, err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DeleteTOTP disables 2FA, it asks for a code so that a stolen session can not remove it
+func (mr *meRoute) DeleteTOTP() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		m, ok := mr.verifyMFACode(rw, r, p.UserID)
+		if !ok {
+			return
+		}
+
+		err := mr.accountCommandService.DisableMFA(m.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(struct{}{})
+	}
+}
+
+func (mr *meRoute) PostRecoveryCodes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		m, ok := mr.verifyMFACode(rw, r, p.UserID)
+		if !ok {
+			return
+		}
+
+		codes, err := mr.accountCommandService.RegenerateMFARecoveryCodes(m.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// verifyMFACode checks the totp or recovery code of the body,
+// it writes the error response itself and returns false when it is not valid
+func (mr *meRoute) verifyMFACode(rw httpresponse.Writer, r *http.Request, userId string) (*account.UserMFA, bool) {
+	var params mfaCodeParams
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil {
+		rw.Error(
+			errors.Wrap(err, "decode params error"),
+		)
+		return nil, false
+	}
+
+	err = validator.New().Struct(&params)
+	if err != nil {
+		rw.ErrorBadRequest(
+			errors.Wrap(err, "params validate error"),
+		)
+		return nil, false
+	}
+
+	m, err := mr.accountQueryService.GetUserMFAByUserId(userId)
+	exist, err := m.IsExist(err)
+	if err != nil {
+		rw.Error(err)
+		return nil, false
+	}
+	if !exist {
+		rw.ErrorBadRequest(account.ErrMFANotEnabled)
+		return nil, false
+	}
+
+	if params.Code != "" {
+		err = mr.accountCommandService.VerifyTOTP(m, params.Code)
+	} else {
+		err = mr.accountCommandService.VerifyMFARecoveryCode(m, params.RecoveryCode)
+	}
+	if err != nil {
+		writeMFAError(rw, err)
+		return nil, false
+	}
+
+	return m, true
+}
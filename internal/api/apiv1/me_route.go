@@ -0,0 +1,445 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpmiddleware"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	commandservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/command"
+	queryservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/query"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/mailer"
+	"github.com/rlawnsxo131/madre-server-v3/lib/password"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+type meRoute struct {
+	accountCommandService account.AccountCommandService
+	accountQueryService   account.AccountQueryService
+	sessionChecker        *commandservice.SessionChecker
+}
+
+func NewMeRoute(db rdb.Database) *meRoute {
+	return &meRoute{
+		commandservice.NewAccountCommandService(db),
+		queryservice.NewAccountQueryService(db),
+		commandservice.DefaultSessionChecker(db),
+	}
+}
+
+func (mr *meRoute) Register(r chi.Router) {
+	r.Route("/me", func(r chi.Router) {
+		r.Use(httpmiddleware.RequireAuth)
+		r.Patch("/profile", mr.PatchProfile())
+		r.Put("/username", mr.PutUsername())
+		r.Post("/email", mr.PostEmail())
+		r.Post("/email/verify", mr.PostEmailVerify())
+		r.Get("/logins", mr.GetLogins())
+		if env.PasswordAuthEnabled() {
+			r.Put("/password", mr.PutPassword())
+		}
+		mr.registerMFA(r)
+		mr.registerWebAuthn(r)
+		mr.registerPersonalAccessTokens(r)
+	})
+}
+
+// PatchProfile customises the profile, a customised field is no longer synced from the identity provider
+// unless sync_origin_name or sync_photo_url turns it back on
+func (mr *meRoute) PatchProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			OriginName     *string `json:"origin_name" validate:"omitempty,max=255"`
+			PhotoUrl       *string `json:"photo_url" validate:"omitempty,url,max=255"`
+			SyncOriginName *bool   `json:"sync_origin_name"`
+			SyncPhotoUrl   *bool   `json:"sync_photo_url"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		u, err := mr.accountQueryService.GetUserById(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		if params.OriginName != nil {
+			u.CustomizeOriginName(*params.OriginName)
+		}
+		if params.PhotoUrl != nil {
+			u.CustomizePhotoUrl(*params.PhotoUrl)
+		}
+		if params.SyncOriginName != nil {
+			u.SyncOriginName = *params.SyncOriginName
+		}
+		if params.SyncPhotoUrl != nil {
+			u.SyncPhotoUrl = *params.SyncPhotoUrl
+		}
+
+		u, err = mr.accountCommandService.UpdateUserProfile(u)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		// the photo url is a claim of the tokens
+		np := token.NewProfile(
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			p.Roles,
+		)
+		np.SessionID = p.SessionID
+		tokens, err := token.NewManager().Issue(np, sessionClient(r), w, tokensInBody(r))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		res := map[string]any{
+			"origin_name":      utils.NormalizeNullString(u.OriginName),
+			"photo_url":        utils.NormalizeNullString(u.PhotoUrl),
+			"sync_origin_name": u.SyncOriginName,
+			"sync_photo_url":   u.SyncPhotoUrl,
+		}
+		if tokens != nil {
+			res["tokens"] = tokens
+		}
+		rw.Write(res)
+	}
+}
+
+func (mr *meRoute) PutUsername() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Username string `json:"username" validate:"required,max=20,min=1"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		reason, err := mr.accountQueryService.GetUsernameUnavailableReason(params.Username, p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if reason != "" {
+			writeUsernameUnavailable(rw, reason)
+			return
+		}
+
+		u, err := mr.accountQueryService.GetUserById(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		u, err = mr.accountCommandService.ChangeUsername(u, params.Username)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		// the username is a claim of the tokens
+		np := token.NewProfile(
+			u.ID,
+			u.Username,
+			utils.NormalizeNullString(u.PhotoUrl),
+			p.Roles,
+		)
+		np.SessionID = p.SessionID
+		tokens, err := token.NewManager().Issue(np, sessionClient(r), w, tokensInBody(r))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(withTokens(np, tokens))
+	}
+}
+
+// PostEmail sends a link to confirm the new email, the email is changed by PostEmailVerify
+func (mr *meRoute) PostEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Email string `json:"email" validate:"required,email,max=255"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		u, err := mr.accountQueryService.GetUserById(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if strings.EqualFold(u.Email, params.Email) {
+			rw.ErrorBadRequest(
+				errors.New("email is not changed"),
+			)
+			return
+		}
+
+		exist, err := mr.accountQueryService.GetExistsUserByEmail(params.Email)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if exist {
+			rw.ErrorConflict(
+				errors.New("email is exist"),
+			)
+			return
+		}
+
+		ev, raw, err := mr.accountCommandService.RequestEmailChange(u, params.Email)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		err = mailer.DefaultMailer().Send(r.Context(), &mailer.Message{
+			To:      ev.Email,
+			Subject: "Confirm your email",
+			Text: "Open the link below to use this email for your account " + u.Username + ".\n\n" +
+				emailVerificationURL(raw) + "\n\n" +
+				"The link expires at " + ev.ExpiresAt.UTC().Format(time.RFC1123) + ".\n",
+		})
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"email":      ev.Email,
+			"expires_at": ev.ExpiresAt,
+		})
+	}
+}
+
+func (mr *meRoute) PostEmailVerify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Token string `json:"token" validate:"required,max=255"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		ev, err := mr.accountQueryService.GetEmailVerificationByToken(params.Token)
+		exist, err := ev.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			rw.ErrorBadRequest(account.ErrEmailVerificationInvalid)
+			return
+		}
+
+		if err := ev.Check(p.UserID, time.Now()); err != nil {
+			writeEmailVerificationError(rw, err)
+			return
+		}
+
+		// the email may have been taken since the link was sent
+		exist, err = mr.accountQueryService.GetExistsUserByEmail(ev.Email)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if exist {
+			rw.ErrorConflict(
+				errors.New("email is exist"),
+			)
+			return
+		}
+
+		err = mr.accountCommandService.ConfirmEmailChange(ev)
+		if err != nil {
+			writeEmailVerificationError(rw, err)
+			return
+		}
+
+		u, err := mr.accountQueryService.GetUserById(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"email":             u.Email,
+			"email_verified_at": u.EmailVerifiedAt.Time,
+		})
+	}
+}
+
+func writeEmailVerificationError(rw httpresponse.Writer, err error) {
+	switch {
+	case errors.Is(err, account.ErrEmailVerificationInvalid):
+		rw.ErrorBadRequest(err)
+	case errors.Is(err, account.ErrEmailVerificationExpired):
+		rw.ErrorUnprocessableEntity(err)
+	default:
+		rw.Error(err)
+	}
+}
+
+// emailVerificationURL is the page of the client which posts the token back to PostEmailVerify
+func emailVerificationURL(raw string) string {
+	return strings.TrimSuffix(env.ClientURL(), "/") + "/settings/email/verify?token=" + url.QueryEscape(raw)
+}
+
+// PutPassword changes the password and signs out every device but the one of the request
+func (mr *meRoute) PutPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			CurrentPassword string `json:"current_password" validate:"required,max=128"`
+			NewPassword     string `json:"new_password" validate:"required"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		u, err := mr.accountQueryService.GetUserById(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		c, err := mr.accountQueryService.GetCredentialByUserId(u.ID)
+		exist, err := c.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !exist {
+			rw.ErrorNotFound(
+				errors.New("not found credential"),
+			)
+			return
+		}
+
+		match, err := password.Verify(params.CurrentPassword, c.PasswordHash)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !match {
+			rw.ErrorForbidden(
+				errors.New("current password is wrong"),
+			)
+			return
+		}
+
+		err = password.Validate(params.NewPassword, env.PasswordMinLength(), u.Username, u.Email)
+		if err != nil {
+			rw.ErrorUnprocessableEntity(err)
+			return
+		}
+
+		err = mr.accountCommandService.ChangePassword(c, params.NewPassword)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		// whoever knew the old password is signed out of the other devices
+		ids, err := mr.accountCommandService.RevokeOtherSessions(p.UserID, p.SessionID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		mr.sessionChecker.Forget(ids...)
+
+		rw.Write(map[string]any{
+			"revoked": len(ids),
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+func (mr *meRoute) registerPersonalAccessTokens(r chi.Router) {
+	r.Route("/tokens", func(r chi.Router) {
+		r.Get("/", mr.GetPersonalAccessTokens())
+		r.Post("/", mr.PostPersonalAccessToken())
+		r.Delete("/{id}", mr.DeletePersonalAccessToken())
+	})
+}
+
+func (mr *meRoute) GetPersonalAccessTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		pats, err := mr.accountQueryService.GetPersonalAccessTokensByUserId(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(pats)
+	}
+}
+
+// PostPersonalAccessToken answers with the raw token, it can not be read again afterwards
+func (mr *meRoute) PostPersonalAccessToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Name          string   `json:"name" validate:"required,max=64"`
+			Scopes        []string `json:"scopes" validate:"required,min=1"`
+			ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		pats, err := mr.accountQueryService.GetPersonalAccessTokensByUserId(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		active := 0
+		now := time.Now()
+		for _, pat := range pats {
+			if pat.Check(now) == nil {
+				active++
+			}
+		}
+		if active >= account.PERSONAL_ACCESS_TOKEN_MAX_PER_USER {
+			rw.ErrorUnprocessableEntity(
+				errors.New("personal access tokens limit is reached"),
+			)
+			return
+		}
+
+		ttl := account.PERSONAL_ACCESS_TOKEN_DEFAULT_TTL
+		if params.ExpiresInDays > 0 {
+			ttl = time.Hour * 24 * time.Duration(params.ExpiresInDays)
+		}
+
+		pat, raw, err := mr.accountCommandService.CreatePersonalAccessToken(p.UserID, params.Name, params.Scopes, ttl)
+		if err != nil {
+			if errors.Is(err, account.ErrPersonalAccessTokenBadScope) {
+				rw.ErrorBadRequest(err)
+				return
+			}
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(map[string]any{
+			"personal_access_token": pat,
+			"token":                 raw,
+		})
+	}
+}
+
+func (mr *meRoute) DeletePersonalAccessToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		id := chi.URLParam(r, "id")
+		err := validator.New().Var(id, "required,uuid")
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		revoked, err := mr.accountCommandService.RevokePersonalAccessToken(p.UserID, id)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !revoked {
+			rw.ErrorNotFound(
+				errors.New("not found personal access token"),
+			)
+			return
+		}
+
+		rw.Write(struct{}{})
+	}
+}
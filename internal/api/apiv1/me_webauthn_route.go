@@ -0,0 +1,214 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/lib/webauthn"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+const (
+	DEFAULT_WEBAUTHN_CREDENTIAL_NAME = "Passkey"
+)
+
+func (mr *meRoute) registerWebAuthn(r chi.Router) {
+	r.Route("/webauthn", func(r chi.Router) {
+		r.Post("/register/begin", mr.PostWebAuthnRegisterBegin())
+		r.Post("/register/finish", mr.PostWebAuthnRegisterFinish())
+		r.Get("/credentials", mr.GetWebAuthnCredentials())
+		r.Delete("/credentials/{id}", mr.DeleteWebAuthnCredential())
+	})
+}
+
+// PostWebAuthnRegisterBegin answers with the options of navigator.credentials.create,
+// the passkeys the user already has are excluded
+func (mr *meRoute) PostWebAuthnRegisterBegin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		u, err := mr.accountQueryService.GetUserById(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		wcs, err := mr.accountQueryService.GetWebAuthnCredentialsByUserId(u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if len(wcs) >= account.WEBAUTHN_MAX_CREDENTIALS {
+			rw.ErrorUnprocessableEntity(
+				errors.New("webauthn credentials limit is reached"),
+			)
+			return
+		}
+
+		exclude := []string{}
+		for _, wc := range wcs {
+			exclude = append(exclude, wc.CredentialID)
+		}
+
+		ch, err := mr.accountCommandService.StartWebAuthnCeremony(account.WEBAUTHN_CEREMONY_REGISTRATION, u.ID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		displayName := utils.NormalizeNullString(u.OriginName)
+		if displayName == "" {
+			displayName = u.Username
+		}
+		rw.Write(
+			webauthn.DefaultRelyingParty().BeginRegistration(
+				ch.Challenge,
+				webauthn.User{
+					ID:          u.ID,
+					Name:        u.Username,
+					DisplayName: displayName,
+				},
+				exclude,
+			),
+		)
+	}
+}
+
+func (mr *meRoute) PostWebAuthnRegisterFinish() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		var params struct {
+			Name       string                        `json:"name" validate:"max=64"`
+			Credential webauthn.RegistrationResponse `json:"credential" validate:"required"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			rw.Error(
+				errors.Wrap(err, "decode params error"),
+			)
+			return
+		}
+
+		err = validator.New().Struct(&params)
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		challenge, err := params.Credential.Challenge()
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+		_, err = mr.accountCommandService.ConsumeWebAuthnChallenge(account.WEBAUTHN_CEREMONY_REGISTRATION, challenge, p.UserID)
+		if err != nil {
+			writeWebAuthnChallengeError(rw, err)
+			return
+		}
+
+		c, err := webauthn.DefaultRelyingParty().FinishRegistration(challenge, &params.Credential)
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+
+		registered, err := mr.accountQueryService.GetWebAuthnCredentialByCredentialId(c.ID)
+		exist, err := registered.IsExist(err)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if exist {
+			rw.ErrorConflict(
+				errors.New("webauthn credential is exist"),
+			)
+			return
+		}
+
+		name := params.Name
+		if name == "" {
+			name = DEFAULT_WEBAUTHN_CREDENTIAL_NAME
+		}
+		wc, err := mr.accountCommandService.AddWebAuthnCredential(&account.WebAuthnCredential{
+			UserID:       p.UserID,
+			CredentialID: c.ID,
+			PublicKey:    c.PublicKey,
+			SignCount:    int64(c.SignCount),
+			AAGUID:       c.AAGUID,
+			Name:         name,
+		})
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(wc)
+	}
+}
+
+func (mr *meRoute) GetWebAuthnCredentials() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		wcs, err := mr.accountQueryService.GetWebAuthnCredentialsByUserId(p.UserID)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(wcs)
+	}
+}
+
+func (mr *meRoute) DeleteWebAuthnCredential() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+		p := token.ProfileCtx(r.Context())
+
+		id := chi.URLParam(r, "id")
+		err := validator.New().Var(id, "required,uuid")
+		if err != nil {
+			rw.ErrorBadRequest(
+				errors.Wrap(err, "params validate error"),
+			)
+			return
+		}
+
+		deleted, err := mr.accountCommandService.DeleteWebAuthnCredential(p.UserID, id)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+		if !deleted {
+			rw.ErrorNotFound(
+				errors.New("not found webauthn credential"),
+			)
+			return
+		}
+
+		rw.Write(struct{}{})
+	}
+}
+
+func writeWebAuthnChallengeError(rw httpresponse.Writer, err error) {
+	switch {
+	case errors.Is(err, account.ErrWebAuthnChallengeInvalid):
+		rw.ErrorBadRequest(err)
+	case errors.Is(err, account.ErrWebAuthnChallengeExpired):
+		rw.ErrorUnprocessableEntity(err)
+	default:
+		rw.Error(err)
+	}
+}
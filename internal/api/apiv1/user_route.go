@@ -0,0 +1,148 @@
+package apiv1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/external/engine/httpresponse"
+	querymapper "github.com/rlawnsxo131/madre-server-v3/internal/application/mapper/query"
+	queryservice "github.com/rlawnsxo131/madre-server-v3/internal/application/service/query"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+)
+
+const (
+	USERNAME_SUGGESTIONS_LIMIT = 3
+)
+
+type userRoute struct {
+	accountQueryService account.AccountQueryService
+	dataQueryService    data.DataQueryService
+}
+
+func NewUserRoute(db rdb.Database) *userRoute {
+	return &userRoute{
+		queryservice.NewAccountQueryService(db),
+		queryservice.NewDataQueryService(db),
+	}
+}
+
+func (ur *userRoute) Register(r chi.Router) {
+	r.Route("/users", func(r chi.Router) {
+		r.Get("/availability", ur.GetAvailability())
+		r.Get("/{username}", ur.Get())
+		r.Get("/{username}/data", ur.GetData())
+	})
+}
+
+func (ur *userRoute) Get() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		username := chi.URLParam(r, "username")
+		u, err := ur.accountQueryService.GetUserByCurrentOrPreviousUsername(username)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(querymapper.NewResolvedPublicUser(u, username))
+	}
+}
+
+// GetData lists the public data of the user, newest first, paginated by page and size
+func (ur *userRoute) GetData() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		page, err := queryInt(r, "page")
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+		size, err := queryInt(r, "size")
+		if err != nil {
+			rw.ErrorBadRequest(err)
+			return
+		}
+		p := querymapper.NewPage(page, size)
+
+		u, err := ur.accountQueryService.GetUserByCurrentOrPreviousUsername(chi.URLParam(r, "username"))
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		ds, total, err := ur.dataQueryService.GetPublicDataByUserId(u.ID, p.Limit(), p.Offset())
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		rw.Write(querymapper.NewPublicDataList(ds, p, total))
+	}
+}
+
+// GetAvailability checks a username before sign-up or rename,
+// suggestions are derived from the username and the optional display_name of the provider
+func (ur *userRoute) GetAvailability() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := httpresponse.NewWriter(w, r)
+
+		username := r.URL.Query().Get("username")
+		if username == "" {
+			rw.ErrorBadRequest(
+				errors.New("username is required"),
+			)
+			return
+		}
+
+		userId := ""
+		if p := token.ProfileCtx(r.Context()); p != nil {
+			userId = p.UserID
+		}
+
+		reason, err := ur.accountQueryService.GetUsernameUnavailableReason(username, userId)
+		if err != nil {
+			rw.Error(err)
+			return
+		}
+
+		suggestions := []string{}
+		if reason != "" {
+			suggestions, err = ur.accountQueryService.GetUsernameSuggestions(
+				username,
+				r.URL.Query().Get("display_name"),
+				USERNAME_SUGGESTIONS_LIMIT,
+			)
+			if err != nil {
+				rw.Error(err)
+				return
+			}
+		}
+
+		rw.Write(map[string]any{
+			"username":    username,
+			"available":   reason == "",
+			"reason":      reason,
+			"suggestions": suggestions,
+		})
+	}
+}
+
+// queryInt returns 0 when the query parameter is absent
+func queryInt(r *http.Request, key string) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s", key)
+	}
+	return n, nil
+}
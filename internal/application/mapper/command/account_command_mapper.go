@@ -1,16 +1,34 @@
 package commandmapper
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
 	"github.com/rlawnsxo131/madre-server-v3/utils"
 )
 
 func NewCreateAccountUser(email, originName, username, photoUrl string) *account.User {
 	return &account.User{
-		Email:      email,
-		OriginName: utils.NewNullString(originName),
-		Username:   username,
-		PhotoUrl:   utils.NewNullString(photoUrl),
+		Email:           email,
+		EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		OriginName:      utils.NewNullString(originName),
+		Username:        username,
+		PhotoUrl:        utils.NewNullString(photoUrl),
+		SyncOriginName:  true,
+		SyncPhotoUrl:    true,
+		ProfileSyncedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+}
+
+// NewCreatePasswordAccountUser has no provider to sync the profile from,
+// the email stays unverified until it is confirmed
+func NewCreatePasswordAccountUser(email, username string) *account.User {
+	return &account.User{
+		Email:          email,
+		Username:       username,
+		SyncOriginName: false,
+		SyncPhotoUrl:   false,
 	}
 }
 
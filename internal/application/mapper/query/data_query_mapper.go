@@ -0,0 +1,114 @@
+package querymapper
+
+import (
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+)
+
+type PublicData struct {
+	ID          string    `json:"id"`
+	FileUrl     string    `json:"file_url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewPublicData(d *data.Data) *PublicData {
+	return &PublicData{
+		ID:          d.ID,
+		FileUrl:     d.FileUrl,
+		Title:       d.Title,
+		Description: utils.NormalizeNullString(d.Description),
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+type Page struct {
+	Page int `json:"page"`
+	Size int `json:"size"`
+}
+
+// NewPage falls back to the first page and DEFAULT_PAGE_SIZE, the size is capped at MAX_PAGE_SIZE
+func NewPage(page, size int) Page {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = DEFAULT_PAGE_SIZE
+	}
+	if size > MAX_PAGE_SIZE {
+		size = MAX_PAGE_SIZE
+	}
+	return Page{page, size}
+}
+
+func (p Page) Limit() int {
+	return p.Size
+}
+
+func (p Page) Offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+type PublicDataList struct {
+	Items   []*PublicData `json:"items"`
+	Page    int           `json:"page"`
+	Size    int           `json:"size"`
+	Total   int           `json:"total"`
+	HasNext bool          `json:"has_next"`
+}
+
+func NewPublicDataList(ds []*data.Data, p Page, total int) *PublicDataList {
+	items := make([]*PublicData, 0, len(ds))
+	for _, d := range ds {
+		items = append(items, NewPublicData(d))
+	}
+	return &PublicDataList{
+		Items:   items,
+		Page:    p.Page,
+		Size:    p.Size,
+		Total:   total,
+		HasNext: p.Offset()+len(items) < total,
+	}
+}
+
+// OwnData is the data as its owner sees it
+type OwnData struct {
+	*PublicData
+	IsPublic bool `json:"is_public"`
+}
+
+func NewOwnData(d *data.Data) *OwnData {
+	return &OwnData{NewPublicData(d), d.IsPublic}
+}
+
+type OwnDataList struct {
+	Items   []*OwnData `json:"items"`
+	Page    int        `json:"page"`
+	Size    int        `json:"size"`
+	Total   int        `json:"total"`
+	HasNext bool       `json:"has_next"`
+}
+
+func NewOwnDataList(ds []*data.Data, p Page, total int) *OwnDataList {
+	items := make([]*OwnData, 0, len(ds))
+	for _, d := range ds {
+		items = append(items, NewOwnData(d))
+	}
+	return &OwnDataList{
+		Items:   items,
+		Page:    p.Page,
+		Size:    p.Size,
+		Total:   total,
+		HasNext: p.Offset()+len(items) < total,
+	}
+}
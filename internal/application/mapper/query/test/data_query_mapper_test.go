@@ -0,0 +1,52 @@
+package querymapper_test
+
+import (
+	"testing"
+
+	querymapper "github.com/rlawnsxo131/madre-server-v3/internal/application/mapper/query"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewPage_Defaults(t *testing.T) {
+	assert := assert.New(t)
+
+	p := querymapper.NewPage(0, 0)
+
+	assert.Equal(1, p.Page)
+	assert.Equal(querymapper.DEFAULT_PAGE_SIZE, p.Size)
+	assert.Equal(0, p.Offset())
+}
+
+func Test_NewPage_CapsSize(t *testing.T) {
+	assert := assert.New(t)
+
+	p := querymapper.NewPage(3, 1000)
+
+	assert.Equal(querymapper.MAX_PAGE_SIZE, p.Limit())
+	assert.Equal(2*querymapper.MAX_PAGE_SIZE, p.Offset())
+}
+
+func Test_NewPublicDataList_HasNext(t *testing.T) {
+	assert := assert.New(t)
+	ds := []*data.Data{{ID: "1"}, {ID: "2"}}
+
+	first := querymapper.NewPublicDataList(ds, querymapper.NewPage(1, 2), 3)
+	last := querymapper.NewPublicDataList(ds[:1], querymapper.NewPage(2, 2), 3)
+
+	assert.True(first.HasNext)
+	assert.Len(first.Items, 2)
+	assert.False(last.HasNext)
+}
+
+func Test_NewOwnDataList_KeepsVisibility(t *testing.T) {
+	assert := assert.New(t)
+	ds := []*data.Data{{ID: "1", IsPublic: true}, {ID: "2"}}
+
+	l := querymapper.NewOwnDataList(ds, querymapper.NewPage(1, 2), 2)
+
+	assert.False(l.HasNext)
+	assert.True(l.Items[0].IsPublic)
+	assert.False(l.Items[1].IsPublic)
+	assert.Equal("2", l.Items[1].ID)
+}
@@ -0,0 +1,30 @@
+package querymapper_test
+
+import (
+	"testing"
+
+	querymapper "github.com/rlawnsxo131/madre-server-v3/internal/application/mapper/query"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewResolvedPublicUser_CurrentUsername(t *testing.T) {
+	assert := assert.New(t)
+	u := &account.User{ID: "1", Email: "madre@example.com", Username: "Madre"}
+
+	pu := querymapper.NewResolvedPublicUser(u, "madre")
+
+	assert.Equal("Madre", pu.Username)
+	assert.Nil(pu.Redirect)
+}
+
+func Test_NewResolvedPublicUser_PreviousUsername(t *testing.T) {
+	assert := assert.New(t)
+	u := &account.User{ID: "1", Email: "madre@example.com", Username: "madre"}
+
+	pu := querymapper.NewResolvedPublicUser(u, "oldmadre")
+
+	assert.NotNil(pu.Redirect)
+	assert.Equal("oldmadre", pu.Redirect.From)
+	assert.Equal("madre", pu.Redirect.To)
+}
@@ -0,0 +1,47 @@
+package querymapper
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// PublicUser is what anyone can see of a user, it must never carry the email
+type PublicUser struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	OriginName string    `json:"origin_name"`
+	PhotoUrl   string    `json:"photo_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	// set when the user was found by a previous username
+	Redirect *UsernameRedirect `json:"redirect,omitempty"`
+}
+
+type UsernameRedirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func NewPublicUser(u *account.User) *PublicUser {
+	return &PublicUser{
+		ID:         u.ID,
+		Username:   u.Username,
+		OriginName: utils.NormalizeNullString(u.OriginName),
+		PhotoUrl:   utils.NormalizeNullString(u.PhotoUrl),
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+// NewResolvedPublicUser hints the client to redirect when username is not the current one of the user
+func NewResolvedPublicUser(u *account.User, username string) *PublicUser {
+	pu := NewPublicUser(u)
+	if !strings.EqualFold(u.Username, username) {
+		pu.Redirect = &UsernameRedirect{
+			From: username,
+			To:   u.Username,
+		}
+	}
+	return pu
+}
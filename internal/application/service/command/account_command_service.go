@@ -1,34 +1,549 @@
 package commandservice
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
 	commandrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/command"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/password"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/lib/totp"
+	"github.com/rlawnsxo131/madre-server-v3/lib/webauthn"
+)
+
+const (
+	TOTP_SECRET_PURPOSE = "totp-secret"
 )
 
 type accountCommandService struct {
-	repo account.AccountCommandRepository
+	repo                 account.AccountCommandRepository
+	emailVerificationTTL time.Duration
+	emailSignInTTL       time.Duration
 }
 
 func NewAccountCommandService(db rdb.Database) account.AccountCommandService {
 	return &accountCommandService{
 		commandrepository.NewAccountCommandRepository(db),
+		env.EmailVerificationTTL(),
+		env.EmailSignInTTL(),
 	}
 }
 
 func (acs *accountCommandService) CreateAccount(u *account.User, sa *account.SocialAccount) (*account.Account, error) {
-	userId, err := acs.repo.InsertUser(u)
+	err := acs.repo.Transaction(func(repo account.AccountCommandRepository) error {
+		return createAccount(repo, u, sa)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account.NewAccount(u, sa), nil
+}
+
+// SyncUserProfile refreshes the fields the user did not customise from the identity provider profile
+func (acs *accountCommandService) SyncUserProfile(u *account.User, originName, photoUrl string) (*account.User, error) {
+	synced := *u
+	synced.SyncProfile(originName, photoUrl, time.Now())
+	if err := acs.repo.UpdateUserProfile(&synced); err != nil {
+		return nil, err
+	}
+	return &synced, nil
+}
+
+func (acs *accountCommandService) UpdateUserProfile(u *account.User) (*account.User, error) {
+	if err := acs.repo.UpdateUserProfile(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (acs *accountCommandService) ChangeUsername(u *account.User, username string) (*account.User, error) {
+	renamed := *u
+	renamed.Username = username
+	if err := acs.repo.UpdateUsername(&renamed); err != nil {
+		return nil, err
+	}
+	return &renamed, nil
+}
+
+func (acs *accountCommandService) ChangeUserStatus(u *account.User, status, reason string, until time.Time) (*account.User, error) {
+	changed := *u
+	changed.ChangeStatus(status, reason, until)
+	if err := acs.repo.UpdateUserStatus(&changed); err != nil {
+		return nil, err
+	}
+	return &changed, nil
+}
+
+// RequestEmailChange returns the pending verification with the token to send to the new email,
+// the email of the user is only changed when it is confirmed
+func (acs *accountCommandService) RequestEmailChange(u *account.User, email string) (*account.EmailVerification, string, error) {
+	raw, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ev := &account.EmailVerification{
+		UserID:    u.ID,
+		Email:     email,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(acs.emailVerificationTTL),
+	}
+	id, err := acs.repo.InsertEmailVerification(ev)
+	if err != nil {
+		return nil, "", err
+	}
+	ev.ID = id
+
+	return ev, raw, nil
+}
+
+func (acs *accountCommandService) ConfirmEmailChange(ev *account.EmailVerification) error {
+	confirmed, err := acs.repo.ConfirmEmailVerification(ev)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return account.ErrEmailVerificationInvalid
+	}
+	return nil
+}
+
+// StartEmailSignIn returns the pending sign-in with the token of the link and the code to send by mail
+func (acs *accountCommandService) StartEmailSignIn(email string) (*account.EmailSignIn, string, string, error) {
+	raw, tokenHash, err := token.NewOpaqueToken()
+	if err != nil {
+		return nil, "", "", err
+	}
+	code, codeHash, err := token.NewNumericCode(account.EMAIL_SIGN_IN_CODE_LENGTH)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	es := &account.EmailSignIn{
+		Email:     email,
+		TokenHash: tokenHash,
+		CodeHash:  codeHash,
+		ExpiresAt: time.Now().Add(acs.emailSignInTTL),
+	}
+	id, err := acs.repo.InsertEmailSignIn(es)
+	if err != nil {
+		return nil, "", "", err
+	}
+	es.ID = id
+
+	return es, raw, code, nil
+}
+
+// FinishEmailSignIn uses the sign-in, the code is only checked when the sign-in was found by email.
+// Every code counts as an attempt before it is compared, so parallel guesses can not pass the limit.
+func (acs *accountCommandService) FinishEmailSignIn(es *account.EmailSignIn, code string) error {
+	if err := es.Check(time.Now()); err != nil {
+		return err
+	}
+
+	if code != "" {
+		ok, err := acs.repo.IncrementEmailSignInAttempts(es)
+		if err != nil {
+			return err
+		}
+		if !ok || !es.MatchCodeHash(token.HashOpaqueToken(code)) {
+			return account.ErrEmailSignInInvalid
+		}
+	}
+
+	used, err := acs.repo.UseEmailSignIn(es)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return account.ErrEmailSignInInvalid
+	}
+	return nil
+}
+
+// ClaimUnverifiedUser gives the account to whom proved the email by email sign-in.
+// The email of the account was never verified, so whoever signed it up loses
+// the password, second factors, tokens and sessions it may have set up.
+// The ids of the revoked sessions are returned.
+func (acs *accountCommandService) ClaimUnverifiedUser(u *account.User) (*account.User, []string, error) {
+	var sessionIds []string
+	err := acs.repo.Transaction(func(repo account.AccountCommandRepository) error {
+		if err := repo.DeleteCredential(u.ID); err != nil {
+			return err
+		}
+		if err := repo.DeleteUserMFA(u.ID); err != nil {
+			return err
+		}
+		if err := repo.DeleteWebAuthnCredentialsOfUser(u.ID); err != nil {
+			return err
+		}
+		if err := repo.RevokePersonalAccessTokensOfUser(u.ID); err != nil {
+			return err
+		}
+		ids, err := repo.RevokeSessionsOfUser(u.ID, account.SESSION_REVOKED_REASON_EMAIL_CLAIMED)
+		if err != nil {
+			return err
+		}
+		sessionIds = ids
+		return repo.UpdateUserEmailVerified(u)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claimed := *u
+	claimed.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return &claimed, sessionIds, nil
+}
+
+// CreatePasswordAccount creates the account with the argon2id hash of the password,
+// the strength of the password is checked by the caller
+func (acs *accountCommandService) CreatePasswordAccount(u *account.User, sa *account.SocialAccount, pw string) (*account.Account, error) {
+	hash, err := password.Hash(pw, password.DefaultParams())
+	if err != nil {
+		return nil, err
+	}
+
+	// a user without its credential could never sign in
+	err = acs.repo.Transaction(func(repo account.AccountCommandRepository) error {
+		if err := createAccount(repo, u, sa); err != nil {
+			return err
+		}
+		_, err := repo.InsertCredential(&account.Credential{
+			UserID:       u.ID,
+			PasswordHash: hash,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account.NewAccount(u, sa), nil
+}
+
+// ChangePassword also rehashes an unchanged password when the argon2id parameters were raised
+func (acs *accountCommandService) ChangePassword(c *account.Credential, pw string) error {
+	hash, err := password.Hash(pw, password.DefaultParams())
+	if err != nil {
+		return err
+	}
+
+	changed := *c
+	changed.PasswordHash = hash
+	return acs.repo.UpdateCredentialPasswordHash(&changed)
+}
+
+// StartTOTPEnrolment returns the secret to show to the user, it is sealed in the database
+func (acs *accountCommandService) StartTOTPEnrolment(userId string) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := token.Seal(TOTP_SECRET_PURPOSE, secret)
+	if err != nil {
+		return "", err
+	}
+
+	err = acs.repo.UpsertPendingUserMFA(&account.UserMFA{
+		UserID:     userId,
+		TOTPSecret: sealed,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// ConfirmTOTPEnrolment enables the totp with a first code and returns new recovery codes
+func (acs *accountCommandService) ConfirmTOTPEnrolment(m *account.UserMFA, code string) ([]string, error) {
+	step, err := acs.validateTOTP(m, code)
 	if err != nil {
 		return nil, err
 	}
+
+	enabled := *m
+	enabled.LastUsedStep = step
+	ok, err := acs.repo.EnableUserMFA(&enabled)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, account.ErrMFACodeInvalid
+	}
+
+	return acs.RegenerateMFARecoveryCodes(m.UserID)
+}
+
+// VerifyTOTP checks the code of an enabled totp, a code is accepted once
+func (acs *accountCommandService) VerifyTOTP(m *account.UserMFA, code string) error {
+	if !m.IsEnabled() {
+		return account.ErrMFANotEnabled
+	}
+
+	step, err := acs.validateTOTP(m, code)
+	if err != nil {
+		return err
+	}
+
+	used := *m
+	used.LastUsedStep = step
+	ok, err := acs.repo.UseUserMFAStep(&used)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return account.ErrMFACodeInvalid
+	}
+	return nil
+}
+
+func (acs *accountCommandService) VerifyMFARecoveryCode(m *account.UserMFA, code string) error {
+	if !m.IsEnabled() {
+		return account.ErrMFANotEnabled
+	}
+	if m.IsLocked(time.Now()) {
+		return account.ErrMFALocked
+	}
+
+	ok, err := acs.repo.UseMFARecoveryCode(m.UserID, token.HashOpaqueToken(totp.NormalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := acs.repo.FailUserMFAAttempt(m); err != nil {
+			return err
+		}
+		return account.ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// RegenerateMFARecoveryCodes returns the codes to show once, only their hashes are stored
+func (acs *accountCommandService) RegenerateMFARecoveryCodes(userId string) ([]string, error) {
+	codes, err := totp.GenerateRecoveryCodes(totp.RECOVERY_CODES)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, token.HashOpaqueToken(totp.NormalizeRecoveryCode(c)))
+	}
+	if err := acs.repo.ReplaceMFARecoveryCodes(userId, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (acs *accountCommandService) DisableMFA(userId string) error {
+	return acs.repo.DeleteUserMFA(userId)
+}
+
+// validateTOTP counts a wrong code as a failed attempt
+func (acs *accountCommandService) validateTOTP(m *account.UserMFA, code string) (int64, error) {
+	if m.IsLocked(time.Now()) {
+		return 0, account.ErrMFALocked
+	}
+
+	secret, err := token.Open(TOTP_SECRET_PURPOSE, m.TOTPSecret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, ok, err := totp.Validate(code, secret, time.Now(), m.LastUsedStep)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if err := acs.repo.FailUserMFAAttempt(m); err != nil {
+			return 0, err
+		}
+		return 0, account.ErrMFACodeInvalid
+	}
+
+	return step, nil
+}
+
+// StartWebAuthnCeremony stores a new challenge, userId is empty for a sign-in
+func (acs *accountCommandService) StartWebAuthnCeremony(ceremony, userId string) (*account.WebAuthnChallenge, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	wc := &account.WebAuthnChallenge{
+		Challenge: challenge,
+		Ceremony:  ceremony,
+		UserID:    sql.NullString{String: userId, Valid: userId != ""},
+		ExpiresAt: time.Now().Add(account.WEBAUTHN_CHALLENGE_TTL),
+	}
+	id, err := acs.repo.InsertWebAuthnChallenge(wc)
+	if err != nil {
+		return nil, err
+	}
+	wc.ID = id
+
+	return wc, nil
+}
+
+// ConsumeWebAuthnChallenge takes the challenge answered by the authenticator, it can not be answered twice
+func (acs *accountCommandService) ConsumeWebAuthnChallenge(ceremony, challenge, userId string) (*account.WebAuthnChallenge, error) {
+	wc, err := acs.repo.ConsumeWebAuthnChallenge(challenge)
+	exist, err := wc.IsExist(err)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, account.ErrWebAuthnChallengeInvalid
+	}
+	if err := wc.Check(ceremony, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return wc, nil
+}
+
+// UseWebAuthnSignInChallenge makes the challenge of a sign-in cookie single use, the cookie alone
+// can be replayed with its assertion until it expires
+func (acs *accountCommandService) UseWebAuthnSignInChallenge(challenge string, expiresAt time.Time) error {
+	wc := &account.WebAuthnChallenge{
+		Challenge: token.HashOpaqueToken(challenge),
+		Ceremony:  account.WEBAUTHN_CEREMONY_AUTHENTICATION,
+		ExpiresAt: expiresAt,
+	}
+	ok, err := acs.repo.UseWebAuthnChallenge(wc)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return account.ErrWebAuthnChallengeInvalid
+	}
+	return nil
+}
+
+func (acs *accountCommandService) AddWebAuthnCredential(wc *account.WebAuthnCredential) (*account.WebAuthnCredential, error) {
+	id, err := acs.repo.InsertWebAuthnCredential(wc)
+	if err != nil {
+		return nil, err
+	}
+	wc.ID = id
+	return wc, nil
+}
+
+// UseWebAuthnCredential stores the counter of the assertion, it fails when a concurrent
+// assertion stored a greater one
+func (acs *accountCommandService) UseWebAuthnCredential(wc *account.WebAuthnCredential, signCount uint32) error {
+	used := *wc
+	used.SignCount = int64(signCount)
+	ok, err := acs.repo.UpdateWebAuthnCredentialSignCount(&used)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return account.ErrWebAuthnSignCount
+	}
+	return nil
+}
+
+func (acs *accountCommandService) DeleteWebAuthnCredential(userId, id string) (bool, error) {
+	return acs.repo.DeleteWebAuthnCredential(userId, id)
+}
+
+// GrantRole returns false when the user already had the role, otherwise the sessions of the user
+// are ended so that no access token keeps the previous roles, their ids are returned
+func (acs *accountCommandService) GrantRole(userId, role string) (bool, []string, error) {
+	return acs.changeRole(userId, func(repo account.AccountCommandRepository) (bool, error) {
+		return repo.InsertUserRole(userId, role)
+	})
+}
+
+// RevokeRole returns false when the user did not have the role, its sessions are ended as by GrantRole
+func (acs *accountCommandService) RevokeRole(userId, role string) (bool, []string, error) {
+	return acs.changeRole(userId, func(repo account.AccountCommandRepository) (bool, error) {
+		return repo.DeleteUserRole(userId, role)
+	})
+}
+
+func (acs *accountCommandService) changeRole(userId string, change func(repo account.AccountCommandRepository) (bool, error)) (bool, []string, error) {
+	var changed bool
+	var sessionIds []string
+	err := acs.repo.Transaction(func(repo account.AccountCommandRepository) error {
+		ok, err := change(repo)
+		if err != nil || !ok {
+			return err
+		}
+		changed = true
+		sessionIds, err = repo.RevokeSessionsOfUser(userId, account.SESSION_REVOKED_REASON_ROLE_CHANGED)
+		return err
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return changed, sessionIds, nil
+}
+
+// RevokeSession signs a device of the user out, it returns false when the user has no such active session
+func (acs *accountCommandService) RevokeSession(userId, sessionId string) (bool, error) {
+	return acs.repo.RevokeSessionOfUser(userId, sessionId, account.SESSION_REVOKED_REASON_REVOKED)
+}
+
+// RevokeOtherSessions signs every device of the user out but the one of keepSessionId
+func (acs *accountCommandService) RevokeOtherSessions(userId, keepSessionId string) ([]string, error) {
+	return acs.repo.RevokeOtherSessions(userId, keepSessionId, account.SESSION_REVOKED_REASON_REVOKED)
+}
+
+// CreatePersonalAccessToken returns the token with the raw value to show once
+func (acs *accountCommandService) CreatePersonalAccessToken(userId, name string, scopes []string, ttl time.Duration) (*account.PersonalAccessToken, string, error) {
+	pat := &account.PersonalAccessToken{
+		UserID:    userId,
+		Name:      name,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := pat.SetScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	raw, hash, prefix, err := token.NewPersonalAccessToken()
+	if err != nil {
+		return nil, "", err
+	}
+	pat.TokenHash = hash
+	pat.TokenPrefix = prefix
+
+	id, err := acs.repo.InsertPersonalAccessToken(pat)
+	if err != nil {
+		return nil, "", err
+	}
+	pat.ID = id
+
+	return pat, raw, nil
+}
+
+func (acs *accountCommandService) RevokePersonalAccessToken(userId, id string) (bool, error) {
+	return acs.repo.RevokePersonalAccessToken(userId, id)
+}
+
+// createAccount inserts the user and its social account with repo, which is in the transaction of the caller
+func createAccount(repo account.AccountCommandRepository, u *account.User, sa *account.SocialAccount) error {
+	userId, err := repo.InsertUser(u)
+	if err != nil {
+		return err
+	}
 	u.ID = userId
 
 	sa.UserID = userId
-	socialAccountId, err := acs.repo.InsertSocialAccount(sa)
+	socialAccountId, err := repo.InsertSocialAccount(sa)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	sa.ID = socialAccountId
 
-	return account.NewAccount(u, sa), nil
+	return nil
 }
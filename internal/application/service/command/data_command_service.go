@@ -1,9 +1,32 @@
 package commandservice
 
-import "github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+import (
+	"time"
 
-type dataCommandService struct{}
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	commandrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/command"
+)
 
-func NewDataCommandService() data.DataCommandService {
-	return &dataCommandService{}
+type dataCommandService struct {
+	repo data.DataCommandRepository
+}
+
+func NewDataCommandService(db rdb.Database) data.DataCommandService {
+	return &dataCommandService{
+		commandrepository.NewDataCommandRepository(db),
+	}
+}
+
+func (ds *dataCommandService) CreateData(d *data.Data) (*data.Data, error) {
+	id, err := ds.repo.InsertData(d)
+	if err != nil {
+		return nil, err
+	}
+
+	created := *d
+	created.ID = id
+	created.CreatedAt = time.Now()
+	created.UpdatedAt = created.CreatedAt
+	return &created, nil
 }
@@ -0,0 +1,119 @@
+package commandservice
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	commandrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/command"
+	queryrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/query"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
+	"github.com/rlawnsxo131/madre-server-v3/lib/mailer"
+)
+
+const (
+	NEW_DEVICE_NOTIFY_TIMEOUT = time.Second * 30
+)
+
+// NewDeviceNotifier tells a user about a sign-in from a device never seen before
+type NewDeviceNotifier interface {
+	NotifyNewDevice(ctx context.Context, u *account.User, e *account.LoginEvent) error
+}
+
+// LoginRecorder keeps the login history of the users and alerts them of the new devices
+type LoginRecorder struct {
+	repo      account.AccountCommandRepository
+	queryRepo account.AccountQueryRepository
+	notifier  NewDeviceNotifier
+}
+
+func NewLoginRecorder(db rdb.Database, notifier NewDeviceNotifier) *LoginRecorder {
+	return &LoginRecorder{
+		commandrepository.NewAccountCommandRepository(db),
+		queryrepository.NewAccountQueryRepository(db),
+		notifier,
+	}
+}
+
+// RecordSuccess stores a sign-in of u, the first one from a device is alerted unless it is the first sign-in
+// of the user, which is also the case of the users who signed in before the history was kept.
+// The alert is sent in the background so that a slow mailer does not delay the sign-in.
+func (lr *LoginRecorder) RecordSuccess(u *account.User, e *account.LoginEvent) error {
+	e.UserID = sql.NullString{String: u.ID, Valid: true}
+	e.Outcome = account.LOGIN_EVENT_OUTCOME_SUCCESS
+
+	known, err := lr.queryRepo.ExistsSuccessfulLoginEventByUserIdAndDevice(u.ID, e.DeviceFingerprint)
+	if err != nil {
+		return err
+	}
+	if !known {
+		signedInBefore, err := lr.queryRepo.ExistsSuccessfulLoginEventByUserId(u.ID)
+		if err != nil {
+			return err
+		}
+		e.NewDevice = signedInBefore
+	}
+
+	id, err := lr.repo.InsertLoginEvent(e)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+
+	if e.NewDevice && lr.notifier != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), NEW_DEVICE_NOTIFY_TIMEOUT)
+			defer cancel()
+			if err := lr.notifier.NotifyNewDevice(ctx, u, e); err != nil {
+				logger.DefaultLogger().Err(err).Timestamp().Str("action", "NotifyNewDevice").Send()
+			}
+		}()
+	}
+
+	return nil
+}
+
+// RecordFailure stores a failed sign-in, userId is empty when the login matched no user
+func (lr *LoginRecorder) RecordFailure(userId, reason string, e *account.LoginEvent) error {
+	e.UserID = sql.NullString{String: userId, Valid: userId != ""}
+	e.Outcome = account.LOGIN_EVENT_OUTCOME_FAILURE
+	e.Reason = sql.NullString{String: reason, Valid: reason != ""}
+
+	id, err := lr.repo.InsertLoginEvent(e)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+
+	return nil
+}
+
+type mailNewDeviceNotifier struct {
+	mailer mailer.Mailer
+}
+
+// NewMailNewDeviceNotifier sends the alerts to the email of the user
+func NewMailNewDeviceNotifier(m mailer.Mailer) NewDeviceNotifier {
+	return &mailNewDeviceNotifier{m}
+}
+
+func (n *mailNewDeviceNotifier) NotifyNewDevice(ctx context.Context, u *account.User, e *account.LoginEvent) error {
+	if u.Email == "" {
+		return nil
+	}
+	return n.mailer.Send(ctx, &mailer.Message{
+		To:      u.Email,
+		Subject: "New sign-in to your account",
+		Text: "Your account " + u.Username + " was signed in from a new device.\n\n" +
+			"Time: " + time.Now().UTC().Format(time.RFC1123) + "\n" +
+			"Method: " + strings.ToLower(e.Provider) + "\n" +
+			"Device: " + e.UserAgent.String + "\n" +
+			"IP address: " + e.IP.String + "\n\n" +
+			"If this was not you, sign the other devices out and secure your account:\n" +
+			strings.TrimSuffix(env.ClientURL(), "/") + "/settings/sessions\n",
+	})
+}
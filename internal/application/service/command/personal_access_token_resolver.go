@@ -0,0 +1,60 @@
+package commandservice
+
+import (
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	commandrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/command"
+	queryrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/query"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// PersonalAccessTokenResolver finds the owner of the personal access tokens of the Authorization header
+// and keeps their last use
+type PersonalAccessTokenResolver struct {
+	repo      account.AccountCommandRepository
+	queryRepo account.AccountQueryRepository
+}
+
+func NewPersonalAccessTokenResolver(db rdb.Database) *PersonalAccessTokenResolver {
+	return &PersonalAccessTokenResolver{
+		commandrepository.NewAccountCommandRepository(db),
+		queryrepository.NewAccountQueryRepository(db),
+	}
+}
+
+// ResolvePersonalAccessToken returns nil when the token is unknown, revoked or expired
+func (r *PersonalAccessTokenResolver) ResolvePersonalAccessToken(raw string) (*token.PersonalAccessTokenOwner, error) {
+	pat, err := r.queryRepo.FindPersonalAccessTokenByTokenHash(token.HashOpaqueToken(raw))
+	exist, err := pat.IsExist(err)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	now := time.Now()
+	if pat.Check(now) != nil {
+		return nil, nil
+	}
+
+	u, err := r.queryRepo.FindUserById(pat.UserID)
+	exist, err = u.IsExist(err)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	if pat.NeedsLastUsed(now) {
+		if err := r.repo.UpdatePersonalAccessTokenLastUsed(pat.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &token.PersonalAccessTokenOwner{
+		TokenID:  pat.ID,
+		UserID:   u.ID,
+		Username: u.Username,
+		PhotoUrl: utils.NormalizeNullString(u.PhotoUrl),
+		Scopes:   pat.ScopeList(),
+	}, nil
+}
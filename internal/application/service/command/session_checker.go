@@ -0,0 +1,100 @@
+package commandservice
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	commandrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/command"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	// the cache is swept past this size, a sweep drops every entry when all are fresh
+	SESSION_CACHE_MAX_ENTRIES = 10000
+)
+
+var (
+	defaultSessionChecker     *SessionChecker
+	onceDefaultSessionChecker sync.Once
+)
+
+// SessionChecker is asked on every authenticated request, a session is checked and its device
+// and last seen time are written at most once per SESSION_CACHE_TTL
+type SessionChecker struct {
+	repo    account.AccountCommandRepository
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]sessionEntry
+}
+
+type sessionEntry struct {
+	active    bool
+	expiresAt time.Time
+}
+
+func DefaultSessionChecker(db rdb.Database) *SessionChecker {
+	onceDefaultSessionChecker.Do(func() {
+		defaultSessionChecker = &SessionChecker{
+			repo:    commandrepository.NewAccountCommandRepository(db),
+			ttl:     env.SessionCacheTTL(),
+			entries: map[string]sessionEntry{},
+		}
+	})
+	return defaultSessionChecker
+}
+
+// CheckSession returns false when the session was revoked or expired,
+// the access tokens issued before the sessions have no session id and are refused as well
+func (c *SessionChecker) CheckSession(sessionId, userAgent, ip string) (bool, error) {
+	if _, err := uuid.Parse(sessionId); err != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[sessionId]
+	c.mu.Unlock()
+
+	if !ok || !now.Before(e.expiresAt) {
+		s := &account.Session{ID: sessionId}
+		s.SetDevice(userAgent, ip)
+		active, err := c.repo.TouchSession(s)
+		if err != nil {
+			return true, err
+		}
+		e = sessionEntry{active, now.Add(c.ttl)}
+
+		c.mu.Lock()
+		if len(c.entries) >= SESSION_CACHE_MAX_ENTRIES {
+			c.sweep(now)
+		}
+		c.entries[sessionId] = e
+		c.mu.Unlock()
+	}
+
+	return e.active, nil
+}
+
+// Forget drops the cached session, the next request of the session checks it again
+func (c *SessionChecker) Forget(sessionIds ...string) {
+	c.mu.Lock()
+	for _, id := range sessionIds {
+		delete(c.entries, id)
+	}
+	c.mu.Unlock()
+}
+
+func (c *SessionChecker) sweep(now time.Time) {
+	for id, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+	if len(c.entries) >= SESSION_CACHE_MAX_ENTRIES {
+		c.entries = map[string]sessionEntry{}
+	}
+}
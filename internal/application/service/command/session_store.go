@@ -0,0 +1,139 @@
+package commandservice
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	commandrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/command"
+	queryrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/query"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
+)
+
+// sessionStore keeps the sessions of the tokens in the database
+type sessionStore struct {
+	repo      account.AccountCommandRepository
+	queryRepo account.AccountQueryRepository
+}
+
+func NewSessionStore(db rdb.Database) token.SessionStore {
+	return &sessionStore{
+		commandrepository.NewAccountCommandRepository(db),
+		queryrepository.NewAccountQueryRepository(db),
+	}
+}
+
+func (ss *sessionStore) CreateSession(userId, refreshTokenUUID string, client token.SessionClient, expiresAt time.Time) (string, error) {
+	s := &account.Session{
+		UserID:    userId,
+		ExpiresAt: expiresAt,
+	}
+	s.SetDevice(client.UserAgent, client.IP)
+	return ss.repo.InsertSession(s, refreshTokenUUID)
+}
+
+func (ss *sessionStore) RotateSession(sessionId, refreshTokenUUID string, expiresAt time.Time) error {
+	ok, err := ss.repo.RotateSessionRefreshToken(&account.Session{
+		ID:        sessionId,
+		ExpiresAt: expiresAt,
+	}, refreshTokenUUID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Wrap(token.ErrSessionInvalid, "session is revoked or expired")
+	}
+	return nil
+}
+
+// UseRefreshToken marks the refresh token used, presenting it again revokes the session
+// unless it happens within SESSION_REFRESH_TOKEN_REUSE_GRACE of the rotation,
+// then it is a concurrent request of the same client and token.ErrRefreshTokenRotated is returned
+func (ss *sessionStore) UseRefreshToken(sessionId, refreshTokenUUID string) error {
+	// tokens issued before the sessions have no session id
+	if _, err := uuid.Parse(sessionId); err != nil {
+		return errors.Wrap(token.ErrSessionInvalid, "session id")
+	}
+	if _, err := uuid.Parse(refreshTokenUUID); err != nil {
+		return errors.Wrap(token.ErrSessionInvalid, "refresh token uuid")
+	}
+
+	rt, err := ss.queryRepo.FindSessionRefreshTokenByTokenUUID(refreshTokenUUID)
+	exist, err := rt.IsExist(err)
+	if err != nil {
+		return err
+	}
+	if !exist || rt.SessionID != sessionId {
+		return errors.Wrap(token.ErrSessionInvalid, "unknown refresh token")
+	}
+
+	s, err := ss.queryRepo.FindSessionById(sessionId)
+	exist, err = s.IsExist(err)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !exist || !s.IsActive(now) {
+		return errors.Wrap(token.ErrSessionInvalid, "session is revoked or expired")
+	}
+
+	if rt.UsedAt.Valid {
+		if rt.IsReuseInGrace(now) {
+			return token.ErrRefreshTokenRotated
+		}
+		err := ss.repo.RevokeSession(sessionId, account.SESSION_REVOKED_REASON_REFRESH_TOKEN_REUSE)
+		if err != nil {
+			return err
+		}
+		return errors.Wrap(token.ErrSessionInvalid, "refresh token reused")
+	}
+
+	used, err := ss.repo.UseSessionRefreshToken(rt)
+	if err != nil {
+		return err
+	}
+	if !used {
+		// a concurrent request used it first
+		return token.ErrRefreshTokenRotated
+	}
+	return nil
+}
+
+// SessionUser revokes the session of a suspended or banned user
+func (ss *sessionStore) SessionUser(sessionId, userId string) (*token.SessionUser, error) {
+	u, err := ss.queryRepo.FindUserById(userId)
+	exist, err := u.IsExist(err)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, errors.Wrap(token.ErrSessionInvalid, "user does not exist")
+	}
+	if err := u.CheckStatus(time.Now()); err != nil {
+		if _, perr := uuid.Parse(sessionId); perr == nil {
+			rerr := ss.repo.RevokeSession(sessionId, account.SESSION_REVOKED_REASON_USER_STATUS)
+			if rerr != nil {
+				return nil, rerr
+			}
+		}
+		return nil, &token.SessionUserStatusError{Err: err}
+	}
+
+	roles, err := ss.queryRepo.FindRolesByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token.SessionUser{
+		Username: u.Username,
+		PhotoUrl: utils.NormalizeNullString(u.PhotoUrl),
+		Roles:    roles,
+	}, nil
+}
+
+func (ss *sessionStore) RevokeSession(sessionId string) error {
+	return ss.repo.RevokeSession(sessionId, account.SESSION_REVOKED_REASON_SIGN_OUT)
+}
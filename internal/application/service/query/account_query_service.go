@@ -1,18 +1,28 @@
 package queryservice
 
 import (
+	"database/sql"
+	"strings"
+	"time"
+
 	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
 	queryrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/query"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
 )
 
 type accountQueryService struct {
-	repo account.AccountQueryRepository
+	repo              account.AccountQueryRepository
+	reservedUsernames []string
+	usernameCooldown  time.Duration
 }
 
 func NewAccountQueryService(db rdb.Database) account.AccountQueryService {
 	return &accountQueryService{
 		queryrepository.NewAccountQueryRepository(db),
+		env.ReservedUsernames(),
+		env.UsernameCooldown(),
 	}
 }
 
@@ -37,10 +47,100 @@ func (aqs *accountQueryService) GetUserByUsername(username string) (*account.Use
 	return aqs.repo.FindUserByUsername(username)
 }
 
+// GetUserByCurrentOrPreviousUsername falls back to the last user who left the username,
+// so that links to an old username keep working
+func (aqs *accountQueryService) GetUserByCurrentOrPreviousUsername(username string) (*account.User, error) {
+	u, err := aqs.repo.FindUserByUsername(username)
+	if err != sql.ErrNoRows {
+		return u, err
+	}
+	return aqs.repo.FindUserByPreviousUsername(username)
+}
+
 func (aqs *accountQueryService) GetExistsUserByUsername(username string) (bool, error) {
 	return aqs.repo.ExistsUserByUsername(username)
 }
 
+func (aqs *accountQueryService) GetUserByEmail(email string) (*account.User, error) {
+	return aqs.repo.FindUserByEmail(email)
+}
+
+func (aqs *accountQueryService) GetExistsUserByEmail(email string) (bool, error) {
+	return aqs.repo.ExistsUserByEmail(email)
+}
+
+// GetUsernameUnavailableReason returns an empty reason when the username can be used,
+// the user of userId is not counted so that the case of its own username can change
+func (aqs *accountQueryService) GetUsernameUnavailableReason(username, userId string) (string, error) {
+	u := &account.User{Username: username}
+	valid, err := u.ValidateUsername()
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		return account.USERNAME_UNAVAILABLE_INVALID, nil
+	}
+
+	if account.IsReservedUsername(username, aqs.reservedUsernames) {
+		return account.USERNAME_UNAVAILABLE_RESERVED, nil
+	}
+
+	u, err = aqs.repo.FindUserByUsername(username)
+	exist, err := u.IsExist(err)
+	if err != nil {
+		return "", err
+	}
+	if exist && u.ID != userId {
+		return account.USERNAME_UNAVAILABLE_TAKEN, nil
+	}
+
+	recentlyUsed, err := aqs.repo.ExistsUsernameHistory(username, userId, aqs.releasedBefore())
+	if err != nil {
+		return "", err
+	}
+	if recentlyUsed {
+		return account.USERNAME_UNAVAILABLE_RECENTLY_USED, nil
+	}
+
+	return "", nil
+}
+
+func (aqs *accountQueryService) GetUsernameSuggestions(username, displayName string, limit int) ([]string, error) {
+	candidates := []string{}
+	for _, c := range account.UsernameCandidates(username, displayName) {
+		if strings.EqualFold(c, username) || account.IsReservedUsername(c, aqs.reservedUsernames) {
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+
+	existing, err := aqs.repo.FindExistingUsernames(candidates, aqs.releasedBefore())
+	if err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, e := range existing {
+		taken[e] = true
+	}
+
+	suggestions := []string{}
+	for _, c := range candidates {
+		if len(suggestions) >= limit {
+			break
+		}
+		if !taken[strings.ToLower(c)] {
+			suggestions = append(suggestions, c)
+		}
+	}
+
+	return suggestions, nil
+}
+
+// releasedBefore is the start of the cool-down of the usernames left by their users
+func (aqs *accountQueryService) releasedBefore() time.Time {
+	return time.Now().Add(-aqs.usernameCooldown)
+}
+
 func (aqs *accountQueryService) GetSocialAccountBySocialIdAndProvider(socialId, provider string) (*account.SocialAccount, error) {
 	return aqs.repo.FindSocialAccountBySocialIdAndProvider(socialId, provider)
 }
@@ -48,3 +148,52 @@ func (aqs *accountQueryService) GetSocialAccountBySocialIdAndProvider(socialId,
 func (aqs *accountQueryService) GetExistsSocialAccountBySocialIdAndProvider(socialId, provider string) (bool, error) {
 	return aqs.repo.ExistsSocialAccountBySocialIdAndProvider(socialId, provider)
 }
+
+func (aqs *accountQueryService) GetEmailVerificationByToken(raw string) (*account.EmailVerification, error) {
+	return aqs.repo.FindEmailVerificationByTokenHash(token.HashOpaqueToken(raw))
+}
+
+func (aqs *accountQueryService) GetCredentialByUserId(userId string) (*account.Credential, error) {
+	return aqs.repo.FindCredentialByUserId(userId)
+}
+
+func (aqs *accountQueryService) GetUserMFAByUserId(userId string) (*account.UserMFA, error) {
+	return aqs.repo.FindUserMFAByUserId(userId)
+}
+
+func (aqs *accountQueryService) GetUnusedMFARecoveryCodesCount(userId string) (int, error) {
+	return aqs.repo.CountUnusedMFARecoveryCodes(userId)
+}
+
+func (aqs *accountQueryService) GetEmailSignInByToken(raw string) (*account.EmailSignIn, error) {
+	return aqs.repo.FindEmailSignInByTokenHash(token.HashOpaqueToken(raw))
+}
+
+func (aqs *accountQueryService) GetPendingEmailSignInByEmail(email string) (*account.EmailSignIn, error) {
+	return aqs.repo.FindPendingEmailSignInByEmail(email)
+}
+
+func (aqs *accountQueryService) GetWebAuthnCredentialByCredentialId(credentialId string) (*account.WebAuthnCredential, error) {
+	return aqs.repo.FindWebAuthnCredentialByCredentialId(credentialId)
+}
+
+func (aqs *accountQueryService) GetWebAuthnCredentialsByUserId(userId string) ([]*account.WebAuthnCredential, error) {
+	return aqs.repo.FindWebAuthnCredentialsByUserId(userId)
+}
+
+func (aqs *accountQueryService) GetRolesByUserId(userId string) ([]string, error) {
+	return aqs.repo.FindRolesByUserId(userId)
+}
+
+func (aqs *accountQueryService) GetActiveSessionsByUserId(userId string) ([]*account.Session, error) {
+	return aqs.repo.FindActiveSessionsByUserId(userId)
+}
+
+func (aqs *accountQueryService) GetPersonalAccessTokensByUserId(userId string) ([]*account.PersonalAccessToken, error) {
+	return aqs.repo.FindPersonalAccessTokensByUserId(userId)
+}
+
+// GetLoginEventsByUserId returns the latest sign-in attempts of the user, newest first
+func (aqs *accountQueryService) GetLoginEventsByUserId(userId string) ([]*account.LoginEvent, error) {
+	return aqs.repo.FindLoginEventsByUserId(userId, account.LOGIN_EVENT_LIST_LIMIT)
+}
@@ -1,9 +1,53 @@
 package queryservice
 
-import "github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+import (
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	queryrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/query"
+)
 
-type dataQueryService struct{}
+type dataQueryService struct {
+	repo data.DataQueryRepository
+}
+
+func NewDataQueryService(db rdb.Database) data.DataQueryService {
+	return &dataQueryService{
+		queryrepository.NewDataQueryRepository(db),
+	}
+}
+
+// GetPublicDataByUserId returns a page of the public data of the user, newest first, with the total count
+func (ds *dataQueryService) GetPublicDataByUserId(userId string, limit, offset int) ([]*data.Data, int, error) {
+	total, err := ds.repo.CountPublicDataByUserId(userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || offset >= total {
+		return []*data.Data{}, total, nil
+	}
+
+	list, err := ds.repo.FindPublicDataByUserId(userId, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+// GetDataByUserId returns a page of all the data of the user, the private ones included
+func (ds *dataQueryService) GetDataByUserId(userId string, limit, offset int) ([]*data.Data, int, error) {
+	total, err := ds.repo.CountDataByUserId(userId)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || offset >= total {
+		return []*data.Data{}, total, nil
+	}
+
+	list, err := ds.repo.FindDataByUserId(userId, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
 
-func NewDataQueryService() data.DataQueryService {
-	return &dataQueryService{}
+	return list, total, nil
 }
@@ -0,0 +1,94 @@
+package queryservice
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	queryrepository "github.com/rlawnsxo131/madre-server-v3/internal/infrastructure/repository/query"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	// the cache is swept past this size, a sweep drops every entry when all are fresh
+	USER_STATUS_CACHE_MAX_ENTRIES = 10000
+)
+
+var (
+	defaultUserStatusChecker     *UserStatusChecker
+	onceDefaultUserStatusChecker sync.Once
+)
+
+// UserStatusChecker is asked on every authenticated request, so the status of the users
+// is cached for a short time instead of being read each time
+type UserStatusChecker struct {
+	repo    account.AccountQueryRepository
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]userStatusEntry
+}
+
+type userStatusEntry struct {
+	u         *account.User
+	expiresAt time.Time
+}
+
+func DefaultUserStatusChecker(db rdb.Database) *UserStatusChecker {
+	onceDefaultUserStatusChecker.Do(func() {
+		defaultUserStatusChecker = &UserStatusChecker{
+			repo:    queryrepository.NewAccountQueryRepository(db),
+			ttl:     env.UserStatusCacheTTL(),
+			entries: map[string]userStatusEntry{},
+		}
+	})
+	return defaultUserStatusChecker
+}
+
+// CheckUserStatus returns false when the user is suspended, banned or deleted
+func (c *UserStatusChecker) CheckUserStatus(userId string) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[userId]
+	c.mu.Unlock()
+
+	if !ok || !now.Before(e.expiresAt) {
+		u, err := c.repo.FindUserById(userId)
+		exist, err := u.IsExist(err)
+		if err != nil {
+			return true, err
+		}
+		if !exist {
+			u = nil
+		}
+		e = userStatusEntry{u, now.Add(c.ttl)}
+
+		c.mu.Lock()
+		if len(c.entries) >= USER_STATUS_CACHE_MAX_ENTRIES {
+			c.sweep(now)
+		}
+		c.entries[userId] = e
+		c.mu.Unlock()
+	}
+
+	return e.u != nil && e.u.CheckStatus(now) == nil, nil
+}
+
+// Forget drops the cached status, the next request of the user reads it again
+func (c *UserStatusChecker) Forget(userId string) {
+	c.mu.Lock()
+	delete(c.entries, userId)
+	c.mu.Unlock()
+}
+
+func (c *UserStatusChecker) sweep(now time.Time) {
+	for id, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+	if len(c.entries) >= USER_STATUS_CACHE_MAX_ENTRIES {
+		c.entries = map[string]userStatusEntry{}
+	}
+}
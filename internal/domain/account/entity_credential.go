@@ -0,0 +1,24 @@
+package account
+
+import (
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	SOCIAL_ACCOUNT_PROVIDER_PASSWORD = "PASSWORD"
+)
+
+// Credential is the password of a local account, only its argon2id hash is stored
+type Credential struct {
+	ID           string    `json:"id" db:"id"`
+	UserID       string    `json:"user_id" db:"user_id"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (c *Credential) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(c.ID, err)
+}
@@ -0,0 +1,66 @@
+package account
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	SOCIAL_ACCOUNT_PROVIDER_EMAIL = "EMAIL"
+
+	EMAIL_SIGN_IN_CODE_LENGTH = 6
+	// a code has 10^6 values, so it is burned after a few wrong guesses
+	EMAIL_SIGN_IN_MAX_ATTEMPTS = 5
+	// the guesses of all the codes sent to an email within EMAIL_SIGN_IN_ATTEMPT_WINDOW,
+	// a resend does not give new guesses beyond this
+	EMAIL_SIGN_IN_MAX_ATTEMPTS_PER_EMAIL = 10
+	EMAIL_SIGN_IN_ATTEMPT_WINDOW         = time.Hour
+	// a new link is not sent to the same email more often than this
+	EMAIL_SIGN_IN_RESEND_INTERVAL = time.Minute
+)
+
+var (
+	ErrEmailSignInInvalid = errors.New("email sign-in is invalid")
+	ErrEmailSignInExpired = errors.New("email sign-in is expired")
+)
+
+// EmailSignIn is a pending passwordless sign-in, the user either follows the link with the token
+// or types the code sent in the same mail. Only the hashes are stored.
+type EmailSignIn struct {
+	ID        string       `json:"id" db:"id"`
+	Email     string       `json:"email" db:"email"`
+	TokenHash string       `json:"-" db:"token_hash"`
+	CodeHash  string       `json:"-" db:"code_hash"`
+	Attempts  int          `json:"attempts" db:"attempts"`
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (es *EmailSignIn) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(es.ID, err)
+}
+
+// Check tells if the sign-in can still be finished
+func (es *EmailSignIn) Check(now time.Time) error {
+	if es.UsedAt.Valid || es.Attempts >= EMAIL_SIGN_IN_MAX_ATTEMPTS {
+		return ErrEmailSignInInvalid
+	}
+	if !now.Before(es.ExpiresAt) {
+		return ErrEmailSignInExpired
+	}
+	return nil
+}
+
+func (es *EmailSignIn) MatchCodeHash(codeHash string) bool {
+	return subtle.ConstantTimeCompare([]byte(es.CodeHash), []byte(codeHash)) == 1
+}
+
+// CanResend is false while the previous link was sent less than EMAIL_SIGN_IN_RESEND_INTERVAL ago
+func (es *EmailSignIn) CanResend(now time.Time) bool {
+	return !now.Before(es.CreatedAt.Add(EMAIL_SIGN_IN_RESEND_INTERVAL))
+}
@@ -0,0 +1,41 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+var (
+	ErrEmailVerificationInvalid = errors.New("email verification is invalid")
+	ErrEmailVerificationExpired = errors.New("email verification is expired")
+)
+
+// EmailVerification is a pending change of the email of a user,
+// only the hash of the token sent by mail is stored
+type EmailVerification struct {
+	ID        string       `json:"id" db:"id"`
+	UserID    string       `json:"user_id" db:"user_id"`
+	Email     string       `json:"email" db:"email"`
+	TokenHash string       `json:"-" db:"token_hash"`
+	ExpiresAt time.Time    `json:"expires_at" db:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (ev *EmailVerification) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(ev.ID, err)
+}
+
+// Check tells if the verification can still confirm the email of the user
+func (ev *EmailVerification) Check(userId string, now time.Time) error {
+	if ev.UserID != userId || ev.UsedAt.Valid {
+		return ErrEmailVerificationInvalid
+	}
+	if !now.Before(ev.ExpiresAt) {
+		return ErrEmailVerificationExpired
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	LOGIN_EVENT_OUTCOME_SUCCESS = "success"
+	LOGIN_EVENT_OUTCOME_FAILURE = "failure"
+
+	// the providers of the sign-ins which are not a social account
+	LOGIN_EVENT_PROVIDER_WEBAUTHN = "WEBAUTHN"
+	LOGIN_EVENT_PROVIDER_MFA      = "MFA"
+
+	LOGIN_EVENT_REASON_INVALID_PASSWORD = "invalid_password"
+	LOGIN_EVENT_REASON_UNKNOWN_USER     = "unknown_user"
+	LOGIN_EVENT_REASON_INVALID_MFA      = "invalid_mfa"
+	LOGIN_EVENT_REASON_INVALID_WEBAUTHN = "invalid_webauthn"
+	LOGIN_EVENT_REASON_EMAIL_UNVERIFIED = "email_unverified"
+	LOGIN_EVENT_REASON_INVALID_CODE     = "invalid_code"
+	// the id token or the access token of an identity provider was refused
+	LOGIN_EVENT_REASON_INVALID_TOKEN = "invalid_token"
+	// the identity provider could not be reached or failed the code exchange
+	LOGIN_EVENT_REASON_PROVIDER_ERROR = "provider_error"
+	// the identity of the provider is not linked to any user
+	LOGIN_EVENT_REASON_UNKNOWN_ACCOUNT = "unknown_account"
+	// the status of a suspended or banned user is appended, e.g. account_banned
+	LOGIN_EVENT_REASON_ACCOUNT = "account_"
+
+	LOGIN_EVENT_LIST_LIMIT = 50
+)
+
+// LoginEvent is a sign-in attempt, UserID is null when the login matched no user.
+// DeviceFingerprint is the hash of the device id cookie, a success from an unknown one is alerted to the user
+type LoginEvent struct {
+	ID                string         `json:"id" db:"id"`
+	UserID            sql.NullString `json:"-" db:"user_id"`
+	Provider          string         `json:"provider" db:"provider"`
+	Outcome           string         `json:"outcome" db:"outcome"`
+	Reason            sql.NullString `json:"reason" db:"reason"`
+	UserAgent         sql.NullString `json:"user_agent" db:"user_agent"`
+	IP                sql.NullString `json:"ip" db:"ip"`
+	DeviceFingerprint string         `json:"-" db:"device_fingerprint"`
+	NewDevice         bool           `json:"new_device" db:"new_device"`
+	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
+}
+
+func (e *LoginEvent) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(e.ID, err)
+}
+
+func (e *LoginEvent) IsSuccess() bool {
+	return e.Outcome == LOGIN_EVENT_OUTCOME_SUCCESS
+}
+
+// SetDevice keeps the user agent and the ip of the attempt, cut to the column sizes like the sessions
+func (e *LoginEvent) SetDevice(userAgent, ip, fingerprint string) {
+	e.UserAgent = sql.NullString{
+		String: truncate(userAgent, SESSION_USER_AGENT_MAX_LENGTH),
+		Valid:  userAgent != "",
+	}
+	e.IP = sql.NullString{
+		String: truncate(ip, SESSION_IP_MAX_LENGTH),
+		Valid:  ip != "",
+	}
+	e.DeviceFingerprint = fingerprint
+}
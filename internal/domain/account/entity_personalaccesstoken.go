@@ -0,0 +1,100 @@
+package account
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	SCOPE_DATA_READ  = "data:read"
+	SCOPE_DATA_WRITE = "data:write"
+
+	PERSONAL_ACCESS_TOKEN_MAX_PER_USER = 20
+	PERSONAL_ACCESS_TOKEN_DEFAULT_TTL  = time.Hour * 24 * 30
+	PERSONAL_ACCESS_TOKEN_MAX_TTL      = time.Hour * 24 * 365
+
+	// the last use is written at most this often, not on every request
+	PERSONAL_ACCESS_TOKEN_LAST_USED_INTERVAL = time.Minute
+)
+
+var (
+	ErrPersonalAccessTokenInvalid  = errors.New("personal access token is invalid")
+	ErrPersonalAccessTokenExpired  = errors.New("personal access token is expired")
+	ErrPersonalAccessTokenBadScope = errors.New("personal access token scope is unknown")
+
+	scopes = []string{SCOPE_DATA_READ, SCOPE_DATA_WRITE}
+)
+
+// PersonalAccessToken authenticates the scripts of a user with some scopes only,
+// the token is shown once and only its hash is stored, TokenPrefix identifies it in the lists
+type PersonalAccessToken struct {
+	ID          string       `json:"id" db:"id"`
+	UserID      string       `json:"user_id" db:"user_id"`
+	Name        string       `json:"name" db:"name"`
+	TokenPrefix string       `json:"token_prefix" db:"token_prefix"`
+	TokenHash   string       `json:"-" db:"token_hash"`
+	Scopes      string       `json:"scopes" db:"scopes"`
+	ExpiresAt   time.Time    `json:"expires_at" db:"expires_at"`
+	LastUsedAt  sql.NullTime `json:"last_used_at" db:"last_used_at"`
+	RevokedAt   sql.NullTime `json:"revoked_at" db:"revoked_at"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (pat *PersonalAccessToken) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(pat.ID, err)
+}
+
+// SetScopes keeps the scopes space separated like the oauth scopes, each scope once
+func (pat *PersonalAccessToken) SetScopes(list []string) error {
+	if len(list) == 0 {
+		return ErrPersonalAccessTokenBadScope
+	}
+	kept := []string{}
+	for _, s := range list {
+		if !IsScope(s) {
+			return errors.Wrap(ErrPersonalAccessTokenBadScope, s)
+		}
+		if !contains(kept, s) {
+			kept = append(kept, s)
+		}
+	}
+	pat.Scopes = strings.Join(kept, " ")
+	return nil
+}
+
+func (pat *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(pat.Scopes)
+}
+
+// Check tells if the token can still authenticate a request
+func (pat *PersonalAccessToken) Check(now time.Time) error {
+	if pat.RevokedAt.Valid {
+		return ErrPersonalAccessTokenInvalid
+	}
+	if !now.Before(pat.ExpiresAt) {
+		return ErrPersonalAccessTokenExpired
+	}
+	return nil
+}
+
+// NeedsLastUsed tells if the use at now has to be written
+func (pat *PersonalAccessToken) NeedsLastUsed(now time.Time) bool {
+	return !pat.LastUsedAt.Valid || now.Sub(pat.LastUsedAt.Time) >= PERSONAL_ACCESS_TOKEN_LAST_USED_INTERVAL
+}
+
+func IsScope(s string) bool {
+	return contains(scopes, s)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
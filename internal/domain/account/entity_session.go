@@ -0,0 +1,90 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	SESSION_REVOKED_REASON_SIGN_OUT = "sign_out"
+	// the user signed the session out from another device
+	SESSION_REVOKED_REASON_REVOKED = "revoked"
+	// a refresh token used twice was stolen or replayed, the whole session is revoked
+	SESSION_REVOKED_REASON_REFRESH_TOKEN_REUSE = "refresh_token_reuse"
+	// the owner of the email signed in to an account whose email was never verified
+	SESSION_REVOKED_REASON_EMAIL_CLAIMED = "email_claimed"
+	// the user was suspended or banned, its sessions end at their next refresh
+	SESSION_REVOKED_REASON_USER_STATUS = "user_status"
+	// a role of the user was granted or revoked, the access tokens carry the roles they were issued with
+	SESSION_REVOKED_REASON_ROLE_CHANGED = "role_changed"
+
+	// concurrent requests of the same browser can refresh with the same token,
+	// a reuse this close to the rotation is refused without revoking the session
+	SESSION_REFRESH_TOKEN_REUSE_GRACE = time.Second * 10
+
+	SESSION_USER_AGENT_MAX_LENGTH = 512
+	SESSION_IP_MAX_LENGTH         = 64
+)
+
+// Session is a sign-in on a device, it lives as long as its refresh tokens are rotated in time
+type Session struct {
+	ID            string         `json:"id" db:"id"`
+	UserID        string         `json:"user_id" db:"user_id"`
+	ExpiresAt     time.Time      `json:"expires_at" db:"expires_at"`
+	RevokedAt     sql.NullTime   `json:"revoked_at" db:"revoked_at"`
+	RevokedReason sql.NullString `json:"revoked_reason" db:"revoked_reason"`
+	UserAgent     sql.NullString `json:"user_agent" db:"user_agent"`
+	IP            sql.NullString `json:"ip" db:"ip"`
+	LastSeenAt    time.Time      `json:"last_seen_at" db:"last_seen_at"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+func (s *Session) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(s.ID, err)
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return !s.RevokedAt.Valid && now.Before(s.ExpiresAt)
+}
+
+// SetDevice keeps the user agent and the ip the session was last seen with, cut to the column sizes
+func (s *Session) SetDevice(userAgent, ip string) {
+	s.UserAgent = sql.NullString{
+		String: truncate(userAgent, SESSION_USER_AGENT_MAX_LENGTH),
+		Valid:  userAgent != "",
+	}
+	s.IP = sql.NullString{
+		String: truncate(ip, SESSION_IP_MAX_LENGTH),
+		Valid:  ip != "",
+	}
+}
+
+// truncate cuts s to n characters, the columns count characters and not bytes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// SessionRefreshToken is a refresh token issued in a session, TokenUUID is its token_uuid claim
+type SessionRefreshToken struct {
+	ID        string       `json:"id" db:"id"`
+	SessionID string       `json:"session_id" db:"session_id"`
+	TokenUUID string       `json:"token_uuid" db:"token_uuid"`
+	UsedAt    sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (rt *SessionRefreshToken) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(rt.ID, err)
+}
+
+// IsReuseInGrace tells if a used token is presented again right after its rotation
+func (rt *SessionRefreshToken) IsReuseInGrace(now time.Time) bool {
+	return rt.UsedAt.Valid && now.Sub(rt.UsedAt.Time) < SESSION_REFRESH_TOKEN_REUSE_GRACE
+}
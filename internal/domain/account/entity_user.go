@@ -9,20 +9,69 @@ import (
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
 )
 
+const (
+	USER_STATUS_ACTIVE    = "active"
+	USER_STATUS_SUSPENDED = "suspended"
+	USER_STATUS_BANNED    = "banned"
+)
+
+var (
+	ErrUserSuspended = errors.New("user is suspended")
+	ErrUserBanned    = errors.New("user is banned")
+	// a password sign-up has not proved that the email belongs to the user
+	ErrUserEmailUnverified = errors.New("user email is not verified")
+)
+
 type User struct {
-	ID         string         `json:"id" db:"id"`
-	Email      string         `json:"email" db:"email"`
-	OriginName sql.NullString `json:"origin_name" db:"origin_name"`
-	Username   string         `json:"username" db:"username"`
-	PhotoUrl   sql.NullString `json:"photo_url" db:"photo_url"`
-	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at" db:"updated_at"`
+	ID              string         `json:"id" db:"id"`
+	Email           string         `json:"email" db:"email"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at" db:"email_verified_at"`
+	OriginName      sql.NullString `json:"origin_name" db:"origin_name"`
+	Username        string         `json:"username" db:"username"`
+	PhotoUrl        sql.NullString `json:"photo_url" db:"photo_url"`
+	SyncOriginName  bool           `json:"sync_origin_name" db:"sync_origin_name"`
+	SyncPhotoUrl    bool           `json:"sync_photo_url" db:"sync_photo_url"`
+	ProfileSyncedAt sql.NullTime   `json:"profile_synced_at" db:"profile_synced_at"`
+	Status          string         `json:"status" db:"status"`
+	StatusReason    sql.NullString `json:"-" db:"status_reason"`
+	SuspendedUntil  sql.NullTime   `json:"suspended_until" db:"suspended_until"`
+	CreatedAt       time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at" db:"updated_at"`
 }
 
 func (u *User) IsExist(err error) (bool, error) {
 	return common.IsExistEntity(u.ID, err)
 }
 
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt.Valid
+}
+
+// CheckStatus tells if the user can sign in and use its tokens,
+// a suspension is over once its end has passed even if the status was not reset
+func (u *User) CheckStatus(now time.Time) error {
+	switch u.Status {
+	case USER_STATUS_BANNED:
+		return ErrUserBanned
+	case USER_STATUS_SUSPENDED:
+		if !u.SuspendedUntil.Valid || now.Before(u.SuspendedUntil.Time) {
+			return ErrUserSuspended
+		}
+	}
+	return nil
+}
+
+// ChangeStatus applies a moderation decision, until is only kept for a suspension
+func (u *User) ChangeStatus(status, reason string, until time.Time) {
+	u.Status = status
+	u.StatusReason = sql.NullString{String: reason, Valid: reason != ""}
+	u.SuspendedUntil = sql.NullTime{Time: until, Valid: status == USER_STATUS_SUSPENDED}
+}
+
+func IsUserStatus(status string) bool {
+	return status == USER_STATUS_ACTIVE || status == USER_STATUS_SUSPENDED || status == USER_STATUS_BANNED
+}
+
 func (u *User) ValidateUsername() (bool, error) {
 	match, err := regexp.MatchString("^[a-zA-Z0-9]{1,20}$", u.Username)
 	if err != nil {
@@ -30,3 +79,33 @@ func (u *User) ValidateUsername() (bool, error) {
 	}
 	return match, nil
 }
+
+// SyncProfile applies the profile of the identity provider to the fields the user did not customise,
+// it returns true when a field has changed
+func (u *User) SyncProfile(originName, photoUrl string, now time.Time) bool {
+	changed := false
+
+	if u.SyncOriginName && originName != "" && originName != u.OriginName.String {
+		u.OriginName = sql.NullString{String: originName, Valid: true}
+		changed = true
+	}
+	if u.SyncPhotoUrl && photoUrl != "" && photoUrl != u.PhotoUrl.String {
+		u.PhotoUrl = sql.NullString{String: photoUrl, Valid: true}
+		changed = true
+	}
+
+	u.ProfileSyncedAt = sql.NullTime{Time: now, Valid: true}
+	return changed
+}
+
+// CustomizeOriginName stops the origin name from being overwritten by the next sign-in
+func (u *User) CustomizeOriginName(originName string) {
+	u.OriginName = sql.NullString{String: originName, Valid: originName != ""}
+	u.SyncOriginName = false
+}
+
+// CustomizePhotoUrl stops the photo url from being overwritten by the next sign-in
+func (u *User) CustomizePhotoUrl(photoUrl string) {
+	u.PhotoUrl = sql.NullString{String: photoUrl, Valid: photoUrl != ""}
+	u.SyncPhotoUrl = false
+}
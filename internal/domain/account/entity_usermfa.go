@@ -0,0 +1,55 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	// a code has 10^6 values, so the verification is locked after a few wrong codes
+	MFA_MAX_FAILED_ATTEMPTS = 5
+	MFA_LOCK_DURATION       = time.Minute * 15
+)
+
+var (
+	ErrMFANotEnabled  = errors.New("mfa is not enabled")
+	ErrMFACodeInvalid = errors.New("mfa code is invalid")
+	ErrMFALocked      = errors.New("mfa is locked")
+)
+
+// UserMFA is the totp of a user, it is pending until a first code confirms the enrolment.
+// The secret is sealed, it must be read back to check the codes.
+type UserMFA struct {
+	UserID         string       `json:"user_id" db:"user_id"`
+	TOTPSecret     string       `json:"-" db:"totp_secret"`
+	EnabledAt      sql.NullTime `json:"enabled_at" db:"enabled_at"`
+	LastUsedStep   int64        `json:"-" db:"last_used_step"`
+	FailedAttempts int          `json:"-" db:"failed_attempts"`
+	LockedUntil    sql.NullTime `json:"locked_until" db:"locked_until"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+func (m *UserMFA) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(m.UserID, err)
+}
+
+func (m *UserMFA) IsEnabled() bool {
+	return m.EnabledAt.Valid
+}
+
+func (m *UserMFA) IsLocked(now time.Time) bool {
+	return m.LockedUntil.Valid && now.Before(m.LockedUntil.Time)
+}
+
+// MFARecoveryCode replaces a totp code once, when the phone is lost
+type MFARecoveryCode struct {
+	ID        string       `json:"id" db:"id"`
+	UserID    string       `json:"user_id" db:"user_id"`
+	CodeHash  string       `json:"-" db:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at" db:"used_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+}
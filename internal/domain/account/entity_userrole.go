@@ -0,0 +1,28 @@
+package account
+
+import "time"
+
+const (
+	ROLE_ADMIN = "admin"
+)
+
+var (
+	// the roles which can be granted, a user without role is a regular user
+	roles = []string{ROLE_ADMIN}
+)
+
+type UserRole struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func IsRole(role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package account
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+const (
+	WEBAUTHN_CEREMONY_REGISTRATION   = "registration"
+	WEBAUTHN_CEREMONY_AUTHENTICATION = "authentication"
+
+	WEBAUTHN_CHALLENGE_TTL = time.Minute * 5
+	// the passkeys a user can register
+	WEBAUTHN_MAX_CREDENTIALS = 10
+)
+
+var (
+	ErrWebAuthnChallengeInvalid = errors.New("webauthn challenge is invalid")
+	ErrWebAuthnChallengeExpired = errors.New("webauthn challenge is expired")
+	ErrWebAuthnSignCount        = errors.New("webauthn sign count did not increase")
+)
+
+// WebAuthnChallenge is issued by the begin step of a ceremony and consumed by its finish step,
+// the registration challenges belong to the signed in user, the authentication ones to nobody yet
+type WebAuthnChallenge struct {
+	ID        string         `json:"id" db:"id"`
+	Challenge string         `json:"challenge" db:"challenge"`
+	Ceremony  string         `json:"ceremony" db:"ceremony"`
+	UserID    sql.NullString `json:"user_id" db:"user_id"`
+	ExpiresAt time.Time      `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+func (wc *WebAuthnChallenge) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(wc.ID, err)
+}
+
+// Check tells if the challenge was issued for the ceremony of the user, userId is empty for a sign-in
+func (wc *WebAuthnChallenge) Check(ceremony, userId string, now time.Time) error {
+	if wc.Ceremony != ceremony || wc.UserID.String != userId {
+		return ErrWebAuthnChallengeInvalid
+	}
+	if !now.Before(wc.ExpiresAt) {
+		return ErrWebAuthnChallengeExpired
+	}
+	return nil
+}
+
+// WebAuthnCredential is a passkey of a user, CredentialID is the base64url id sent by the authenticator
+// and PublicKey the COSE key the assertions are verified with
+type WebAuthnCredential struct {
+	ID           string       `json:"id" db:"id"`
+	UserID       string       `json:"user_id" db:"user_id"`
+	CredentialID string       `json:"credential_id" db:"credential_id"`
+	PublicKey    []byte       `json:"-" db:"public_key"`
+	SignCount    int64        `json:"-" db:"sign_count"`
+	AAGUID       []byte       `json:"-" db:"aaguid"`
+	Name         string       `json:"name" db:"name"`
+	LastUsedAt   sql.NullTime `json:"last_used_at" db:"last_used_at"`
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (wc *WebAuthnCredential) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(wc.ID, err)
+}
@@ -1,15 +1,81 @@
 package account
 
+import "time"
+
 type AccountCommandRepository interface {
+	Transaction(fn func(repo AccountCommandRepository) error) error
 	InsertUser(u *User) (string, error)
+	UpdateUserProfile(u *User) error
+	UpdateUsername(u *User) error
+	UpdateUserStatus(u *User) error
+	UpdateUserEmailVerified(u *User) error
 	InsertSocialAccount(sa *SocialAccount) (string, error)
+	InsertEmailVerification(ev *EmailVerification) (string, error)
+	ConfirmEmailVerification(ev *EmailVerification) (bool, error)
+	InsertEmailSignIn(es *EmailSignIn) (string, error)
+	IncrementEmailSignInAttempts(es *EmailSignIn) (bool, error)
+	UseEmailSignIn(es *EmailSignIn) (bool, error)
+	InsertCredential(c *Credential) (string, error)
+	UpdateCredentialPasswordHash(c *Credential) error
+	DeleteCredential(userId string) error
+	UpsertPendingUserMFA(m *UserMFA) error
+	EnableUserMFA(m *UserMFA) (bool, error)
+	UseUserMFAStep(m *UserMFA) (bool, error)
+	FailUserMFAAttempt(m *UserMFA) error
+	DeleteUserMFA(userId string) error
+	ReplaceMFARecoveryCodes(userId string, codeHashes []string) error
+	UseMFARecoveryCode(userId, codeHash string) (bool, error)
+	InsertWebAuthnChallenge(wc *WebAuthnChallenge) (string, error)
+	ConsumeWebAuthnChallenge(challenge string) (*WebAuthnChallenge, error)
+	UseWebAuthnChallenge(wc *WebAuthnChallenge) (bool, error)
+	InsertWebAuthnCredential(wc *WebAuthnCredential) (string, error)
+	UpdateWebAuthnCredentialSignCount(wc *WebAuthnCredential) (bool, error)
+	DeleteWebAuthnCredential(userId, id string) (bool, error)
+	DeleteWebAuthnCredentialsOfUser(userId string) error
+	InsertUserRole(userId, role string) (bool, error)
+	DeleteUserRole(userId, role string) (bool, error)
+	InsertSession(s *Session, refreshTokenUUID string) (string, error)
+	RotateSessionRefreshToken(s *Session, refreshTokenUUID string) (bool, error)
+	UseSessionRefreshToken(rt *SessionRefreshToken) (bool, error)
+	RevokeSession(sessionId, reason string) error
+	TouchSession(s *Session) (bool, error)
+	RevokeSessionOfUser(userId, sessionId, reason string) (bool, error)
+	RevokeOtherSessions(userId, keepSessionId, reason string) ([]string, error)
+	RevokeSessionsOfUser(userId, reason string) ([]string, error)
+	InsertPersonalAccessToken(pat *PersonalAccessToken) (string, error)
+	RevokePersonalAccessToken(userId, id string) (bool, error)
+	RevokePersonalAccessTokensOfUser(userId string) error
+	UpdatePersonalAccessTokenLastUsed(id string) error
+	InsertLoginEvent(e *LoginEvent) (string, error)
 }
 
 type AccountQueryRepository interface {
 	FindUserById(id string) (*User, error)
 	FindUserByUsername(username string) (*User, error)
+	FindUserByEmail(email string) (*User, error)
+	FindUserByPreviousUsername(username string) (*User, error)
 	ExistsUserByUsername(username string) (bool, error)
+	ExistsUserByEmail(email string) (bool, error)
+	FindExistingUsernames(usernames []string, releasedBefore time.Time) ([]string, error)
+	ExistsUsernameHistory(username, excludeUserId string, releasedBefore time.Time) (bool, error)
 	FindSocialAccountByUserId(userId string) (*SocialAccount, error)
 	FindSocialAccountBySocialIdAndProvider(socialId, provider string) (*SocialAccount, error)
 	ExistsSocialAccountBySocialIdAndProvider(sodialId, provider string) (bool, error)
+	FindEmailVerificationByTokenHash(tokenHash string) (*EmailVerification, error)
+	FindCredentialByUserId(userId string) (*Credential, error)
+	FindUserMFAByUserId(userId string) (*UserMFA, error)
+	CountUnusedMFARecoveryCodes(userId string) (int, error)
+	FindEmailSignInByTokenHash(tokenHash string) (*EmailSignIn, error)
+	FindPendingEmailSignInByEmail(email string) (*EmailSignIn, error)
+	FindWebAuthnCredentialByCredentialId(credentialId string) (*WebAuthnCredential, error)
+	FindWebAuthnCredentialsByUserId(userId string) ([]*WebAuthnCredential, error)
+	FindRolesByUserId(userId string) ([]string, error)
+	FindSessionById(id string) (*Session, error)
+	FindActiveSessionsByUserId(userId string) ([]*Session, error)
+	FindSessionRefreshTokenByTokenUUID(tokenUUID string) (*SessionRefreshToken, error)
+	FindPersonalAccessTokenByTokenHash(tokenHash string) (*PersonalAccessToken, error)
+	FindPersonalAccessTokensByUserId(userId string) ([]*PersonalAccessToken, error)
+	FindLoginEventsByUserId(userId string, limit int) ([]*LoginEvent, error)
+	ExistsSuccessfulLoginEventByUserId(userId string) (bool, error)
+	ExistsSuccessfulLoginEventByUserIdAndDevice(userId, deviceFingerprint string) (bool, error)
 }
@@ -1,14 +1,62 @@
 package account
 
+import "time"
+
 type AccountCommandService interface {
 	CreateAccount(u *User, sa *SocialAccount) (*Account, error)
+	SyncUserProfile(u *User, originName, photoUrl string) (*User, error)
+	UpdateUserProfile(u *User) (*User, error)
+	ChangeUsername(u *User, username string) (*User, error)
+	ChangeUserStatus(u *User, status, reason string, until time.Time) (*User, error)
+	RequestEmailChange(u *User, email string) (*EmailVerification, string, error)
+	ConfirmEmailChange(ev *EmailVerification) error
+	StartEmailSignIn(email string) (*EmailSignIn, string, string, error)
+	FinishEmailSignIn(es *EmailSignIn, code string) error
+	ClaimUnverifiedUser(u *User) (*User, []string, error)
+	CreatePasswordAccount(u *User, sa *SocialAccount, password string) (*Account, error)
+	ChangePassword(c *Credential, password string) error
+	StartTOTPEnrolment(userId string) (string, error)
+	ConfirmTOTPEnrolment(m *UserMFA, code string) ([]string, error)
+	VerifyTOTP(m *UserMFA, code string) error
+	VerifyMFARecoveryCode(m *UserMFA, code string) error
+	RegenerateMFARecoveryCodes(userId string) ([]string, error)
+	DisableMFA(userId string) error
+	StartWebAuthnCeremony(ceremony, userId string) (*WebAuthnChallenge, error)
+	ConsumeWebAuthnChallenge(ceremony, challenge, userId string) (*WebAuthnChallenge, error)
+	UseWebAuthnSignInChallenge(challenge string, expiresAt time.Time) error
+	AddWebAuthnCredential(wc *WebAuthnCredential) (*WebAuthnCredential, error)
+	UseWebAuthnCredential(wc *WebAuthnCredential, signCount uint32) error
+	DeleteWebAuthnCredential(userId, id string) (bool, error)
+	GrantRole(userId, role string) (bool, []string, error)
+	RevokeRole(userId, role string) (bool, []string, error)
+	RevokeSession(userId, sessionId string) (bool, error)
+	RevokeOtherSessions(userId, keepSessionId string) ([]string, error)
+	CreatePersonalAccessToken(userId, name string, scopes []string, ttl time.Duration) (*PersonalAccessToken, string, error)
+	RevokePersonalAccessToken(userId, id string) (bool, error)
 }
 
 type AccountQueryService interface {
 	GetAccountByUserId(userId string) (*Account, error)
 	GetUserById(userId string) (*User, error)
 	GetUserByUsername(username string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	GetUserByCurrentOrPreviousUsername(username string) (*User, error)
 	GetExistsUserByUsername(username string) (bool, error)
+	GetExistsUserByEmail(email string) (bool, error)
+	GetUsernameUnavailableReason(username, userId string) (string, error)
+	GetUsernameSuggestions(username, displayName string, limit int) ([]string, error)
 	GetSocialAccountBySocialIdAndProvider(socialId, provider string) (*SocialAccount, error)
 	GetExistsSocialAccountBySocialIdAndProvider(socialId, provider string) (bool, error)
+	GetEmailVerificationByToken(token string) (*EmailVerification, error)
+	GetCredentialByUserId(userId string) (*Credential, error)
+	GetUserMFAByUserId(userId string) (*UserMFA, error)
+	GetUnusedMFARecoveryCodesCount(userId string) (int, error)
+	GetEmailSignInByToken(token string) (*EmailSignIn, error)
+	GetPendingEmailSignInByEmail(email string) (*EmailSignIn, error)
+	GetWebAuthnCredentialByCredentialId(credentialId string) (*WebAuthnCredential, error)
+	GetWebAuthnCredentialsByUserId(userId string) ([]*WebAuthnCredential, error)
+	GetRolesByUserId(userId string) ([]string, error)
+	GetActiveSessionsByUserId(userId string) ([]*Session, error)
+	GetPersonalAccessTokensByUserId(userId string) ([]*PersonalAccessToken, error)
+	GetLoginEventsByUserId(userId string) ([]*LoginEvent, error)
 }
@@ -0,0 +1,71 @@
+package account_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EmailSignIn_Check_IsValid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	es := &account.EmailSignIn{
+		Attempts:  account.EMAIL_SIGN_IN_MAX_ATTEMPTS - 1,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	assert.Nil(es.Check(now))
+}
+
+func Test_EmailSignIn_Check_UsedIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	es := &account.EmailSignIn{
+		ExpiresAt: now.Add(time.Minute),
+		UsedAt:    sql.NullTime{Time: now, Valid: true},
+	}
+
+	assert.Equal(account.ErrEmailSignInInvalid, es.Check(now))
+}
+
+func Test_EmailSignIn_Check_TooManyAttemptsIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	es := &account.EmailSignIn{
+		Attempts:  account.EMAIL_SIGN_IN_MAX_ATTEMPTS,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	assert.Equal(account.ErrEmailSignInInvalid, es.Check(now))
+}
+
+func Test_EmailSignIn_Check_IsExpired(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	es := &account.EmailSignIn{
+		ExpiresAt: now.Add(-time.Second),
+	}
+
+	assert.Equal(account.ErrEmailSignInExpired, es.Check(now))
+}
+
+func Test_EmailSignIn_MatchCodeHash(t *testing.T) {
+	assert := assert.New(t)
+	es := &account.EmailSignIn{CodeHash: "hash"}
+
+	assert.True(es.MatchCodeHash("hash"))
+	assert.False(es.MatchCodeHash("other"))
+	assert.False(es.MatchCodeHash(""))
+}
+
+func Test_EmailSignIn_CanResend(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	es := &account.EmailSignIn{CreatedAt: now}
+
+	assert.False(es.CanResend(now.Add(time.Second)))
+	assert.True(es.CanResend(now.Add(account.EMAIL_SIGN_IN_RESEND_INTERVAL)))
+}
@@ -0,0 +1,56 @@
+package account_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EmailVerification_Check_IsValid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	ev := &account.EmailVerification{
+		UserID:    uuid.NewString(),
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	assert.Nil(ev.Check(ev.UserID, now))
+}
+
+func Test_EmailVerification_Check_OtherUserIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	ev := &account.EmailVerification{
+		UserID:    uuid.NewString(),
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	assert.Equal(account.ErrEmailVerificationInvalid, ev.Check(uuid.NewString(), now))
+}
+
+func Test_EmailVerification_Check_UsedIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	ev := &account.EmailVerification{
+		UserID:    uuid.NewString(),
+		ExpiresAt: now.Add(time.Hour),
+		UsedAt:    sql.NullTime{Time: now, Valid: true},
+	}
+
+	assert.Equal(account.ErrEmailVerificationInvalid, ev.Check(ev.UserID, now))
+}
+
+func Test_EmailVerification_Check_IsExpired(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	ev := &account.EmailVerification{
+		UserID:    uuid.NewString(),
+		ExpiresAt: now,
+	}
+
+	assert.Equal(account.ErrEmailVerificationExpired, ev.Check(ev.UserID, now))
+}
@@ -0,0 +1,21 @@
+package account_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoginEvent_SetDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	e := &account.LoginEvent{Outcome: account.LOGIN_EVENT_OUTCOME_SUCCESS}
+	e.SetDevice(strings.Repeat("a", account.SESSION_USER_AGENT_MAX_LENGTH+1), "", "fingerprint")
+
+	assert.True(e.IsSuccess())
+	assert.Len(e.UserAgent.String, account.SESSION_USER_AGENT_MAX_LENGTH)
+	assert.False(e.IP.Valid)
+	assert.Equal("fingerprint", e.DeviceFingerprint)
+}
@@ -0,0 +1,46 @@
+package account_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PersonalAccessToken_SetScopes(t *testing.T) {
+	assert := assert.New(t)
+	pat := &account.PersonalAccessToken{}
+
+	assert.Nil(pat.SetScopes([]string{account.SCOPE_DATA_READ, account.SCOPE_DATA_WRITE, account.SCOPE_DATA_READ}))
+	assert.Equal("data:read data:write", pat.Scopes)
+	assert.Equal([]string{account.SCOPE_DATA_READ, account.SCOPE_DATA_WRITE}, pat.ScopeList())
+
+	assert.ErrorIs(pat.SetScopes(nil), account.ErrPersonalAccessTokenBadScope)
+	assert.ErrorIs(pat.SetScopes([]string{"admin"}), account.ErrPersonalAccessTokenBadScope)
+}
+
+func Test_PersonalAccessToken_Check(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	pat := &account.PersonalAccessToken{ExpiresAt: now.Add(time.Hour)}
+	assert.Nil(pat.Check(now))
+	assert.ErrorIs(pat.Check(now.Add(time.Hour)), account.ErrPersonalAccessTokenExpired)
+
+	pat.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	assert.ErrorIs(pat.Check(now), account.ErrPersonalAccessTokenInvalid)
+}
+
+func Test_PersonalAccessToken_NeedsLastUsed(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	pat := &account.PersonalAccessToken{}
+	assert.True(pat.NeedsLastUsed(now))
+
+	pat.LastUsedAt = sql.NullTime{Time: now, Valid: true}
+	assert.False(pat.NeedsLastUsed(now.Add(time.Second)))
+	assert.True(pat.NeedsLastUsed(now.Add(account.PERSONAL_ACCESS_TOKEN_LAST_USED_INTERVAL)))
+}
@@ -0,0 +1,51 @@
+package account_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Session_IsActive(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	s := &account.Session{ExpiresAt: now.Add(time.Hour)}
+	assert.True(s.IsActive(now))
+	assert.False(s.IsActive(now.Add(time.Hour)))
+
+	s.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	assert.False(s.IsActive(now))
+}
+
+func Test_SessionRefreshToken_IsReuseInGrace(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	rt := &account.SessionRefreshToken{}
+	assert.False(rt.IsReuseInGrace(now))
+
+	rt.UsedAt = sql.NullTime{Time: now.Add(-time.Second), Valid: true}
+	assert.True(rt.IsReuseInGrace(now))
+
+	rt.UsedAt = sql.NullTime{Time: now.Add(-time.Minute), Valid: true}
+	assert.False(rt.IsReuseInGrace(now))
+}
+
+func Test_Session_SetDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &account.Session{}
+	s.SetDevice("", "")
+	assert.False(s.UserAgent.Valid)
+	assert.False(s.IP.Valid)
+
+	s.SetDevice(strings.Repeat("가", account.SESSION_USER_AGENT_MAX_LENGTH+1), "203.0.113.7")
+	assert.True(s.UserAgent.Valid)
+	assert.Equal(strings.Repeat("가", account.SESSION_USER_AGENT_MAX_LENGTH), s.UserAgent.String)
+	assert.Equal("203.0.113.7", s.IP.String)
+}
@@ -1,10 +1,13 @@
 package account_test
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,6 +23,16 @@ func Test_User_IsExist_IsTrue(t *testing.T) {
 	assert.True(exist)
 }
 
+func Test_User_IsEmailVerified(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &account.User{}
+	assert.False(u.IsEmailVerified())
+
+	u.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	assert.True(u.IsEmailVerified())
+}
+
 func Test_User_ValidateUsername_ValidIsTrue(t *testing.T) {
 	assert := assert.New(t)
 
@@ -39,3 +52,57 @@ func Test_User_ValidateUsername_ValidIsFalse(t *testing.T) {
 
 	assert.False(valid)
 }
+
+func Test_User_SyncProfile_ChangedIsTrue(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &account.User{
+		OriginName:     utils.NewNullString("old name"),
+		PhotoUrl:       utils.NewNullString("https://example.com/old.png"),
+		SyncOriginName: true,
+		SyncPhotoUrl:   true,
+	}
+	now := time.Now()
+	changed := u.SyncProfile("new name", "https://example.com/new.png", now)
+
+	assert.True(changed)
+	assert.Equal("new name", u.OriginName.String)
+	assert.Equal("https://example.com/new.png", u.PhotoUrl.String)
+	assert.Equal(now, u.ProfileSyncedAt.Time)
+}
+
+func Test_User_SyncProfile_CustomisedFieldIsKept(t *testing.T) {
+	assert := assert.New(t)
+
+	u := &account.User{
+		OriginName:     utils.NewNullString("old name"),
+		SyncOriginName: true,
+		SyncPhotoUrl:   true,
+	}
+	u.CustomizePhotoUrl("https://example.com/custom.png")
+	changed := u.SyncProfile("old name", "https://example.com/new.png", time.Now())
+
+	assert.False(changed)
+	assert.Equal("https://example.com/custom.png", u.PhotoUrl.String)
+	assert.True(u.ProfileSyncedAt.Valid)
+}
+
+func Test_User_CheckStatus(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	u := &account.User{Status: account.USER_STATUS_ACTIVE}
+	assert.Nil(u.CheckStatus(now))
+
+	u.ChangeStatus(account.USER_STATUS_SUSPENDED, "spam", now.Add(time.Hour))
+	assert.Equal(account.ErrUserSuspended, u.CheckStatus(now))
+	assert.Equal("spam", u.StatusReason.String)
+
+	// the suspension is over without the status being reset
+	assert.Nil(u.CheckStatus(now.Add(time.Hour)))
+
+	u.ChangeStatus(account.USER_STATUS_BANNED, "", now.Add(time.Hour))
+	assert.Equal(account.ErrUserBanned, u.CheckStatus(now.Add(time.Hour*24*365)))
+	assert.False(u.SuspendedUntil.Valid)
+	assert.False(u.StatusReason.Valid)
+}
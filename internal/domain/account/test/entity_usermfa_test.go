@@ -0,0 +1,37 @@
+package account_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserMFA_IsEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	pending := &account.UserMFA{UserID: uuid.NewString()}
+	enabled := &account.UserMFA{
+		UserID:    uuid.NewString(),
+		EnabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+
+	assert.False(pending.IsEnabled())
+	assert.True(enabled.IsEnabled())
+}
+
+func Test_UserMFA_IsLocked(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	m := &account.UserMFA{
+		LockedUntil: sql.NullTime{Time: now.Add(time.Minute), Valid: true},
+	}
+
+	assert.True(m.IsLocked(now))
+	assert.False(m.IsLocked(now.Add(time.Minute)))
+	assert.False((&account.UserMFA{}).IsLocked(now))
+}
@@ -0,0 +1,17 @@
+package account_test
+
+import (
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsRole(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(account.IsRole(account.ROLE_ADMIN))
+	assert.False(account.IsRole("Admin"))
+	assert.False(account.IsRole("root"))
+	assert.False(account.IsRole(""))
+}
@@ -0,0 +1,63 @@
+package account_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WebAuthnChallenge_Check_IsValid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	userId := uuid.NewString()
+	registration := &account.WebAuthnChallenge{
+		Ceremony:  account.WEBAUTHN_CEREMONY_REGISTRATION,
+		UserID:    sql.NullString{String: userId, Valid: true},
+		ExpiresAt: now.Add(time.Minute),
+	}
+	authentication := &account.WebAuthnChallenge{
+		Ceremony:  account.WEBAUTHN_CEREMONY_AUTHENTICATION,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	assert.Nil(registration.Check(account.WEBAUTHN_CEREMONY_REGISTRATION, userId, now))
+	assert.Nil(authentication.Check(account.WEBAUTHN_CEREMONY_AUTHENTICATION, "", now))
+}
+
+func Test_WebAuthnChallenge_Check_OtherCeremonyIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	wc := &account.WebAuthnChallenge{
+		Ceremony:  account.WEBAUTHN_CEREMONY_AUTHENTICATION,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	assert.Equal(account.ErrWebAuthnChallengeInvalid, wc.Check(account.WEBAUTHN_CEREMONY_REGISTRATION, "", now))
+}
+
+func Test_WebAuthnChallenge_Check_OtherUserIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	wc := &account.WebAuthnChallenge{
+		Ceremony:  account.WEBAUTHN_CEREMONY_REGISTRATION,
+		UserID:    sql.NullString{String: uuid.NewString(), Valid: true},
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	assert.Equal(account.ErrWebAuthnChallengeInvalid, wc.Check(account.WEBAUTHN_CEREMONY_REGISTRATION, uuid.NewString(), now))
+}
+
+func Test_WebAuthnChallenge_Check_IsExpired(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	wc := &account.WebAuthnChallenge{
+		Ceremony:  account.WEBAUTHN_CEREMONY_AUTHENTICATION,
+		ExpiresAt: now.Add(-time.Second),
+	}
+
+	assert.Equal(account.ErrWebAuthnChallengeExpired, wc.Check(account.WEBAUTHN_CEREMONY_AUTHENTICATION, "", now))
+}
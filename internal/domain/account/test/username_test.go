@@ -0,0 +1,33 @@
+package account_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsReservedUsername_IsCaseInsensitive(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(account.IsReservedUsername("Admin", nil))
+	assert.True(account.IsReservedUsername("HEALTH", nil))
+	assert.True(account.IsReservedUsername("Madrebot", []string{"madrebot"}))
+	assert.False(account.IsReservedUsername("juntae", nil))
+}
+
+func Test_UsernameCandidates_AreValidUsernames(t *testing.T) {
+	assert := assert.New(t)
+
+	candidates := account.UsernameCandidates("juntae", "Juntae Kim-Lee The Longest Name")
+
+	assert.Equal("juntae", candidates[0])
+	assert.Contains(candidates, "juntaekimleethelonge")
+	for _, c := range candidates {
+		u := &account.User{Username: c}
+		valid, _ := u.ValidateUsername()
+		assert.True(valid, c)
+		assert.True(strings.HasPrefix(c, "juntae"), c)
+	}
+}
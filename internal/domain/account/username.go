@@ -0,0 +1,88 @@
+package account
+
+import (
+	"crypto/rand"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	USERNAME_MAX_LENGTH = 20
+
+	USERNAME_UNAVAILABLE_INVALID  = "invalid"
+	USERNAME_UNAVAILABLE_RESERVED = "reserved"
+	USERNAME_UNAVAILABLE_TAKEN    = "taken"
+	// left by another user during the cool-down, so nobody can impersonate them
+	USERNAME_UNAVAILABLE_RECENTLY_USED = "recently_used"
+)
+
+var (
+	// paths, roles and names people could take for the service itself
+	defaultReservedUsernames = []string{
+		"admin", "administrator", "api", "auth", "availability", "health",
+		"help", "madre", "me", "moderator", "root", "security", "sign-in",
+		"signin", "signup", "staff", "support", "system", "user", "users", "www",
+	}
+
+	notUsernameCharacters = regexp.MustCompile("[^a-zA-Z0-9]")
+)
+
+// IsReservedUsername is case insensitive,
+// reserved is added to the usernames reserved by default
+func IsReservedUsername(username string, reserved []string) bool {
+	for _, list := range [][]string{defaultReservedUsernames, reserved} {
+		for _, name := range list {
+			if strings.EqualFold(username, strings.TrimSpace(name)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UsernameCandidates derives usernames from the requested username and the display name of the provider,
+// the candidates still have to be checked for availability
+func UsernameCandidates(username, displayName string) []string {
+	bases := []string{}
+	add := func(s string) {
+		s = strings.ToLower(notUsernameCharacters.ReplaceAllString(s, ""))
+		if len(s) > USERNAME_MAX_LENGTH {
+			s = s[:USERNAME_MAX_LENGTH]
+		}
+		if s == "" {
+			return
+		}
+		for _, b := range bases {
+			if b == s {
+				return
+			}
+		}
+		bases = append(bases, s)
+	}
+
+	add(username)
+	words := strings.Fields(displayName)
+	add(strings.Join(words, ""))
+	if len(words) > 0 {
+		add(words[0])
+	}
+
+	candidates := append([]string{}, bases...)
+	for _, b := range bases {
+		for i := 0; i < 3; i++ {
+			n, err := rand.Int(rand.Reader, big.NewInt(9000))
+			if err != nil {
+				continue
+			}
+			suffix := strconv.FormatInt(n.Int64()+1000, 10)
+			if len(b)+len(suffix) > USERNAME_MAX_LENGTH {
+				b = b[:USERNAME_MAX_LENGTH-len(suffix)]
+			}
+			candidates = append(candidates, b+suffix)
+		}
+	}
+
+	return candidates
+}
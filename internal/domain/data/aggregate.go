@@ -1,3 +1,23 @@
 package data
 
-type Data struct{}
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/common"
+)
+
+type Data struct {
+	ID          string         `json:"id" db:"id"`
+	UserID      string         `json:"user_id" db:"user_id"`
+	FileUrl     string         `json:"file_url" db:"file_url"`
+	Title       string         `json:"title" db:"title"`
+	Description sql.NullString `json:"description" db:"description"`
+	IsPublic    bool           `json:"is_public" db:"is_public"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+func (d *Data) IsExist(err error) (bool, error) {
+	return common.IsExistEntity(d.ID, err)
+}
@@ -1,5 +1,12 @@
 package data
 
-type DataCommandRepository interface{}
+type DataCommandRepository interface {
+	InsertData(d *Data) (string, error)
+}
 
-type DataQueryRepository interface{}
+type DataQueryRepository interface {
+	FindPublicDataByUserId(userId string, limit, offset int) ([]*Data, error)
+	CountPublicDataByUserId(userId string) (int, error)
+	FindDataByUserId(userId string, limit, offset int) ([]*Data, error)
+	CountDataByUserId(userId string) (int, error)
+}
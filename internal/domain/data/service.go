@@ -1,5 +1,10 @@
 package data
 
-type DataCommandService interface{}
+type DataCommandService interface {
+	CreateData(d *Data) (*Data, error)
+}
 
-type DataQueryService interface{}
+type DataQueryService interface {
+	GetPublicDataByUserId(userId string, limit, offset int) ([]*Data, int, error)
+	GetDataByUserId(userId string, limit, offset int) ([]*Data, int, error)
+}
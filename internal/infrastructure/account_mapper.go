@@ -6,23 +6,38 @@ type AccountMapper struct{}
 
 func (am AccountMapper) ToUserEntity(u *account.User) *account.User {
 	return &account.User{
-		ID:         u.ID,
-		Email:      u.Email,
-		OriginName: u.OriginName,
-		Username:   u.Username,
-		PhotoUrl:   u.PhotoUrl,
-		CreatedAt:  u.CreatedAt,
-		UpdatedAt:  u.UpdatedAt,
+		ID:              u.ID,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		OriginName:      u.OriginName,
+		Username:        u.Username,
+		PhotoUrl:        u.PhotoUrl,
+		SyncOriginName:  u.SyncOriginName,
+		SyncPhotoUrl:    u.SyncPhotoUrl,
+		ProfileSyncedAt: u.ProfileSyncedAt,
+		Status:          u.Status,
+		StatusReason:    u.StatusReason,
+		SuspendedUntil:  u.SuspendedUntil,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
 	}
 }
 
 func (am AccountMapper) ToUserModel(u *account.User) *account.User {
 	return &account.User{
-		Email:      u.Email,
-		OriginName: u.OriginName,
-		Username:   u.Username,
-		PhotoUrl:   u.PhotoUrl,
-		UpdatedAt:  u.UpdatedAt,
+		ID:              u.ID,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		OriginName:      u.OriginName,
+		Username:        u.Username,
+		PhotoUrl:        u.PhotoUrl,
+		SyncOriginName:  u.SyncOriginName,
+		SyncPhotoUrl:    u.SyncPhotoUrl,
+		ProfileSyncedAt: u.ProfileSyncedAt,
+		Status:          u.Status,
+		StatusReason:    u.StatusReason,
+		SuspendedUntil:  u.SuspendedUntil,
+		UpdatedAt:       u.UpdatedAt,
 	}
 }
 
@@ -44,3 +59,223 @@ func (am AccountMapper) ToSocialAccountModel(sa *account.SocialAccount) *account
 		SocialID: sa.SocialID,
 	}
 }
+
+func (am AccountMapper) ToEmailVerificationEntity(ev *account.EmailVerification) *account.EmailVerification {
+	return &account.EmailVerification{
+		ID:        ev.ID,
+		UserID:    ev.UserID,
+		Email:     ev.Email,
+		TokenHash: ev.TokenHash,
+		ExpiresAt: ev.ExpiresAt,
+		UsedAt:    ev.UsedAt,
+		CreatedAt: ev.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToEmailVerificationModel(ev *account.EmailVerification) *account.EmailVerification {
+	return &account.EmailVerification{
+		ID:        ev.ID,
+		UserID:    ev.UserID,
+		Email:     ev.Email,
+		TokenHash: ev.TokenHash,
+		ExpiresAt: ev.ExpiresAt,
+	}
+}
+
+func (am AccountMapper) ToEmailSignInEntity(es *account.EmailSignIn) *account.EmailSignIn {
+	return &account.EmailSignIn{
+		ID:        es.ID,
+		Email:     es.Email,
+		TokenHash: es.TokenHash,
+		CodeHash:  es.CodeHash,
+		Attempts:  es.Attempts,
+		ExpiresAt: es.ExpiresAt,
+		UsedAt:    es.UsedAt,
+		CreatedAt: es.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToEmailSignInModel(es *account.EmailSignIn) *account.EmailSignIn {
+	return &account.EmailSignIn{
+		ID:        es.ID,
+		Email:     es.Email,
+		TokenHash: es.TokenHash,
+		CodeHash:  es.CodeHash,
+		ExpiresAt: es.ExpiresAt,
+	}
+}
+
+func (am AccountMapper) ToCredentialEntity(c *account.Credential) *account.Credential {
+	return &account.Credential{
+		ID:           c.ID,
+		UserID:       c.UserID,
+		PasswordHash: c.PasswordHash,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+}
+
+func (am AccountMapper) ToCredentialModel(c *account.Credential) *account.Credential {
+	return &account.Credential{
+		UserID:       c.UserID,
+		PasswordHash: c.PasswordHash,
+	}
+}
+
+func (am AccountMapper) ToUserMFAEntity(m *account.UserMFA) *account.UserMFA {
+	return &account.UserMFA{
+		UserID:         m.UserID,
+		TOTPSecret:     m.TOTPSecret,
+		EnabledAt:      m.EnabledAt,
+		LastUsedStep:   m.LastUsedStep,
+		FailedAttempts: m.FailedAttempts,
+		LockedUntil:    m.LockedUntil,
+		CreatedAt:      m.CreatedAt,
+		UpdatedAt:      m.UpdatedAt,
+	}
+}
+
+func (am AccountMapper) ToUserMFAModel(m *account.UserMFA) *account.UserMFA {
+	return &account.UserMFA{
+		UserID:       m.UserID,
+		TOTPSecret:   m.TOTPSecret,
+		LastUsedStep: m.LastUsedStep,
+	}
+}
+
+func (am AccountMapper) ToWebAuthnChallengeEntity(wc *account.WebAuthnChallenge) *account.WebAuthnChallenge {
+	return &account.WebAuthnChallenge{
+		ID:        wc.ID,
+		Challenge: wc.Challenge,
+		Ceremony:  wc.Ceremony,
+		UserID:    wc.UserID,
+		ExpiresAt: wc.ExpiresAt,
+		CreatedAt: wc.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToWebAuthnChallengeModel(wc *account.WebAuthnChallenge) *account.WebAuthnChallenge {
+	return &account.WebAuthnChallenge{
+		Challenge: wc.Challenge,
+		Ceremony:  wc.Ceremony,
+		UserID:    wc.UserID,
+		ExpiresAt: wc.ExpiresAt,
+	}
+}
+
+func (am AccountMapper) ToWebAuthnCredentialEntity(wc *account.WebAuthnCredential) *account.WebAuthnCredential {
+	return &account.WebAuthnCredential{
+		ID:           wc.ID,
+		UserID:       wc.UserID,
+		CredentialID: wc.CredentialID,
+		PublicKey:    wc.PublicKey,
+		SignCount:    wc.SignCount,
+		AAGUID:       wc.AAGUID,
+		Name:         wc.Name,
+		LastUsedAt:   wc.LastUsedAt,
+		CreatedAt:    wc.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToWebAuthnCredentialModel(wc *account.WebAuthnCredential) *account.WebAuthnCredential {
+	return &account.WebAuthnCredential{
+		ID:           wc.ID,
+		UserID:       wc.UserID,
+		CredentialID: wc.CredentialID,
+		PublicKey:    wc.PublicKey,
+		SignCount:    wc.SignCount,
+		AAGUID:       wc.AAGUID,
+		Name:         wc.Name,
+	}
+}
+
+func (am AccountMapper) ToSessionEntity(s *account.Session) *account.Session {
+	return &account.Session{
+		ID:            s.ID,
+		UserID:        s.UserID,
+		ExpiresAt:     s.ExpiresAt,
+		RevokedAt:     s.RevokedAt,
+		RevokedReason: s.RevokedReason,
+		UserAgent:     s.UserAgent,
+		IP:            s.IP,
+		LastSeenAt:    s.LastSeenAt,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+}
+
+func (am AccountMapper) ToSessionModel(s *account.Session) *account.Session {
+	return &account.Session{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		ExpiresAt: s.ExpiresAt,
+		UserAgent: s.UserAgent,
+		IP:        s.IP,
+	}
+}
+
+func (am AccountMapper) ToSessionRefreshTokenEntity(rt *account.SessionRefreshToken) *account.SessionRefreshToken {
+	return &account.SessionRefreshToken{
+		ID:        rt.ID,
+		SessionID: rt.SessionID,
+		TokenUUID: rt.TokenUUID,
+		UsedAt:    rt.UsedAt,
+		CreatedAt: rt.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToPersonalAccessTokenEntity(pat *account.PersonalAccessToken) *account.PersonalAccessToken {
+	return &account.PersonalAccessToken{
+		ID:          pat.ID,
+		UserID:      pat.UserID,
+		Name:        pat.Name,
+		TokenPrefix: pat.TokenPrefix,
+		TokenHash:   pat.TokenHash,
+		Scopes:      pat.Scopes,
+		ExpiresAt:   pat.ExpiresAt,
+		LastUsedAt:  pat.LastUsedAt,
+		RevokedAt:   pat.RevokedAt,
+		CreatedAt:   pat.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToPersonalAccessTokenModel(pat *account.PersonalAccessToken) *account.PersonalAccessToken {
+	return &account.PersonalAccessToken{
+		ID:          pat.ID,
+		UserID:      pat.UserID,
+		Name:        pat.Name,
+		TokenPrefix: pat.TokenPrefix,
+		TokenHash:   pat.TokenHash,
+		Scopes:      pat.Scopes,
+		ExpiresAt:   pat.ExpiresAt,
+	}
+}
+
+func (am AccountMapper) ToLoginEventEntity(e *account.LoginEvent) *account.LoginEvent {
+	return &account.LoginEvent{
+		ID:                e.ID,
+		UserID:            e.UserID,
+		Provider:          e.Provider,
+		Outcome:           e.Outcome,
+		Reason:            e.Reason,
+		UserAgent:         e.UserAgent,
+		IP:                e.IP,
+		DeviceFingerprint: e.DeviceFingerprint,
+		NewDevice:         e.NewDevice,
+		CreatedAt:         e.CreatedAt,
+	}
+}
+
+func (am AccountMapper) ToLoginEventModel(e *account.LoginEvent) *account.LoginEvent {
+	return &account.LoginEvent{
+		ID:                e.ID,
+		UserID:            e.UserID,
+		Provider:          e.Provider,
+		Outcome:           e.Outcome,
+		Reason:            e.Reason,
+		UserAgent:         e.UserAgent,
+		IP:                e.IP,
+		DeviceFingerprint: e.DeviceFingerprint,
+		NewDevice:         e.NewDevice,
+	}
+}
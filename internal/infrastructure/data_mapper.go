@@ -1,3 +1,28 @@
 package infrastructure
 
+import "github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+
 type DataMapper struct{}
+
+func (dm DataMapper) ToDataEntity(d *data.Data) *data.Data {
+	return &data.Data{
+		ID:          d.ID,
+		UserID:      d.UserID,
+		FileUrl:     d.FileUrl,
+		Title:       d.Title,
+		Description: d.Description,
+		IsPublic:    d.IsPublic,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+	}
+}
+
+func (dm DataMapper) ToDataModel(d *data.Data) *data.Data {
+	return &data.Data{
+		UserID:      d.UserID,
+		FileUrl:     d.FileUrl,
+		Title:       d.Title,
+		Description: d.Description,
+		IsPublic:    d.IsPublic,
+	}
+}
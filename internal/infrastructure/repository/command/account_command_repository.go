@@ -1,10 +1,15 @@
 package commandrepository
 
 import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
 	"github.com/rlawnsxo131/madre-server-v3/internal/infrastructure"
+	"github.com/rlawnsxo131/madre-server-v3/utils"
 )
 
 type accountCommandRepository struct {
@@ -16,11 +21,18 @@ func NewAccountCommandRepository(db rdb.Database) account.AccountCommandReposito
 	return &accountCommandRepository{db, infrastructure.AccountMapper{}}
 }
 
+// Transaction runs fn with a repository whose queries are in one transaction
+func (r *accountCommandRepository) Transaction(fn func(repo account.AccountCommandRepository) error) error {
+	return r.db.Transaction(func(tx rdb.Database) error {
+		return fn(&accountCommandRepository{tx, r.mapper})
+	})
+}
+
 func (r *accountCommandRepository) InsertUser(u *account.User) (string, error) {
 	var id string
 
-	query := "INSERT INTO public.user(email, origin_name, username, photo_url)" +
-		" VALUES(:email, :origin_name, :username, :photo_url)" +
+	query := "INSERT INTO public.user(email, email_verified_at, origin_name, username, photo_url, sync_origin_name, sync_photo_url, profile_synced_at)" +
+		" VALUES(:email, :email_verified_at, :origin_name, :username, :photo_url, :sync_origin_name, :sync_photo_url, :profile_synced_at)" +
 		" RETURNING id"
 
 	err := r.db.PrepareNamedGet(
@@ -35,6 +47,70 @@ func (r *accountCommandRepository) InsertUser(u *account.User) (string, error) {
 	return id, nil
 }
 
+func (r *accountCommandRepository) UpdateUserProfile(u *account.User) error {
+	query := "UPDATE public.user" +
+		" SET origin_name = :origin_name, photo_url = :photo_url," +
+		" sync_origin_name = :sync_origin_name, sync_photo_url = :sync_photo_url," +
+		" profile_synced_at = :profile_synced_at, updated_at = now()" +
+		" WHERE id = :id"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToUserModel(u))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpdateUserProfile")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) UpdateUserStatus(u *account.User) error {
+	query := "UPDATE public.user" +
+		" SET status = :status, status_reason = :status_reason," +
+		" suspended_until = :suspended_until, updated_at = now()" +
+		" WHERE id = :id"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToUserModel(u))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpdateUserStatus")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) UpdateUserEmailVerified(u *account.User) error {
+	query := "UPDATE public.user" +
+		" SET email_verified_at = now(), updated_at = now()" +
+		" WHERE id = :id"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToUserModel(u))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpdateUserEmailVerified")
+	}
+
+	return nil
+}
+
+// UpdateUsername keeps the previous username in username_history in the same statement,
+// a change of case only is not recorded
+func (r *accountCommandRepository) UpdateUsername(u *account.User) error {
+	query := "WITH previous AS (" +
+		" SELECT id, username FROM public.user WHERE id = :id FOR UPDATE" +
+		"), renamed AS (" +
+		" UPDATE public.user u SET username = :username, updated_at = now()" +
+		" FROM previous WHERE u.id = previous.id" +
+		" RETURNING previous.id, previous.username" +
+		")" +
+		" INSERT INTO public.username_history(user_id, username)" +
+		" SELECT id, username FROM renamed" +
+		" WHERE lower(username) <> lower(:username)"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToUserModel(u))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpdateUsername")
+	}
+
+	return nil
+}
+
 func (r *accountCommandRepository) InsertSocialAccount(sa *account.SocialAccount) (string, error) {
 	var id string
 
@@ -53,3 +129,780 @@ func (r *accountCommandRepository) InsertSocialAccount(sa *account.SocialAccount
 
 	return id, err
 }
+
+// InsertEmailVerification discards the pending verifications of the user,
+// only the link of the last requested email works
+func (r *accountCommandRepository) InsertEmailVerification(ev *account.EmailVerification) (string, error) {
+	var id string
+
+	query := "WITH discarded AS (" +
+		" DELETE FROM public.email_verification WHERE user_id = :user_id AND used_at IS NULL" +
+		")" +
+		" INSERT INTO public.email_verification(user_id, email, token_hash, expires_at)" +
+		" VALUES(:user_id, :email, :token_hash, :expires_at)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToEmailVerificationModel(ev),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertEmailVerification")
+	}
+
+	return id, nil
+}
+
+// ConfirmEmailVerification uses the verification and changes the email in the same statement,
+// it returns false when the verification was used or expired meanwhile
+func (r *accountCommandRepository) ConfirmEmailVerification(ev *account.EmailVerification) (bool, error) {
+	query := "WITH used AS (" +
+		" UPDATE public.email_verification SET used_at = now()" +
+		" WHERE id = :id AND used_at IS NULL AND expires_at > now()" +
+		" RETURNING user_id, email" +
+		")" +
+		" UPDATE public.user u SET email = used.email, email_verified_at = now(), updated_at = now()" +
+		" FROM used WHERE u.id = used.user_id"
+
+	result, err := r.db.NamedExec(query, r.mapper.ToEmailVerificationModel(ev))
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository ConfirmEmailVerification")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository ConfirmEmailVerification RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// InsertEmailSignIn expires the pending sign-ins of the email,
+// only the link and code of the last mail work. The rows are kept so that
+// their attempts still count against the email.
+func (r *accountCommandRepository) InsertEmailSignIn(es *account.EmailSignIn) (string, error) {
+	var id string
+
+	query := "WITH discarded AS (" +
+		" UPDATE public.email_sign_in SET expires_at = now()" +
+		" WHERE lower(email) = lower(:email) AND used_at IS NULL AND expires_at > now()" +
+		")" +
+		" INSERT INTO public.email_sign_in(email, token_hash, code_hash, expires_at)" +
+		" VALUES(:email, :token_hash, :code_hash, :expires_at)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToEmailSignInModel(es),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertEmailSignIn")
+	}
+
+	return id, nil
+}
+
+// IncrementEmailSignInAttempts counts a guess of the code before it is compared,
+// it returns false when the sign-in or its email has no guess left
+func (r *accountCommandRepository) IncrementEmailSignInAttempts(es *account.EmailSignIn) (bool, error) {
+	var attempts int
+
+	query := "UPDATE public.email_sign_in" +
+		" SET attempts = attempts + 1" +
+		" WHERE id = :id AND used_at IS NULL" +
+		fmt.Sprintf(" AND attempts < %d", account.EMAIL_SIGN_IN_MAX_ATTEMPTS) +
+		" AND (" +
+		" SELECT COALESCE(SUM(attempts), 0) FROM public.email_sign_in" +
+		" WHERE lower(email) = lower(:email) AND used_at IS NULL" +
+		fmt.Sprintf(" AND created_at > now() - interval '%d seconds'", int(account.EMAIL_SIGN_IN_ATTEMPT_WINDOW.Seconds())) +
+		fmt.Sprintf(") < %d", account.EMAIL_SIGN_IN_MAX_ATTEMPTS_PER_EMAIL) +
+		" RETURNING attempts"
+
+	err := r.db.PrepareNamedGet(
+		&attempts,
+		query,
+		r.mapper.ToEmailSignInModel(es),
+	)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository IncrementEmailSignInAttempts")
+	}
+	es.Attempts = attempts
+
+	return true, nil
+}
+
+// UseEmailSignIn returns false when the sign-in was used, burned or expired meanwhile
+func (r *accountCommandRepository) UseEmailSignIn(es *account.EmailSignIn) (bool, error) {
+	query := "UPDATE public.email_sign_in" +
+		" SET used_at = now()" +
+		" WHERE id = :id AND used_at IS NULL AND expires_at > now()" +
+		fmt.Sprintf(" AND attempts <= %d", account.EMAIL_SIGN_IN_MAX_ATTEMPTS)
+
+	result, err := r.db.NamedExec(query, r.mapper.ToEmailSignInModel(es))
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseEmailSignIn")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseEmailSignIn RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) InsertCredential(c *account.Credential) (string, error) {
+	var id string
+
+	query := "INSERT INTO public.credential(user_id, password_hash)" +
+		" VALUES(:user_id, :password_hash)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToCredentialModel(c),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertCredential")
+	}
+
+	return id, nil
+}
+
+func (r *accountCommandRepository) UpdateCredentialPasswordHash(c *account.Credential) error {
+	query := "UPDATE public.credential" +
+		" SET password_hash = :password_hash, updated_at = now()" +
+		" WHERE user_id = :user_id"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToCredentialModel(c))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpdateCredentialPasswordHash")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) DeleteCredential(userId string) error {
+	query := "DELETE FROM public.credential" +
+		" WHERE user_id = :user_id"
+
+	_, err := r.db.NamedExec(query, map[string]any{"user_id": userId})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository DeleteCredential")
+	}
+
+	return nil
+}
+
+// UpsertPendingUserMFA restarts the enrolment with a new secret, it never replaces an enabled totp
+func (r *accountCommandRepository) UpsertPendingUserMFA(m *account.UserMFA) error {
+	query := "INSERT INTO public.user_mfa(user_id, totp_secret)" +
+		" VALUES(:user_id, :totp_secret)" +
+		" ON CONFLICT (user_id) DO UPDATE" +
+		" SET totp_secret = excluded.totp_secret, last_used_step = 0," +
+		" failed_attempts = 0, locked_until = NULL, updated_at = now()" +
+		" WHERE public.user_mfa.enabled_at IS NULL"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToUserMFAModel(m))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpsertPendingUserMFA")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) EnableUserMFA(m *account.UserMFA) (bool, error) {
+	query := "UPDATE public.user_mfa" +
+		" SET enabled_at = now(), last_used_step = :last_used_step, updated_at = now()" +
+		" WHERE user_id = :user_id AND enabled_at IS NULL"
+
+	result, err := r.db.NamedExec(query, r.mapper.ToUserMFAModel(m))
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository EnableUserMFA")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository EnableUserMFA RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// UseUserMFAStep returns false when a code of the step or a later one was used meanwhile
+func (r *accountCommandRepository) UseUserMFAStep(m *account.UserMFA) (bool, error) {
+	query := "UPDATE public.user_mfa" +
+		" SET last_used_step = :last_used_step, failed_attempts = 0, updated_at = now()" +
+		" WHERE user_id = :user_id AND last_used_step < :last_used_step"
+
+	result, err := r.db.NamedExec(query, r.mapper.ToUserMFAModel(m))
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseUserMFAStep")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseUserMFAStep RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// FailUserMFAAttempt locks the verification for MFA_LOCK_DURATION after MFA_MAX_FAILED_ATTEMPTS
+func (r *accountCommandRepository) FailUserMFAAttempt(m *account.UserMFA) error {
+	query := "UPDATE public.user_mfa SET" +
+		fmt.Sprintf(
+			" locked_until = CASE WHEN failed_attempts + 1 >= %d THEN now() + interval '%d seconds' ELSE locked_until END,",
+			account.MFA_MAX_FAILED_ATTEMPTS,
+			int(account.MFA_LOCK_DURATION.Seconds()),
+		) +
+		fmt.Sprintf(
+			" failed_attempts = CASE WHEN failed_attempts + 1 >= %d THEN 0 ELSE failed_attempts + 1 END,",
+			account.MFA_MAX_FAILED_ATTEMPTS,
+		) +
+		" updated_at = now()" +
+		" WHERE user_id = :user_id"
+
+	_, err := r.db.NamedExec(query, r.mapper.ToUserMFAModel(m))
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository FailUserMFAAttempt")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) DeleteUserMFA(userId string) error {
+	query := "WITH codes AS (" +
+		" DELETE FROM public.mfa_recovery_code WHERE user_id = :user_id" +
+		")" +
+		" DELETE FROM public.user_mfa WHERE user_id = :user_id"
+
+	_, err := r.db.NamedExec(query, map[string]any{"user_id": userId})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository DeleteUserMFA")
+	}
+
+	return nil
+}
+
+// ReplaceMFARecoveryCodes discards the previous codes, used or not
+func (r *accountCommandRepository) ReplaceMFARecoveryCodes(userId string, codeHashes []string) error {
+	query := "WITH discarded AS (" +
+		" DELETE FROM public.mfa_recovery_code WHERE user_id = :user_id" +
+		")" +
+		" INSERT INTO public.mfa_recovery_code(user_id, code_hash)" +
+		" SELECT :user_id, unnest(CAST(:code_hashes AS text[]))"
+
+	_, err := r.db.NamedExec(query, map[string]any{
+		"user_id":     userId,
+		"code_hashes": pq.Array(codeHashes),
+	})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository ReplaceMFARecoveryCodes")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) UseMFARecoveryCode(userId, codeHash string) (bool, error) {
+	query := "UPDATE public.mfa_recovery_code" +
+		" SET used_at = now()" +
+		" WHERE user_id = :user_id AND code_hash = :code_hash AND used_at IS NULL"
+
+	result, err := r.db.NamedExec(query, map[string]any{
+		"user_id":   userId,
+		"code_hash": codeHash,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseMFARecoveryCode")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseMFARecoveryCode RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// InsertWebAuthnChallenge also clears the expired challenges, the abandoned ceremonies are never consumed
+func (r *accountCommandRepository) InsertWebAuthnChallenge(wc *account.WebAuthnChallenge) (string, error) {
+	var id string
+
+	query := "WITH expired AS (" +
+		" DELETE FROM public.webauthn_challenge WHERE expires_at < now()" +
+		")" +
+		" INSERT INTO public.webauthn_challenge(challenge, ceremony, user_id, expires_at)" +
+		" VALUES(:challenge, :ceremony, :user_id, :expires_at)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToWebAuthnChallengeModel(wc),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertWebAuthnChallenge")
+	}
+
+	return id, nil
+}
+
+// ConsumeWebAuthnChallenge deletes the challenge as it is read, so a ceremony is finished at most once
+func (r *accountCommandRepository) ConsumeWebAuthnChallenge(challenge string) (*account.WebAuthnChallenge, error) {
+	var wc account.WebAuthnChallenge
+
+	query := "DELETE FROM public.webauthn_challenge" +
+		" WHERE challenge = :challenge" +
+		" RETURNING *"
+
+	err := r.db.PrepareNamedGet(
+		&wc,
+		query,
+		map[string]any{"challenge": challenge},
+	)
+	if err != nil {
+		customError := errors.Wrap(err, "accountCommandRepository ConsumeWebAuthnChallenge")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToWebAuthnChallengeEntity(&wc), err
+}
+
+// UseWebAuthnChallenge keeps the hash of a sign-in challenge kept in a cookie, it is false when the
+// challenge was already used, the rows are cleared once they expire as the cookie does
+func (r *accountCommandRepository) UseWebAuthnChallenge(wc *account.WebAuthnChallenge) (bool, error) {
+	query := "WITH expired AS (" +
+		" DELETE FROM public.webauthn_challenge WHERE expires_at < now()" +
+		")" +
+		" INSERT INTO public.webauthn_challenge(challenge, ceremony, user_id, expires_at)" +
+		" VALUES(:challenge, :ceremony, :user_id, :expires_at)" +
+		" ON CONFLICT (challenge) DO NOTHING"
+
+	result, err := r.db.NamedExec(query, r.mapper.ToWebAuthnChallengeModel(wc))
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseWebAuthnChallenge")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseWebAuthnChallenge RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) InsertWebAuthnCredential(wc *account.WebAuthnCredential) (string, error) {
+	var id string
+
+	query := "INSERT INTO public.webauthn_credential(user_id, credential_id, public_key, sign_count, aaguid, name)" +
+		" VALUES(:user_id, :credential_id, :public_key, :sign_count, :aaguid, :name)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToWebAuthnCredentialModel(wc),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertWebAuthnCredential")
+	}
+
+	return id, nil
+}
+
+// UpdateWebAuthnCredentialSignCount returns false when a greater count was stored meanwhile,
+// a counter of 0 means the authenticator does not count
+func (r *accountCommandRepository) UpdateWebAuthnCredentialSignCount(wc *account.WebAuthnCredential) (bool, error) {
+	query := "UPDATE public.webauthn_credential" +
+		" SET sign_count = :sign_count, last_used_at = now()" +
+		" WHERE id = :id AND (sign_count < :sign_count OR (sign_count = 0 AND :sign_count = 0))"
+
+	result, err := r.db.NamedExec(query, r.mapper.ToWebAuthnCredentialModel(wc))
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UpdateWebAuthnCredentialSignCount")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UpdateWebAuthnCredentialSignCount RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) DeleteWebAuthnCredential(userId, id string) (bool, error) {
+	query := "DELETE FROM public.webauthn_credential" +
+		" WHERE id = :id AND user_id = :user_id"
+
+	result, err := r.db.NamedExec(query, map[string]any{
+		"id":      id,
+		"user_id": userId,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository DeleteWebAuthnCredential")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository DeleteWebAuthnCredential RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) DeleteWebAuthnCredentialsOfUser(userId string) error {
+	query := "DELETE FROM public.webauthn_credential" +
+		" WHERE user_id = :user_id"
+
+	_, err := r.db.NamedExec(query, map[string]any{"user_id": userId})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository DeleteWebAuthnCredentialsOfUser")
+	}
+
+	return nil
+}
+
+// InsertUserRole returns false when the user already has the role
+func (r *accountCommandRepository) InsertUserRole(userId, role string) (bool, error) {
+	query := "INSERT INTO public.user_role(user_id, role)" +
+		" VALUES(:user_id, :role)" +
+		" ON CONFLICT (user_id, role) DO NOTHING"
+
+	result, err := r.db.NamedExec(query, map[string]any{
+		"user_id": userId,
+		"role":    role,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository InsertUserRole")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository InsertUserRole RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) DeleteUserRole(userId, role string) (bool, error) {
+	query := "DELETE FROM public.user_role" +
+		" WHERE user_id = :user_id AND role = :role"
+
+	result, err := r.db.NamedExec(query, map[string]any{
+		"user_id": userId,
+		"role":    role,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository DeleteUserRole")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository DeleteUserRole RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// InsertSession creates the session with its first refresh token
+func (r *accountCommandRepository) InsertSession(s *account.Session, refreshTokenUUID string) (string, error) {
+	var id string
+
+	query := "WITH s AS (" +
+		" INSERT INTO public.session(user_id, expires_at, user_agent, ip)" +
+		" VALUES(:user_id, :expires_at, :user_agent, :ip)" +
+		" RETURNING id" +
+		"), rt AS (" +
+		" INSERT INTO public.session_refresh_token(session_id, token_uuid)" +
+		" SELECT id, :token_uuid FROM s" +
+		")" +
+		" SELECT id FROM s"
+
+	m := r.mapper.ToSessionModel(s)
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		map[string]any{
+			"user_id":    m.UserID,
+			"expires_at": m.ExpiresAt,
+			"user_agent": m.UserAgent,
+			"ip":         m.IP,
+			"token_uuid": refreshTokenUUID,
+		},
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertSession")
+	}
+
+	return id, nil
+}
+
+// RotateSessionRefreshToken retires the unused refresh tokens of the session and adds the new one,
+// it returns false when the session is revoked or expired
+func (r *accountCommandRepository) RotateSessionRefreshToken(s *account.Session, refreshTokenUUID string) (bool, error) {
+	var n int
+
+	query := "WITH s AS (" +
+		" UPDATE public.session SET expires_at = :expires_at, updated_at = now()" +
+		" WHERE id = :id AND revoked_at IS NULL AND expires_at > now()" +
+		" RETURNING id" +
+		"), retired AS (" +
+		" UPDATE public.session_refresh_token SET used_at = now()" +
+		" WHERE session_id IN (SELECT id FROM s) AND used_at IS NULL" +
+		"), rt AS (" +
+		" INSERT INTO public.session_refresh_token(session_id, token_uuid)" +
+		" SELECT id, :token_uuid FROM s" +
+		" RETURNING id" +
+		")" +
+		" SELECT count(*) FROM rt"
+
+	m := r.mapper.ToSessionModel(s)
+	err := r.db.PrepareNamedGet(
+		&n,
+		query,
+		map[string]any{
+			"id":         m.ID,
+			"expires_at": m.ExpiresAt,
+			"token_uuid": refreshTokenUUID,
+		},
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository RotateSessionRefreshToken")
+	}
+
+	return n == 1, nil
+}
+
+// UseSessionRefreshToken returns false when the refresh token was already used
+func (r *accountCommandRepository) UseSessionRefreshToken(rt *account.SessionRefreshToken) (bool, error) {
+	query := "UPDATE public.session_refresh_token" +
+		" SET used_at = now()" +
+		" WHERE id = :id AND used_at IS NULL"
+
+	result, err := r.db.NamedExec(query, map[string]any{"id": rt.ID})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseSessionRefreshToken")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository UseSessionRefreshToken RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) RevokeSession(sessionId, reason string) error {
+	query := "UPDATE public.session" +
+		" SET revoked_at = now(), revoked_reason = :revoked_reason, updated_at = now()" +
+		" WHERE id = :id AND revoked_at IS NULL"
+
+	_, err := r.db.NamedExec(query, map[string]any{
+		"id":             sessionId,
+		"revoked_reason": reason,
+	})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository RevokeSession")
+	}
+
+	return nil
+}
+
+// TouchSession refreshes the device and the last seen time of the session,
+// it returns false when the session is revoked or expired
+func (r *accountCommandRepository) TouchSession(s *account.Session) (bool, error) {
+	query := "UPDATE public.session" +
+		" SET user_agent = :user_agent, ip = :ip, last_seen_at = now()" +
+		" WHERE id = :id AND revoked_at IS NULL AND expires_at > now()"
+
+	m := r.mapper.ToSessionModel(s)
+	result, err := r.db.NamedExec(query, map[string]any{
+		"id":         m.ID,
+		"user_agent": m.UserAgent,
+		"ip":         m.IP,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository TouchSession")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository TouchSession RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// RevokeSessionOfUser returns false when the user has no such active session
+func (r *accountCommandRepository) RevokeSessionOfUser(userId, sessionId, reason string) (bool, error) {
+	query := "UPDATE public.session" +
+		" SET revoked_at = now(), revoked_reason = :revoked_reason, updated_at = now()" +
+		" WHERE id = :id AND user_id = :user_id AND revoked_at IS NULL"
+
+	result, err := r.db.NamedExec(query, map[string]any{
+		"id":             sessionId,
+		"user_id":        userId,
+		"revoked_reason": reason,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository RevokeSessionOfUser")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository RevokeSessionOfUser RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+// RevokeOtherSessions revokes every active session of the user but keepSessionId,
+// it returns the ids of the revoked sessions
+func (r *accountCommandRepository) RevokeOtherSessions(userId, keepSessionId, reason string) ([]string, error) {
+	query := "UPDATE public.session" +
+		" SET revoked_at = now(), revoked_reason = :revoked_reason, updated_at = now()" +
+		" WHERE user_id = :user_id AND id <> :keep_id AND revoked_at IS NULL" +
+		" RETURNING id"
+
+	rows, err := r.db.NamedQuery(query, map[string]any{
+		"user_id":        userId,
+		"keep_id":        keepSessionId,
+		"revoked_reason": reason,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "accountCommandRepository RevokeOtherSessions")
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "accountCommandRepository RevokeOtherSessions Scan")
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RevokeSessionsOfUser returns the ids of the revoked sessions, so that their cache can be dropped
+func (r *accountCommandRepository) RevokeSessionsOfUser(userId, reason string) ([]string, error) {
+	query := "UPDATE public.session" +
+		" SET revoked_at = now(), revoked_reason = :revoked_reason, updated_at = now()" +
+		" WHERE user_id = :user_id AND revoked_at IS NULL" +
+		" RETURNING id"
+
+	rows, err := r.db.NamedQuery(query, map[string]any{
+		"user_id":        userId,
+		"revoked_reason": reason,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "accountCommandRepository RevokeSessionsOfUser")
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "accountCommandRepository RevokeSessionsOfUser Scan")
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (r *accountCommandRepository) InsertPersonalAccessToken(pat *account.PersonalAccessToken) (string, error) {
+	var id string
+
+	query := "INSERT INTO public.personal_access_token(user_id, name, token_prefix, token_hash, scopes, expires_at)" +
+		" VALUES(:user_id, :name, :token_prefix, :token_hash, :scopes, :expires_at)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToPersonalAccessTokenModel(pat),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertPersonalAccessToken")
+	}
+
+	return id, nil
+}
+
+// RevokePersonalAccessToken returns false when the user has no such token left to revoke
+func (r *accountCommandRepository) RevokePersonalAccessToken(userId, id string) (bool, error) {
+	query := "UPDATE public.personal_access_token" +
+		" SET revoked_at = now()" +
+		" WHERE id = :id AND user_id = :user_id AND revoked_at IS NULL"
+
+	result, err := r.db.NamedExec(query, map[string]any{
+		"id":      id,
+		"user_id": userId,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository RevokePersonalAccessToken")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "accountCommandRepository RevokePersonalAccessToken RowsAffected")
+	}
+
+	return n == 1, nil
+}
+
+func (r *accountCommandRepository) RevokePersonalAccessTokensOfUser(userId string) error {
+	query := "UPDATE public.personal_access_token" +
+		" SET revoked_at = now()" +
+		" WHERE user_id = :user_id AND revoked_at IS NULL"
+
+	_, err := r.db.NamedExec(query, map[string]any{"user_id": userId})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository RevokePersonalAccessTokensOfUser")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) UpdatePersonalAccessTokenLastUsed(id string) error {
+	query := "UPDATE public.personal_access_token" +
+		" SET last_used_at = now()" +
+		" WHERE id = :id"
+
+	_, err := r.db.NamedExec(query, map[string]any{"id": id})
+	if err != nil {
+		return errors.Wrap(err, "accountCommandRepository UpdatePersonalAccessTokenLastUsed")
+	}
+
+	return nil
+}
+
+func (r *accountCommandRepository) InsertLoginEvent(e *account.LoginEvent) (string, error) {
+	var id string
+
+	query := "INSERT INTO public.login_event(user_id, provider, outcome, reason, user_agent, ip, device_fingerprint, new_device)" +
+		" VALUES(:user_id, :provider, :outcome, :reason, :user_agent, :ip, :device_fingerprint, :new_device)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToLoginEventModel(e),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "accountCommandRepository InsertLoginEvent")
+	}
+
+	return id, nil
+}
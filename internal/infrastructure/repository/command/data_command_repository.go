@@ -1,9 +1,36 @@
 package commandrepository
 
-import "github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+import (
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	"github.com/rlawnsxo131/madre-server-v3/internal/infrastructure"
+)
 
-type dataCommandRepository struct{}
+type dataCommandRepository struct {
+	db     rdb.Database
+	mapper infrastructure.DataMapper
+}
+
+func NewDataCommandRepository(db rdb.Database) data.DataCommandRepository {
+	return &dataCommandRepository{db, infrastructure.DataMapper{}}
+}
+
+func (r *dataCommandRepository) InsertData(d *data.Data) (string, error) {
+	var id string
+
+	query := "INSERT INTO public.data(user_id, file_url, title, description, is_public)" +
+		" VALUES(:user_id, :file_url, :title, :description, :is_public)" +
+		" RETURNING id"
+
+	err := r.db.PrepareNamedGet(
+		&id,
+		query,
+		r.mapper.ToDataModel(d),
+	)
+	if err != nil {
+		return "", errors.Wrap(err, "dataCommandRepository InsertData")
+	}
 
-func NewDataCommandRepository() data.DataCommandRepository {
-	return &dataCommandRepository{}
+	return id, nil
 }
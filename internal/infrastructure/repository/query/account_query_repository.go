@@ -1,6 +1,10 @@
 package queryrepository
 
 import (
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
 	"github.com/rlawnsxo131/madre-server-v3/internal/domain/account"
@@ -37,7 +41,7 @@ func (r *accountQueryRepository) FindUserByUsername(username string) (*account.U
 	var u account.User
 
 	query := "SELECT * FROM public.user" +
-		" WHERE username = $1"
+		" WHERE lower(username) = lower($1)"
 
 	err := r.db.QueryRowx(query, username).StructScan(&u)
 	if err != nil {
@@ -48,11 +52,60 @@ func (r *accountQueryRepository) FindUserByUsername(username string) (*account.U
 	return r.mapper.ToUserEntity(&u), err
 }
 
+func (r *accountQueryRepository) FindUserByEmail(email string) (*account.User, error) {
+	var u account.User
+
+	query := "SELECT * FROM public.user" +
+		" WHERE lower(email) = lower($1)"
+
+	err := r.db.QueryRowx(query, email).StructScan(&u)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindUserByEmail")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToUserEntity(&u), err
+}
+
+func (r *accountQueryRepository) ExistsUserByEmail(email string) (bool, error) {
+	var exist bool
+
+	query := "SELECT EXISTS" +
+		"(SELECT 1 FROM public.user WHERE lower(email) = lower($1))"
+
+	err := r.db.QueryRowx(query, email).Scan(&exist)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository ExistsUserByEmail")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return exist, err
+}
+
+// FindUserByPreviousUsername returns the user who left the username last
+func (r *accountQueryRepository) FindUserByPreviousUsername(username string) (*account.User, error) {
+	var u account.User
+
+	query := "SELECT u.* FROM public.user u" +
+		" JOIN public.username_history h ON h.user_id = u.id" +
+		" WHERE lower(h.username) = lower($1)" +
+		" ORDER BY h.created_at DESC" +
+		" LIMIT 1"
+
+	err := r.db.QueryRowx(query, username).StructScan(&u)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindUserByPreviousUsername")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToUserEntity(&u), err
+}
+
 func (r *accountQueryRepository) ExistsUserByUsername(username string) (bool, error) {
 	var exist bool
 
 	query := "SELECT EXISTS" +
-		"(SELECT 1 FROM public.user WHERE username = $1)"
+		"(SELECT 1 FROM public.user WHERE lower(username) = lower($1))"
 
 	err := r.db.QueryRowx(query, username).Scan(&exist)
 	if err != nil {
@@ -63,6 +116,55 @@ func (r *accountQueryRepository) ExistsUserByUsername(username string) (bool, er
 	return exist, err
 }
 
+// ExistsUsernameHistory tells if a user other than excludeUserId left the username after releasedBefore
+func (r *accountQueryRepository) ExistsUsernameHistory(username, excludeUserId string, releasedBefore time.Time) (bool, error) {
+	var exist bool
+
+	query := "SELECT EXISTS" +
+		"(SELECT 1 FROM public.username_history" +
+		" WHERE lower(username) = lower($1) AND user_id::text <> $2 AND created_at > $3)"
+
+	err := r.db.QueryRowx(query, username, excludeUserId, releasedBefore).Scan(&exist)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository ExistsUsernameHistory")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return exist, err
+}
+
+// FindExistingUsernames returns the lower cased usernames already taken among usernames,
+// including the ones left after releasedBefore
+func (r *accountQueryRepository) FindExistingUsernames(usernames []string, releasedBefore time.Time) ([]string, error) {
+	lowers := make([]string, 0, len(usernames))
+	for _, u := range usernames {
+		lowers = append(lowers, strings.ToLower(u))
+	}
+
+	query := "SELECT lower(username) FROM public.user" +
+		" WHERE lower(username) = ANY($1)" +
+		" UNION" +
+		" SELECT lower(username) FROM public.username_history" +
+		" WHERE lower(username) = ANY($1) AND created_at > $2"
+
+	rows, err := r.db.Queryx(query, pq.Array(lowers), releasedBefore)
+	if err != nil {
+		return nil, errors.Wrap(err, "accountQueryRepository FindExistingUsernames")
+	}
+	defer rows.Close()
+
+	existing := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, errors.Wrap(err, "accountQueryRepository FindExistingUsernames Scan")
+		}
+		existing = append(existing, username)
+	}
+
+	return existing, rows.Err()
+}
+
 func (r *accountQueryRepository) FindSocialAccountByUserId(userId string) (*account.SocialAccount, error) {
 	var sa account.SocialAccount
 
@@ -108,3 +210,304 @@ func (r *accountQueryRepository) ExistsSocialAccountBySocialIdAndProvider(social
 
 	return exist, err
 }
+
+func (r *accountQueryRepository) FindEmailVerificationByTokenHash(tokenHash string) (*account.EmailVerification, error) {
+	var ev account.EmailVerification
+
+	query := "SELECT * FROM public.email_verification" +
+		" WHERE token_hash = $1"
+
+	err := r.db.QueryRowx(query, tokenHash).StructScan(&ev)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindEmailVerificationByTokenHash")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToEmailVerificationEntity(&ev), err
+}
+
+func (r *accountQueryRepository) FindCredentialByUserId(userId string) (*account.Credential, error) {
+	var c account.Credential
+
+	query := "SELECT * FROM public.credential" +
+		" WHERE user_id = $1"
+
+	err := r.db.QueryRowx(query, userId).StructScan(&c)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindCredentialByUserId")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToCredentialEntity(&c), err
+}
+
+func (r *accountQueryRepository) FindUserMFAByUserId(userId string) (*account.UserMFA, error) {
+	var m account.UserMFA
+
+	query := "SELECT * FROM public.user_mfa" +
+		" WHERE user_id = $1"
+
+	err := r.db.QueryRowx(query, userId).StructScan(&m)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindUserMFAByUserId")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToUserMFAEntity(&m), err
+}
+
+func (r *accountQueryRepository) CountUnusedMFARecoveryCodes(userId string) (int, error) {
+	var count int
+
+	query := "SELECT count(*) FROM public.mfa_recovery_code" +
+		" WHERE user_id = $1 AND used_at IS NULL"
+
+	err := r.db.QueryRowx(query, userId).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "accountQueryRepository CountUnusedMFARecoveryCodes")
+	}
+
+	return count, nil
+}
+
+func (r *accountQueryRepository) FindEmailSignInByTokenHash(tokenHash string) (*account.EmailSignIn, error) {
+	var es account.EmailSignIn
+
+	query := "SELECT * FROM public.email_sign_in" +
+		" WHERE token_hash = $1"
+
+	err := r.db.QueryRowx(query, tokenHash).StructScan(&es)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindEmailSignInByTokenHash")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToEmailSignInEntity(&es), err
+}
+
+// FindPendingEmailSignInByEmail returns the last sign-in sent to the email which is not used
+func (r *accountQueryRepository) FindPendingEmailSignInByEmail(email string) (*account.EmailSignIn, error) {
+	var es account.EmailSignIn
+
+	query := "SELECT * FROM public.email_sign_in" +
+		" WHERE lower(email) = lower($1) AND used_at IS NULL" +
+		" ORDER BY created_at DESC" +
+		" LIMIT 1"
+
+	err := r.db.QueryRowx(query, email).StructScan(&es)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindPendingEmailSignInByEmail")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToEmailSignInEntity(&es), err
+}
+
+func (r *accountQueryRepository) FindWebAuthnCredentialByCredentialId(credentialId string) (*account.WebAuthnCredential, error) {
+	var wc account.WebAuthnCredential
+
+	query := "SELECT * FROM public.webauthn_credential" +
+		" WHERE credential_id = $1"
+
+	err := r.db.QueryRowx(query, credentialId).StructScan(&wc)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindWebAuthnCredentialByCredentialId")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToWebAuthnCredentialEntity(&wc), err
+}
+
+func (r *accountQueryRepository) FindWebAuthnCredentialsByUserId(userId string) ([]*account.WebAuthnCredential, error) {
+	query := "SELECT * FROM public.webauthn_credential" +
+		" WHERE user_id = $1" +
+		" ORDER BY created_at"
+
+	rows, err := r.db.Queryx(query, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "accountQueryRepository FindWebAuthnCredentialsByUserId")
+	}
+	defer rows.Close()
+
+	wcs := []*account.WebAuthnCredential{}
+	for rows.Next() {
+		var wc account.WebAuthnCredential
+		if err := rows.StructScan(&wc); err != nil {
+			return nil, errors.Wrap(err, "accountQueryRepository FindWebAuthnCredentialsByUserId StructScan")
+		}
+		wcs = append(wcs, r.mapper.ToWebAuthnCredentialEntity(&wc))
+	}
+
+	return wcs, rows.Err()
+}
+
+func (r *accountQueryRepository) FindRolesByUserId(userId string) ([]string, error) {
+	roles := []string{}
+
+	query := "SELECT role FROM public.user_role" +
+		" WHERE user_id = $1" +
+		" ORDER BY role"
+
+	rows, err := r.db.Queryx(query, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "accountQueryRepository FindRolesByUserId")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, errors.Wrap(err, "accountQueryRepository FindRolesByUserId Scan")
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+func (r *accountQueryRepository) FindSessionById(id string) (*account.Session, error) {
+	var s account.Session
+
+	query := "SELECT * FROM public.session" +
+		" WHERE id = $1"
+
+	err := r.db.QueryRowx(query, id).StructScan(&s)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindSessionById")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToSessionEntity(&s), err
+}
+
+// FindActiveSessionsByUserId returns the sessions neither revoked nor expired, the last seen first
+func (r *accountQueryRepository) FindActiveSessionsByUserId(userId string) ([]*account.Session, error) {
+	query := "SELECT * FROM public.session" +
+		" WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()" +
+		" ORDER BY last_seen_at DESC"
+
+	rows, err := r.db.Queryx(query, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "accountQueryRepository FindActiveSessionsByUserId")
+	}
+	defer rows.Close()
+
+	ss := []*account.Session{}
+	for rows.Next() {
+		var s account.Session
+		if err := rows.StructScan(&s); err != nil {
+			return nil, errors.Wrap(err, "accountQueryRepository FindActiveSessionsByUserId StructScan")
+		}
+		ss = append(ss, r.mapper.ToSessionEntity(&s))
+	}
+
+	return ss, rows.Err()
+}
+
+func (r *accountQueryRepository) FindSessionRefreshTokenByTokenUUID(tokenUUID string) (*account.SessionRefreshToken, error) {
+	var rt account.SessionRefreshToken
+
+	query := "SELECT * FROM public.session_refresh_token" +
+		" WHERE token_uuid = $1"
+
+	err := r.db.QueryRowx(query, tokenUUID).StructScan(&rt)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindSessionRefreshTokenByTokenUUID")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToSessionRefreshTokenEntity(&rt), err
+}
+
+func (r *accountQueryRepository) FindPersonalAccessTokenByTokenHash(tokenHash string) (*account.PersonalAccessToken, error) {
+	var pat account.PersonalAccessToken
+
+	query := "SELECT * FROM public.personal_access_token" +
+		" WHERE token_hash = $1"
+
+	err := r.db.QueryRowx(query, tokenHash).StructScan(&pat)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository FindPersonalAccessTokenByTokenHash")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return r.mapper.ToPersonalAccessTokenEntity(&pat), err
+}
+
+// FindPersonalAccessTokensByUserId returns the tokens not revoked, the expired ones included
+func (r *accountQueryRepository) FindPersonalAccessTokensByUserId(userId string) ([]*account.PersonalAccessToken, error) {
+	query := "SELECT * FROM public.personal_access_token" +
+		" WHERE user_id = $1 AND revoked_at IS NULL" +
+		" ORDER BY created_at DESC"
+
+	rows, err := r.db.Queryx(query, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "accountQueryRepository FindPersonalAccessTokensByUserId")
+	}
+	defer rows.Close()
+
+	pats := []*account.PersonalAccessToken{}
+	for rows.Next() {
+		var pat account.PersonalAccessToken
+		if err := rows.StructScan(&pat); err != nil {
+			return nil, errors.Wrap(err, "accountQueryRepository FindPersonalAccessTokensByUserId StructScan")
+		}
+		pats = append(pats, r.mapper.ToPersonalAccessTokenEntity(&pat))
+	}
+
+	return pats, rows.Err()
+}
+
+func (r *accountQueryRepository) FindLoginEventsByUserId(userId string, limit int) ([]*account.LoginEvent, error) {
+	query := "SELECT * FROM public.login_event" +
+		" WHERE user_id = $1" +
+		" ORDER BY created_at DESC" +
+		" LIMIT $2"
+
+	rows, err := r.db.Queryx(query, userId, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "accountQueryRepository FindLoginEventsByUserId")
+	}
+	defer rows.Close()
+
+	es := []*account.LoginEvent{}
+	for rows.Next() {
+		var e account.LoginEvent
+		if err := rows.StructScan(&e); err != nil {
+			return nil, errors.Wrap(err, "accountQueryRepository FindLoginEventsByUserId StructScan")
+		}
+		es = append(es, r.mapper.ToLoginEventEntity(&e))
+	}
+
+	return es, rows.Err()
+}
+
+func (r *accountQueryRepository) ExistsSuccessfulLoginEventByUserId(userId string) (bool, error) {
+	var exist bool
+
+	query := "SELECT EXISTS" +
+		"(SELECT 1 FROM public.login_event WHERE user_id = $1 AND outcome = $2)"
+
+	err := r.db.QueryRowx(query, userId, account.LOGIN_EVENT_OUTCOME_SUCCESS).Scan(&exist)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository ExistsSuccessfulLoginEventByUserId")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return exist, err
+}
+
+func (r *accountQueryRepository) ExistsSuccessfulLoginEventByUserIdAndDevice(userId, deviceFingerprint string) (bool, error) {
+	var exist bool
+
+	query := "SELECT EXISTS" +
+		"(SELECT 1 FROM public.login_event WHERE user_id = $1 AND device_fingerprint = $2 AND outcome = $3)"
+
+	err := r.db.QueryRowx(query, userId, deviceFingerprint, account.LOGIN_EVENT_OUTCOME_SUCCESS).Scan(&exist)
+	if err != nil {
+		customError := errors.Wrap(err, "accountQueryRepository ExistsSuccessfulLoginEventByUserIdAndDevice")
+		err = utils.ErrNoRowsReturnRawError(err, customError)
+	}
+
+	return exist, err
+}
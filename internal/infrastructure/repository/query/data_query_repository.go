@@ -1,9 +1,95 @@
 package queryrepository
 
-import "github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+import (
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/external/datastore/rdb"
+	"github.com/rlawnsxo131/madre-server-v3/internal/domain/data"
+	"github.com/rlawnsxo131/madre-server-v3/internal/infrastructure"
+)
 
-type dataQueryRepository struct{}
+type dataQueryRepository struct {
+	db     rdb.Database
+	mapper infrastructure.DataMapper
+}
+
+func NewDataQueryRepository(db rdb.Database) data.DataQueryRepository {
+	return &dataQueryRepository{db, infrastructure.DataMapper{}}
+}
+
+func (r *dataQueryRepository) FindPublicDataByUserId(userId string, limit, offset int) ([]*data.Data, error) {
+	query := "SELECT * FROM public.data" +
+		" WHERE user_id = $1" +
+		" AND is_public = true" +
+		" ORDER BY created_at DESC, id DESC" +
+		" LIMIT $2 OFFSET $3"
+
+	rows, err := r.db.Queryx(query, userId, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "dataQueryRepository FindPublicDataByUserId")
+	}
+	defer rows.Close()
+
+	ds := []*data.Data{}
+	for rows.Next() {
+		var d data.Data
+		if err := rows.StructScan(&d); err != nil {
+			return nil, errors.Wrap(err, "dataQueryRepository FindPublicDataByUserId StructScan")
+		}
+		ds = append(ds, r.mapper.ToDataEntity(&d))
+	}
+
+	return ds, rows.Err()
+}
+
+func (r *dataQueryRepository) CountPublicDataByUserId(userId string) (int, error) {
+	var count int
+
+	query := "SELECT count(*) FROM public.data" +
+		" WHERE user_id = $1" +
+		" AND is_public = true"
+
+	err := r.db.QueryRowx(query, userId).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "dataQueryRepository CountPublicDataByUserId")
+	}
+
+	return count, nil
+}
+
+func (r *dataQueryRepository) FindDataByUserId(userId string, limit, offset int) ([]*data.Data, error) {
+	query := "SELECT * FROM public.data" +
+		" WHERE user_id = $1" +
+		" ORDER BY created_at DESC, id DESC" +
+		" LIMIT $2 OFFSET $3"
+
+	rows, err := r.db.Queryx(query, userId, limit, offset)
+	if err != nil {
+		return nil, errors.Wrap(err, "dataQueryRepository FindDataByUserId")
+	}
+	defer rows.Close()
+
+	ds := []*data.Data{}
+	for rows.Next() {
+		var d data.Data
+		if err := rows.StructScan(&d); err != nil {
+			return nil, errors.Wrap(err, "dataQueryRepository FindDataByUserId StructScan")
+		}
+		ds = append(ds, r.mapper.ToDataEntity(&d))
+	}
+
+	return ds, rows.Err()
+}
+
+func (r *dataQueryRepository) CountDataByUserId(userId string) (int, error) {
+	var count int
+
+	query := "SELECT count(*) FROM public.data" +
+		" WHERE user_id = $1"
+
+	err := r.db.QueryRowx(query, userId).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrap(err, "dataQueryRepository CountDataByUserId")
+	}
 
-func NewDataQueryRepository() data.DataQueryRepository {
-	return &dataQueryRepository{}
+	return count, nil
 }
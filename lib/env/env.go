@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
@@ -55,6 +58,233 @@ func JWTSecretKey() string {
 	return getEnv("JWT_SECRET_KEY")
 }
 
+// JWTSigningKeyFile is the PEM file of the rsa or ed25519 private key signing the auth tokens,
+// the tokens are signed with JWT_SECRET_KEY when it is not set
+func JWTSigningKeyFile() string {
+	return lookupEnv("JWT_SIGNING_KEY_FILE", "")
+}
+
+// JWTVerificationKeyFiles are the PEM files of the keys still verifying the auth tokens,
+// the previous signing key after a rotation and the next one before it
+func JWTVerificationKeyFiles() []string {
+	return lookupEnvList("JWT_VERIFICATION_KEY_FILES")
+}
+
+// JWTAcceptHS256 keeps the auth tokens signed with JWT_SECRET_KEY valid once a signing key is set,
+// it is turned off when the tokens issued before have expired
+func JWTAcceptHS256() bool {
+	return lookupEnvBool("JWT_ACCEPT_HS256", true)
+}
+
+// JWTAccessTokenTTL and JWTRefreshTokenTTL are the lifetimes of the auth tokens and of their cookies,
+// a malformed value is returned as an error so the server does not start with the default
+func JWTAccessTokenTTL() (time.Duration, error) {
+	return parseEnvDuration("JWT_ACCESS_TOKEN_TTL", time.Hour*24)
+}
+
+func JWTRefreshTokenTTL() (time.Duration, error) {
+	return parseEnvDuration("JWT_REFRESH_TOKEN_TTL", time.Hour*24*30)
+}
+
+func JWTIssuer() string {
+	return lookupEnv("JWT_ISSUER", "madre")
+}
+
+// JWTAudience is set in the auth tokens and checked when they are decoded, it is not checked when empty
+func JWTAudience() string {
+	return lookupEnv("JWT_AUDIENCE", "")
+}
+
+// AuthCookieDomain is empty for host-only cookies, e.g. ".juntae.kim" shares them with the subdomains
+func AuthCookieDomain() string {
+	return lookupEnv("AUTH_COOKIE_DOMAIN", "")
+}
+
+func AuthCookiePath() string {
+	return lookupEnv("AUTH_COOKIE_PATH", "/")
+}
+
+// AuthCookieSameSite is "lax", "strict" or "none", none requires AUTH_COOKIE_SECURE
+func AuthCookieSameSite() string {
+	return lookupEnv("AUTH_COOKIE_SAME_SITE", "lax")
+}
+
+// AuthCookieSecure is turned off to use the cookies over http locally,
+// a malformed value is returned as an error rather than read as the default
+func AuthCookieSecure() (bool, error) {
+	return parseEnvBool("AUTH_COOKIE_SECURE", true)
+}
+
+func ClientURL() string {
+	return lookupEnv("CLIENT_URL", "http://localhost:8080")
+}
+
+// OAuthCallbackBaseURL is where the providers redirect back to,
+// the callback url is {OAUTH_CALLBACK_BASE_URL}/{provider}/callback
+func OAuthCallbackBaseURL() string {
+	return lookupEnv("OAUTH_CALLBACK_BASE_URL", "http://localhost:5000/api/v1/auth")
+}
+
+func GoogleClientID() string {
+	return lookupEnv("GOOGLE_CLIENT_ID", "")
+}
+
+func GoogleClientSecret() string {
+	return lookupEnv("GOOGLE_CLIENT_SECRET", "")
+}
+
+// SocialHTTPTimeout is the timeout of the http client shared by the social providers
+func SocialHTTPTimeout() time.Duration {
+	return lookupEnvDuration("SOCIAL_HTTP_TIMEOUT", time.Second*10)
+}
+
+// ReservedUsernames are added to the usernames reserved by default, e.g. admin,api,health
+func ReservedUsernames() []string {
+	return lookupEnvList("RESERVED_USERNAMES")
+}
+
+// UsernameCooldown is how long a previous username stays reserved for the user who left it
+func UsernameCooldown() time.Duration {
+	return lookupEnvDuration("USERNAME_COOLDOWN", time.Hour*24*30)
+}
+
+// Mailer is "smtp" or "outbox", the outbox writes the mails to MAIL_OUTBOX_DIR for local development
+func Mailer() string {
+	return lookupEnv("MAILER", "outbox")
+}
+
+func MailFrom() string {
+	return lookupEnv("MAIL_FROM", "no-reply@localhost")
+}
+
+func MailOutboxDir() string {
+	return lookupEnv("MAIL_OUTBOX_DIR", "outbox")
+}
+
+func SMTPHost() string {
+	return getEnv("SMTP_HOST")
+}
+
+func SMTPPort() string {
+	return lookupEnv("SMTP_PORT", "587")
+}
+
+func SMTPUsername() string {
+	return lookupEnv("SMTP_USERNAME", "")
+}
+
+func SMTPPassword() string {
+	return lookupEnv("SMTP_PASSWORD", "")
+}
+
+// EmailVerificationTTL is how long the link sent to confirm an email stays valid
+func EmailVerificationTTL() time.Duration {
+	return lookupEnvDuration("EMAIL_VERIFICATION_TTL", time.Hour*24)
+}
+
+// EmailSignInTTL is how long the link and code sent to sign in by email stay valid
+func EmailSignInTTL() time.Duration {
+	return lookupEnvDuration("EMAIL_SIGN_IN_TTL", time.Minute*15)
+}
+
+// PasswordAuthEnabled turns on the local username/password accounts, for installations without oauth
+func PasswordAuthEnabled() bool {
+	return lookupEnvBool("PASSWORD_AUTH_ENABLED", false)
+}
+
+func PasswordMinLength() int {
+	return lookupEnvInt("PASSWORD_MIN_LENGTH", 10)
+}
+
+// PasswordArgon2Memory is in KiB
+func PasswordArgon2Memory() int {
+	return lookupEnvInt("PASSWORD_ARGON2_MEMORY", 64*1024)
+}
+
+func PasswordArgon2Iterations() int {
+	return lookupEnvInt("PASSWORD_ARGON2_ITERATIONS", 3)
+}
+
+func PasswordArgon2Parallelism() int {
+	return lookupEnvInt("PASSWORD_ARGON2_PARALLELISM", 2)
+}
+
+// UserStatusCacheTTL is how long the status of a user is trusted by the JWT middleware,
+// a suspension takes at most this long to sign out the tokens already issued
+func UserStatusCacheTTL() time.Duration {
+	return lookupEnvDuration("USER_STATUS_CACHE_TTL", time.Minute)
+}
+
+// SessionCacheTTL is how long a session is trusted by the JWT middleware, a revoked session
+// takes at most this long to sign out its access token and its last seen time is this coarse
+func SessionCacheTTL() time.Duration {
+	return lookupEnvDuration("SESSION_CACHE_TTL", time.Minute)
+}
+
+// MFAIssuer is the name of the service shown by the authenticator apps
+func MFAIssuer() string {
+	return lookupEnv("MFA_ISSUER", "madre")
+}
+
+// MFAEncryptionKey seals the totp secrets and is required to start, it is independent of JWT_SECRET_KEY
+// so that rotating the token keys does not lock the users out of their second factor
+func MFAEncryptionKey() string {
+	return lookupEnv("MFA_ENCRYPTION_KEY", "")
+}
+
+// WebAuthnRPID is the domain the passkeys are bound to
+func WebAuthnRPID() string {
+	return lookupEnv("WEBAUTHN_RP_ID", "localhost")
+}
+
+func WebAuthnRPName() string {
+	return lookupEnv("WEBAUTHN_RP_NAME", "madre")
+}
+
+// WebAuthnOrigins are the origins the ceremonies are accepted from, the client url by default
+func WebAuthnOrigins() []string {
+	origins := lookupEnvList("WEBAUTHN_ORIGINS")
+	if len(origins) == 0 {
+		return []string{ClientURL()}
+	}
+	return origins
+}
+
+// WebAuthnAttestation is "none" or "direct"
+func WebAuthnAttestation() string {
+	return lookupEnv("WEBAUTHN_ATTESTATION", "none")
+}
+
+func OIDCProviders() []string {
+	return lookupEnvList("OIDC_PROVIDERS")
+}
+
+func OIDCIssuer(provider string) string {
+	return getEnv(oidcKey(provider, "ISSUER"))
+}
+
+func OIDCClientID(provider string) string {
+	return getEnv(oidcKey(provider, "CLIENT_ID"))
+}
+
+func OIDCClientSecret(provider string) string {
+	return lookupEnv(oidcKey(provider, "CLIENT_SECRET"), "")
+}
+
+func OIDCScopes(provider string) []string {
+	scopes := lookupEnvList(oidcKey(provider, "SCOPES"))
+	if len(scopes) == 0 {
+		return []string{"openid", "email", "profile"}
+	}
+	return scopes
+}
+
+// oidcKey builds the per provider variable name,
+// e.g. OIDC_KEYCLOAK_ISSUER for provider "keycloak"
+func oidcKey(provider, name string) string {
+	return fmt.Sprintf("OIDC_%s_%s", strings.ToUpper(provider), name)
+}
+
 func getEnv(key string) string {
 	v, ok := os.LookupEnv(key)
 	if !ok {
@@ -66,3 +296,79 @@ func getEnv(key string) string {
 	}
 	return v
 }
+
+// lookupEnv is used for optional values, so it does not log when the key is not set
+func lookupEnv(key, defaultValue string) string {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return defaultValue
+	}
+	return v
+}
+
+func lookupEnvList(key string) []string {
+	list := []string{}
+	for _, v := range strings.Split(lookupEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func lookupEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	d, err := parseEnvDuration(key, defaultValue)
+	if err != nil {
+		logger.DefaultLogger().Err(err).Timestamp().Str("key", key).Send()
+		return defaultValue
+	}
+	return d
+}
+
+func lookupEnvBool(key string, defaultValue bool) bool {
+	b, err := parseEnvBool(key, defaultValue)
+	if err != nil {
+		logger.DefaultLogger().Err(err).Timestamp().Str("key", key).Send()
+		return defaultValue
+	}
+	return b
+}
+
+// parseEnvDuration and parseEnvBool return the default when the key is not set and an error when it is malformed,
+// they are used for the values the server must not silently replace
+func parseEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	v := lookupEnv(key, "")
+	if v == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a duration: %w", key, err)
+	}
+	return d, nil
+}
+
+func parseEnvBool(key string, defaultValue bool) (bool, error) {
+	v := lookupEnv(key, "")
+	if v == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s is not a boolean: %w", key, err)
+	}
+	return b, nil
+}
+
+func lookupEnvInt(key string, defaultValue int) int {
+	v := lookupEnv(key, "")
+	if v == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		logger.DefaultLogger().Err(errors.New(fmt.Sprintf("%s is not a positive integer", key))).Timestamp().Send()
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	MAILER_SMTP   = "smtp"
+	MAILER_OUTBOX = "outbox"
+)
+
+var (
+	defaultMailer     Mailer
+	onceDefaultMailer sync.Once
+)
+
+// Message is a plain text mail
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// DefaultMailer is selected by MAILER, the outbox is used unless smtp is set
+func DefaultMailer() Mailer {
+	onceDefaultMailer.Do(func() {
+		switch env.Mailer() {
+		case MAILER_SMTP:
+			defaultMailer = NewSMTPMailer(SMTPConfig{
+				Host:     env.SMTPHost(),
+				Port:     env.SMTPPort(),
+				Username: env.SMTPUsername(),
+				Password: env.SMTPPassword(),
+				From:     env.MailFrom(),
+			})
+		default:
+			defaultMailer = NewOutboxMailer(env.MailOutboxDir(), env.MailFrom())
+		}
+	})
+	return defaultMailer
+}
+
+func (m *Message) validate() error {
+	if m.To == "" {
+		return errors.New("mailer Message recipient is empty")
+	}
+	// the headers are written as is, a line break would let the value add headers
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return errors.New("mailer Message header contains a line break")
+	}
+	return nil
+}
+
+// bytes formats the message as rfc 5322 with an utf-8 text body
+func (m *Message) bytes(from string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Text, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
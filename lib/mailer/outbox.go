@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// outboxMailer writes every mail as an .eml file instead of sending it,
+// so that the links can be followed without a mail server
+type outboxMailer struct {
+	dir  string
+	from string
+}
+
+func NewOutboxMailer(dir, from string) Mailer {
+	return &outboxMailer{dir, from}
+}
+
+func (om *outboxMailer) Send(ctx context.Context, m *Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "outboxMailer Send")
+	}
+
+	if err := os.MkdirAll(om.dir, 0o700); err != nil {
+		return errors.Wrap(err, "outboxMailer Send MkdirAll")
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "outboxMailer Send rand")
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(b))
+
+	// the mails hold single use tokens
+	err := os.WriteFile(filepath.Join(om.dir, name), m.bytes(om.from, now), 0o600)
+	if err != nil {
+		return errors.Wrap(err, "outboxMailer Send WriteFile")
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type smtpMailer struct {
+	config SMTPConfig
+}
+
+func NewSMTPMailer(config SMTPConfig) Mailer {
+	return &smtpMailer{config}
+}
+
+// Send upgrades the connection with STARTTLS when the server offers it,
+// credentials are never sent over a plain connection
+func (sm *smtpMailer) Send(ctx context.Context, m *Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(sm.config.Host, sm.config.Port))
+	if err != nil {
+		return errors.Wrap(err, "smtpMailer Send Dial")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, sm.config.Host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "smtpMailer Send NewClient")
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: sm.config.Host}); err != nil {
+			return errors.Wrap(err, "smtpMailer Send StartTLS")
+		}
+	}
+	if sm.config.Username != "" {
+		auth := smtp.PlainAuth("", sm.config.Username, sm.config.Password, sm.config.Host)
+		if err := c.Auth(auth); err != nil {
+			return errors.Wrap(err, "smtpMailer Send Auth")
+		}
+	}
+
+	if err := c.Mail(sm.config.From); err != nil {
+		return errors.Wrap(err, "smtpMailer Send Mail")
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return errors.Wrap(err, "smtpMailer Send Rcpt")
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "smtpMailer Send Data")
+	}
+	if _, err := w.Write(m.bytes(sm.config.From, time.Now())); err != nil {
+		w.Close()
+		return errors.Wrap(err, "smtpMailer Send Write")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "smtpMailer Send Close")
+	}
+
+	return c.Quit()
+}
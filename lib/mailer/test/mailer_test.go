@@ -0,0 +1,48 @@
+package mailer_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/mailer"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OutboxMailer_Send_WritesMail(t *testing.T) {
+	assert := assert.New(t)
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	err := mailer.NewOutboxMailer(dir, "no-reply@madre.test").Send(context.Background(), &mailer.Message{
+		To:      "user@madre.test",
+		Subject: "Confirm your email",
+		Text:    "open the link\nhttps://madre.test/verify",
+	})
+	assert.Nil(err)
+
+	files, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(files, 1)
+
+	b, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	assert.Nil(err)
+	assert.Contains(string(b), "From: no-reply@madre.test\r\n")
+	assert.Contains(string(b), "To: user@madre.test\r\n")
+	assert.Contains(string(b), "Subject: Confirm your email\r\n")
+	assert.Contains(string(b), "\r\n\r\nopen the link\r\nhttps://madre.test/verify")
+}
+
+func Test_OutboxMailer_Send_RejectsHeaderInjection(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	err := mailer.NewOutboxMailer(dir, "no-reply@madre.test").Send(context.Background(), &mailer.Message{
+		To:      "user@madre.test\r\nBcc: other@madre.test",
+		Subject: "Confirm your email",
+	})
+
+	assert.NotNil(err)
+	files, _ := os.ReadDir(dir)
+	assert.Len(files, 0)
+}
@@ -0,0 +1,135 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	SALT_LENGTH = 16
+	KEY_LENGTH  = 32
+)
+
+var (
+	ErrInvalidHash = errors.New("invalid password hash")
+
+	defaultParams     *Params
+	onceDefaultParams sync.Once
+
+	dummyHash     string
+	onceDummyHash sync.Once
+)
+
+// Params are the argon2id cost parameters, memory is in KiB
+type Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultParams are read once from PASSWORD_ARGON2_MEMORY, PASSWORD_ARGON2_ITERATIONS
+// and PASSWORD_ARGON2_PARALLELISM, raising them makes the next sign-in rehash the password
+func DefaultParams() *Params {
+	onceDefaultParams.Do(func() {
+		defaultParams = &Params{
+			Memory:      uint32(env.PasswordArgon2Memory()),
+			Iterations:  uint32(env.PasswordArgon2Iterations()),
+			Parallelism: uint8(env.PasswordArgon2Parallelism()),
+		}
+	})
+	return defaultParams
+}
+
+// Hash returns the password hash in the PHC string format,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func Hash(password string, p *Params) (string, error) {
+	salt := make([]byte, SALT_LENGTH)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Wrap(err, "password Hash rand")
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, KEY_LENGTH)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		p.Memory,
+		p.Iterations,
+		p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify compares the password with the hash in constant time
+func Verify(password, encoded string) (bool, error) {
+	h, err := decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), h.salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, uint32(len(h.key)))
+
+	return subtle.ConstantTimeCompare(key, h.key) == 1, nil
+}
+
+// NeedsRehash is true when the hash was made with other parameters than p
+func NeedsRehash(encoded string, p *Params) bool {
+	h, err := decode(encoded)
+	if err != nil {
+		return true
+	}
+	return h.params != *p || len(h.key) != KEY_LENGTH
+}
+
+// VerifyDummy takes the time of a Verify when there is no hash to compare with,
+// so that unknown users can not be told apart by the response time
+func VerifyDummy(password string) {
+	onceDummyHash.Do(func() {
+		dummyHash, _ = Hash("dummy password", DefaultParams())
+	})
+	Verify(password, dummyHash)
+}
+
+type hash struct {
+	params Params
+	salt   []byte
+	key    []byte
+}
+
+func decode(encoded string) (*hash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrInvalidHash
+	}
+
+	var h hash
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.params.Memory, &h.params.Iterations, &h.params.Parallelism)
+	if err != nil || h.params.Iterations == 0 || h.params.Parallelism == 0 {
+		return nil, ErrInvalidHash
+	}
+
+	h.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+	h.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(h.key) == 0 {
+		return nil, ErrInvalidHash
+	}
+
+	return &h, nil
+}
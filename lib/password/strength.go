@@ -0,0 +1,70 @@
+package password
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MAX_LENGTH = 128
+	// distinct characters, so that aaaaaaaaaa or 1212121212 are refused
+	MIN_DISTINCT_CHARACTERS = 5
+)
+
+var (
+	ErrTooShort          = errors.New("password is too short")
+	ErrTooLong           = errors.New("password is too long")
+	ErrTooSimple         = errors.New("password has too few distinct characters")
+	ErrTooCommon         = errors.New("password is too common")
+	ErrContainsUserInput = errors.New("password contains the username or email")
+
+	commonPasswords = map[string]bool{
+		"password": true, "password1": true, "password12": true, "password123": true,
+		"passw0rd": true, "qwerty": true, "qwerty123": true, "qwertyuiop": true,
+		"123456": true, "12345678": true, "123456789": true, "1234567890": true,
+		"abc123": true, "111111": true, "iloveyou": true, "welcome": true,
+		"welcome1": true, "admin": true, "admin123": true, "letmein": true,
+		"monkey": true, "dragon": true, "football": true, "baseball": true,
+		"sunshine": true, "princess": true, "trustno1": true, "changeme": true,
+		"1q2w3e4r": true, "1q2w3e4r5t": true, "zaq12wsx": true, "asdfghjkl": true,
+	}
+)
+
+// Validate checks the strength of a new password, minLength counts characters, not bytes
+func Validate(password string, minLength int, userInputs ...string) error {
+	n := utf8.RuneCountInString(password)
+	if n < minLength {
+		return ErrTooShort
+	}
+	if n > MAX_LENGTH {
+		return ErrTooLong
+	}
+
+	distinct := map[rune]bool{}
+	for _, r := range password {
+		distinct[r] = true
+	}
+	if len(distinct) < MIN_DISTINCT_CHARACTERS {
+		return ErrTooSimple
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return ErrTooCommon
+	}
+
+	for _, input := range userInputs {
+		// the local part of an email is what people reuse
+		if i := strings.Index(input, "@"); i > 0 {
+			input = input[:i]
+		}
+		input = strings.ToLower(input)
+		if len(input) >= 3 && strings.Contains(lower, input) {
+			return ErrContainsUserInput
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/password"
+	"github.com/stretchr/testify/assert"
+)
+
+// cheap parameters, the tests do not measure the cost
+var testParams = &password.Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func Test_Hash_Verify(t *testing.T) {
+	assert := assert.New(t)
+
+	encoded, err := password.Hash("correct horse battery", testParams)
+	assert.Nil(err)
+	assert.True(strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	match, err := password.Verify("correct horse battery", encoded)
+	assert.Nil(err)
+	assert.True(match)
+
+	match, err = password.Verify("correct horse battery!", encoded)
+	assert.Nil(err)
+	assert.False(match)
+}
+
+func Test_Hash_IsSalted(t *testing.T) {
+	assert := assert.New(t)
+
+	first, _ := password.Hash("correct horse battery", testParams)
+	second, _ := password.Hash("correct horse battery", testParams)
+
+	assert.NotEqual(first, second)
+}
+
+func Test_Verify_InvalidHash(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, encoded := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+	} {
+		match, err := password.Verify("password", encoded)
+		assert.False(match, encoded)
+		assert.Equal(password.ErrInvalidHash, err, encoded)
+	}
+}
+
+func Test_NeedsRehash(t *testing.T) {
+	assert := assert.New(t)
+	encoded, _ := password.Hash("correct horse battery", testParams)
+
+	assert.False(password.NeedsRehash(encoded, testParams))
+	assert.True(password.NeedsRehash(encoded, &password.Params{Memory: 2048, Iterations: 1, Parallelism: 1}))
+	assert.True(password.NeedsRehash("invalid", testParams))
+}
+
+func Test_Validate(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		password string
+		err      error
+	}{
+		{"correct horse battery", nil},
+		{"short1!", password.ErrTooShort},
+		{strings.Repeat("ab1!c", 26), password.ErrTooLong},
+		{"aaaaaaaaaaaa", password.ErrTooSimple},
+		{"1212121212", password.ErrTooSimple},
+		{"Password123", password.ErrTooCommon},
+		{"madreuser2022!", password.ErrContainsUserInput},
+		{"mymail-is-hunter", password.ErrContainsUserInput},
+	}
+
+	for _, c := range cases {
+		assert.Equal(c.err, password.Validate(c.password, 10, "MadreUser", "hunter@example.com"), c.password)
+	}
+}
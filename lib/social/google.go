@@ -1,15 +1,29 @@
 package social
 
 import (
+	"context"
 	"encoding/json"
-	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
+const (
+	GOOGLE_PEOPLE_API_BASE_URL     = "https://people.googleapis.com"
+	GOOGLE_PEOPLE_API_MAX_ATTEMPTS = 3
+	GOOGLE_PEOPLE_API_BACKOFF      = time.Millisecond * 200
+	GOOGLE_PEOPLE_API_MAX_BACKOFF  = time.Second * 2
+)
+
+var (
+	defaultGooglePeopleAPI     *googlePeopleAPI
+	onceDefaultGooglePeopleAPI sync.Once
+)
+
 // Google people api Response
 // {
 // 	"resourceName": "",
@@ -70,7 +84,7 @@ type googlePeopleApiResponse struct {
 				Id   string `json:"id"`
 			} `json:"source"`
 		} `json:"metadata"`
-		DisplayName          string `json:"displayname"`
+		DisplayName          string `json:"displayName"`
 		FamilyName           string `json:"familyName"`
 		GivenName            string `json:"givenName"`
 		DisplayNameLastFirst string `json:"displayNameLastFirst"`
@@ -82,7 +96,7 @@ type googlePeopleApiResponse struct {
 			Source  struct {
 				Type string `json:"type"`
 				Id   string `json:"id"`
-			} `json:"struct"`
+			} `json:"source"`
 		} `json:"metadata"`
 		Url     string `json:"url"`
 		Default bool   `json:"default"`
@@ -94,52 +108,52 @@ type googlePeopleApiResponse struct {
 			Source   struct {
 				Type string `json:"type"`
 				Id   string `json:"id"`
-			} `json:"struct"`
+			} `json:"source"`
 			SourcePrimary bool `json:"sourcePrimary"`
 		} `json:"metadata"`
 		Value string `json:"value"`
 	} `json:"emailAddresses"`
 }
 
-type googlePeopleProfile struct {
-	SocialID    string
-	Email       string
-	PhotoUrl    string
-	DisplayName string
-}
-
 type googlePeopleAPI struct {
-	accessToken string
+	client      *http.Client
+	baseURL     string
+	maxAttempts int
+	backoff     time.Duration
 }
 
-func NewGooglePeopleAPI(actk string) *googlePeopleAPI {
+// NewGooglePeopleAPI takes the base url, so that tests can use a fake server
+func NewGooglePeopleAPI(client *http.Client, baseURL string) *googlePeopleAPI {
 	return &googlePeopleAPI{
-		accessToken: actk,
+		client:      client,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		maxAttempts: GOOGLE_PEOPLE_API_MAX_ATTEMPTS,
+		backoff:     GOOGLE_PEOPLE_API_BACKOFF,
 	}
 }
 
-func (g *googlePeopleAPI) Do() (*googlePeopleProfile, error) {
-	req, err := g.createRequest(g.accessToken)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := g.excuteRequest(req)
-	if err != nil {
-		return nil, err
-	}
+func GooglePeopleAPI() *googlePeopleAPI {
+	onceDefaultGooglePeopleAPI.Do(func() {
+		defaultGooglePeopleAPI = NewGooglePeopleAPI(
+			DefaultHTTPClient(),
+			GOOGLE_PEOPLE_API_BASE_URL,
+		)
+	})
+	return defaultGooglePeopleAPI
+}
 
-	gapiRes, err := g.mapToGooglePeopleApiResponse(res)
+func (g *googlePeopleAPI) Do(ctx context.Context, accessToken string) (*Profile, error) {
+	gapiRes, err := g.excuteRequest(ctx, accessToken)
 	if err != nil {
 		return nil, err
 	}
 
-	return g.mapToGooglePeopleProfile(gapiRes), nil
+	return g.mapToGooglePeopleProfile(gapiRes)
 }
 
-func (g *googlePeopleAPI) createRequest(accessToken string) (*http.Request, error) {
-	url := "https://people.googleapis.com/v1/people/me?personFields=names,emailAddresses,photos"
-	req, err := http.NewRequest("GET", url, nil)
+func (g *googlePeopleAPI) createRequest(ctx context.Context, accessToken string) (*http.Request, error) {
+	url := g.baseURL + "/v1/people/me?personFields=names,emailAddresses,photos"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "social googlePeopleAPI createRequest")
 	}
@@ -147,72 +161,166 @@ func (g *googlePeopleAPI) createRequest(accessToken string) (*http.Request, erro
 	return req, nil
 }
 
-func (g *googlePeopleAPI) excuteRequest(req *http.Request) (*http.Response, error) {
-	client := &http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "social googlePeopleAPI excuteRequest")
+// excuteRequest retries rate limited, 5xx and network errors with exponential backoff,
+// the request is a GET so it is safe to repeat
+func (g *googlePeopleAPI) excuteRequest(ctx context.Context, accessToken string) (*googlePeopleApiResponse, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < g.maxAttempts; attempt++ {
+		if attempt > 0 {
+			wait := g.backoff << (attempt - 1)
+			if ra, ok := retryAfter(lastErr); ok {
+				if ra > GOOGLE_PEOPLE_API_MAX_BACKOFF {
+					break
+				}
+				wait = ra
+			}
+			select {
+			case <-ctx.Done():
+				return nil, errors.Wrap(ctx.Err(), "social googlePeopleAPI excuteRequest")
+			case <-time.After(wait):
+			}
+		}
+
+		req, err := g.createRequest(ctx, accessToken)
+		if err != nil {
+			return nil, err
+		}
+
+		gapiRes, err := g.roundTrip(req)
+		if err == nil {
+			return gapiRes, nil
+		}
+		lastErr = err
+
+		if !errors.Is(err, ErrProviderRateLimited) && !errors.Is(err, ErrProviderUnavailable) {
+			return nil, err
+		}
 	}
-	return res, nil
+
+	return nil, lastErr
 }
 
-func (g *googlePeopleAPI) mapToGooglePeopleApiResponse(res *http.Response) (*googlePeopleApiResponse, error) {
-	defer res.Body.Close()
-	body, err := ioutil.ReadAll(res.Body)
+func (g *googlePeopleAPI) roundTrip(req *http.Request) (*googlePeopleApiResponse, error) {
+	res, err := g.client.Do(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "social googlePeopleAPI mapTogooglePeopleApiResponse res.Body read")
+		return nil, errors.Wrap(ErrProviderUnavailable, err.Error())
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode == http.StatusUnauthorized:
+		return nil, errors.Wrap(ErrProviderUnauthorized, "social googlePeopleAPI status 401")
+	case res.StatusCode == http.StatusForbidden:
+		return nil, errors.Wrap(ErrProviderForbidden, "social googlePeopleAPI status 403")
+	case res.StatusCode == http.StatusTooManyRequests:
+		return nil, &retryAfterError{
+			err:   errors.Wrap(ErrProviderRateLimited, "social googlePeopleAPI status 429"),
+			delay: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
+	case res.StatusCode >= http.StatusInternalServerError:
+		return nil, errors.Wrapf(ErrProviderUnavailable, "social googlePeopleAPI status %d", res.StatusCode)
+	default:
+		return nil, errors.Errorf("social googlePeopleAPI unexpected status %d", res.StatusCode)
 	}
 
 	var gapiRes googlePeopleApiResponse
-	if err := json.Unmarshal(body, &gapiRes); err != nil {
-		return nil, errors.Wrap(err, "social googlePeopleAPI mapTogooglePeopleApiResponse Unmarshal")
+	if err := json.NewDecoder(res.Body).Decode(&gapiRes); err != nil {
+		return nil, errors.Wrap(ErrInvalidProfile, "social googlePeopleAPI Decode "+err.Error())
 	}
 	return &gapiRes, nil
 }
 
-func (g *googlePeopleAPI) mapToGooglePeopleProfile(gapiRes *googlePeopleApiResponse) *googlePeopleProfile {
-	var socialId string
+func (g *googlePeopleAPI) mapToGooglePeopleProfile(gapiRes *googlePeopleApiResponse) (*Profile, error) {
 	var email string
+	var emailVerified bool
 	var photoUrl string
 	var displayName string
 
-	// must not be null
-	if gapiRes.ResourceName != "" {
-		replaceResourceName := strings.ReplaceAll(gapiRes.ResourceName, "people/", "")
-		if replaceResourceName != "" {
-			socialId = replaceResourceName
-		} else {
-			socialId = uuid.NewString()
-		}
-	} else {
-		socialId = uuid.NewString()
+	// the social id is the only thing identifying the account, so it must not be guessed
+	socialId := strings.TrimPrefix(gapiRes.ResourceName, "people/")
+	if socialId == "" || socialId == gapiRes.ResourceName {
+		return nil, errors.Wrap(ErrInvalidProfile, "social googlePeopleAPI missing resourceName")
 	}
 
-	if len(gapiRes.EmailAddresses) > 0 {
-		value := gapiRes.EmailAddresses[0].Value
-		if value != "" {
-			email = value
+	// only a verified address can be trusted, the primary one is preferred
+	for _, e := range gapiRes.EmailAddresses {
+		if e.Value == "" || !e.Metadata.Verified {
+			continue
+		}
+		if email == "" || e.Metadata.Primary {
+			email = e.Value
+			emailVerified = true
+		}
+		if e.Metadata.Primary {
+			break
 		}
 	}
 
-	if len(gapiRes.Photos) > 0 {
-		url := gapiRes.Photos[0].Url
-		if len(url) != 0 {
-			photoUrl = url
+	for _, p := range gapiRes.Photos {
+		// the default photo is the silhouette placeholder of google
+		if p.Url == "" || p.Default {
+			continue
+		}
+		if photoUrl == "" || p.MetaData.Primary {
+			photoUrl = p.Url
+		}
+		if p.MetaData.Primary {
+			break
 		}
 	}
 
-	if len(gapiRes.Names) > 0 {
-		name := strings.Split(gapiRes.Names[0].DisplayName, " ")[0]
-		if name != "" {
+	for _, n := range gapiRes.Names {
+		name := strings.TrimSpace(n.DisplayName)
+		if name == "" {
+			continue
+		}
+		if displayName == "" || n.Metadata.Primary {
 			displayName = name
 		}
+		if n.Metadata.Primary {
+			break
+		}
 	}
 
-	return &googlePeopleProfile{
-		SocialID:    socialId,
-		Email:       email,
-		PhotoUrl:    photoUrl,
-		DisplayName: displayName,
+	return &Profile{
+		SocialID:      socialId,
+		Email:         email,
+		EmailVerified: emailVerified,
+		PhotoUrl:      photoUrl,
+		DisplayName:   displayName,
+	}, nil
+}
+
+// retryAfterError keeps the Retry-After delay of a 429 response
+type retryAfterError struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+func retryAfter(err error) (time.Duration, bool) {
+	var e *retryAfterError
+	if errors.As(err, &e) && e.delay > 0 {
+		return e.delay, true
+	}
+	return 0, false
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
 	}
+	return 0
 }
@@ -0,0 +1,72 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	GOOGLE_JWKS_URI = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+var (
+	ErrEmailNotVerified = errors.New("email is not verified")
+
+	// https://developers.google.com/identity/openid-connect/openid-connect#validatinganidtoken
+	googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+	defaultGoogleIDTokenVerifier     *googleIDTokenVerifier
+	onceDefaultGoogleIDTokenVerifier sync.Once
+)
+
+// googleIDTokenVerifier verifies google id tokens locally against the cached google jwks,
+// so that sign-in does not depend on the People API
+type googleIDTokenVerifier struct {
+	verifier *idTokenVerifier
+}
+
+func NewGoogleIDTokenVerifier(clientId, jwksURI string, client *http.Client) *googleIDTokenVerifier {
+	return &googleIDTokenVerifier{
+		verifier: &idTokenVerifier{
+			issuers:  googleIssuers,
+			clientId: clientId,
+			algs:     []string{"RS256"},
+			keys:     newJWKSCache(jwksURI, client),
+			now:      time.Now,
+		},
+	}
+}
+
+func GoogleIDTokenVerifier() *googleIDTokenVerifier {
+	onceDefaultGoogleIDTokenVerifier.Do(func() {
+		defaultGoogleIDTokenVerifier = NewGoogleIDTokenVerifier(
+			env.GoogleClientID(),
+			GOOGLE_JWKS_URI,
+			DefaultHTTPClient(),
+		)
+	})
+	return defaultGoogleIDTokenVerifier
+}
+
+// Verify checks the id token was issued by google for GOOGLE_CLIENT_ID and its email is verified.
+// An empty nonce skips the nonce check, tokens from google sign-in on the client have none.
+func (g *googleIDTokenVerifier) Verify(ctx context.Context, rawIDToken, nonce string) (*Profile, error) {
+	if g.verifier.clientId == "" {
+		return nil, errors.New("googleIDTokenVerifier Verify GOOGLE_CLIENT_ID not set")
+	}
+
+	claims, err := g.verifier.verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	return claims.profile(), nil
+}
@@ -0,0 +1,164 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	ID_TOKEN_CLOCK_SKEW = time.Minute
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+
+	defaultIDTokenSigningAlgs = []string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+// audience is a string or an array of strings in the id token
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = audience(list)
+	return nil
+}
+
+func (a audience) contains(v string) bool {
+	for _, aud := range a {
+		if aud == v {
+			return true
+		}
+	}
+	return false
+}
+
+// flexibleBool accepts "true" as well as true,
+// some providers send email_verified as a string
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var v bool
+	if err := json.Unmarshal(data, &v); err == nil {
+		*b = flexibleBool(v)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*b = flexibleBool(s == "true")
+	return nil
+}
+
+type idTokenClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          audience     `json:"aud"`
+	AuthorizedParty   string       `json:"azp"`
+	ExpiresAt         int64        `json:"exp"`
+	IssuedAt          int64        `json:"iat"`
+	NotBefore         int64        `json:"nbf"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+	Picture           string       `json:"picture"`
+}
+
+// Valid is called by the jwt parser,
+// the claims are validated by idTokenVerifier with clock skew instead
+func (c *idTokenClaims) Valid() error {
+	return nil
+}
+
+func (c *idTokenClaims) profile() *Profile {
+	displayName := c.Name
+	if displayName == "" {
+		displayName = c.PreferredUsername
+	}
+	return &Profile{
+		SocialID:      c.Subject,
+		Email:         c.Email,
+		EmailVerified: bool(c.EmailVerified),
+		PhotoUrl:      c.Picture,
+		DisplayName:   displayName,
+	}
+}
+
+type idTokenVerifier struct {
+	issuers  []string
+	clientId string
+	algs     []string
+	keys     *jwksCache
+	now      func() time.Time
+}
+
+// verify checks the signature, iss, aud, azp, exp, iat, nbf and nonce of the id token.
+// An empty nonce skips the nonce check.
+func (v *idTokenVerifier) verify(ctx context.Context, rawIDToken, nonce string) (*idTokenClaims, error) {
+	claims := idTokenClaims{}
+	parser := &jwt.Parser{
+		ValidMethods:         v.algs,
+		SkipClaimsValidation: true,
+	}
+	_, err := parser.ParseWithClaims(rawIDToken, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidIDToken, err.Error())
+	}
+
+	validIssuer := false
+	for _, iss := range v.issuers {
+		if claims.Issuer == iss {
+			validIssuer = true
+			break
+		}
+	}
+	if !validIssuer {
+		return nil, errors.Wrapf(ErrInvalidIDToken, "unexpected issuer %q", claims.Issuer)
+	}
+
+	if !claims.Audience.contains(v.clientId) {
+		return nil, errors.Wrap(ErrInvalidIDToken, "audience mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != v.clientId {
+		return nil, errors.Wrap(ErrInvalidIDToken, "authorized party mismatch")
+	}
+
+	now := v.now()
+	if claims.ExpiresAt == 0 || now.Add(-ID_TOKEN_CLOCK_SKEW).Unix() > claims.ExpiresAt {
+		return nil, errors.Wrap(ErrInvalidIDToken, "token is expired")
+	}
+	if claims.IssuedAt > now.Add(ID_TOKEN_CLOCK_SKEW).Unix() {
+		return nil, errors.Wrap(ErrInvalidIDToken, "token used before issued")
+	}
+	if claims.NotBefore > now.Add(ID_TOKEN_CLOCK_SKEW).Unix() {
+		return nil, errors.Wrap(ErrInvalidIDToken, "token is not valid yet")
+	}
+
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, errors.Wrap(ErrInvalidIDToken, "nonce mismatch")
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.Wrap(ErrInvalidIDToken, "missing subject")
+	}
+
+	return &claims, nil
+}
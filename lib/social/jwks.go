@@ -0,0 +1,215 @@
+package social
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	JWKS_DEFAULT_TTL          = time.Hour
+	JWKS_MIN_REFRESH_INTERVAL = time.Second * 30
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jwksCache keeps the public keys of a provider in memory.
+// Keys are refetched when the cache expires (Cache-Control max-age or JWKS_DEFAULT_TTL)
+// or when a token is signed by an unknown kid, which is how providers rotate keys.
+type jwksCache struct {
+	uri       string
+	client    *http.Client
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+	expiresAt time.Time
+}
+
+func newJWKSCache(uri string, client *http.Client) *jwksCache {
+	return &jwksCache{
+		uri:    uri,
+		client: client,
+		keys:   map[string]any{},
+	}
+}
+
+func (c *jwksCache) Key(ctx context.Context, kid string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.After(c.expiresAt) {
+		err := c.refresh(ctx, now)
+		// keep using the previous keys when the provider is temporarily unreachable
+		if err != nil && len(c.keys) == 0 {
+			return nil, err
+		}
+	}
+
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+
+	// throttled, so that tokens with random kids can not make us hammer the provider
+	if now.Sub(c.fetchedAt) >= JWKS_MIN_REFRESH_INTERVAL {
+		if err := c.refresh(ctx, now); err != nil {
+			return nil, err
+		}
+		if key, ok := c.keys[kid]; ok {
+			return key, nil
+		}
+	}
+
+	return nil, errors.Errorf("jwksCache Key unknown kid %q", kid)
+}
+
+func (c *jwksCache) refresh(ctx context.Context, now time.Time) error {
+	c.fetchedAt = now
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.uri, nil)
+	if err != nil {
+		return errors.Wrap(err, "jwksCache refresh createRequest")
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "jwksCache refresh excuteRequest")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("jwksCache refresh unexpected status %d", res.StatusCode)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return errors.Wrap(err, "jwksCache refresh Decode")
+	}
+
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// an unsupported key must not hide the others
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwksCache refresh no usable keys")
+	}
+
+	c.keys = keys
+	c.expiresAt = now.Add(cacheMaxAge(res.Header, JWKS_DEFAULT_TTL))
+	return nil
+}
+
+func (k *jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "jsonWebKey publicKey n")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "jsonWebKey publicKey e")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 {
+			return nil, errors.New("jsonWebKey publicKey invalid rsa exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("jsonWebKey publicKey unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "jsonWebKey publicKey x")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "jsonWebKey publicKey y")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("jsonWebKey publicKey point is not on curve")
+		}
+		return key, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.Errorf("jsonWebKey publicKey unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "jsonWebKey publicKey x")
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jsonWebKey publicKey invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errors.Errorf("jsonWebKey publicKey unsupported kty %q", k.Kty)
+}
+
+func cacheMaxAge(h http.Header, defaultValue time.Duration) time.Duration {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil || seconds <= 0 {
+			break
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultValue
+}
@@ -0,0 +1,194 @@
+package social
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	GOOGLE_AUTHORIZATION_ENDPOINT = "https://accounts.google.com/o/oauth2/v2/auth"
+	GOOGLE_TOKEN_ENDPOINT         = "https://oauth2.googleapis.com/token"
+)
+
+// OAuthProvider runs the server side authorization code flow with PKCE
+type OAuthProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, codeChallenge, nonce, redirectURL string) (string, error)
+	Authenticate(ctx context.Context, code, codeVerifier, nonce, redirectURL string) (*Profile, error)
+}
+
+type oauthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// OAuthProviderByName returns google or one of the OIDC_PROVIDERS
+func OAuthProviderByName(name string) (OAuthProvider, bool) {
+	if strings.ToUpper(name) == "GOOGLE" {
+		if env.GoogleClientID() == "" {
+			return nil, false
+		}
+		return &googleOAuth{
+			clientId:     env.GoogleClientID(),
+			clientSecret: env.GoogleClientSecret(),
+			client:       DefaultHTTPClient(),
+		}, true
+	}
+	return OIDCProvider(name)
+}
+
+// NewPKCE returns the code verifier and its S256 code challenge
+// https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+func NewPKCE() (string, string, error) {
+	verifier, err := RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes encoded as url safe base64,
+// it is used for the state, nonce and code verifier
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "social RandomString")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func authCodeURL(endpoint, clientId string, scopes []string, state, codeChallenge, nonce, redirectURL string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "social authCodeURL Parse")
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientId)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	if nonce != "" {
+		q.Set("nonce", nonce)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func exchangeCode(ctx context.Context, client *http.Client, endpoint, clientId, clientSecret, code, codeVerifier, redirectURL string) (*oauthToken, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("redirect_uri", redirectURL)
+	if clientSecret == "" {
+		// public client
+		form.Set("client_id", clientId)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "social exchangeCode createRequest")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientId), url.QueryEscape(clientSecret))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "social exchangeCode excuteRequest")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("social exchangeCode unexpected status %d", res.StatusCode)
+	}
+
+	var t oauthToken
+	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return nil, errors.Wrap(err, "social exchangeCode Decode")
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("social exchangeCode access_token is empty")
+	}
+	return &t, nil
+}
+
+type googleOAuth struct {
+	clientId     string
+	clientSecret string
+	client       *http.Client
+}
+
+func (g *googleOAuth) Name() string {
+	return "GOOGLE"
+}
+
+func (g *googleOAuth) AuthCodeURL(ctx context.Context, state, codeChallenge, nonce, redirectURL string) (string, error) {
+	return authCodeURL(
+		GOOGLE_AUTHORIZATION_ENDPOINT,
+		g.clientId,
+		[]string{"openid", "email", "profile"},
+		state,
+		codeChallenge,
+		nonce,
+		redirectURL,
+	)
+}
+
+func (g *googleOAuth) Authenticate(ctx context.Context, code, codeVerifier, nonce, redirectURL string) (*Profile, error) {
+	t, err := exchangeCode(ctx, g.client, GOOGLE_TOKEN_ENDPOINT, g.clientId, g.clientSecret, code, codeVerifier, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	if t.IDToken != "" {
+		return GoogleIDTokenVerifier().Verify(ctx, t.IDToken, nonce)
+	}
+	// the access token was minted for our client id by the token endpoint itself
+	return GooglePeopleAPI().Do(ctx, t.AccessToken)
+}
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, codeChallenge, nonce, redirectURL string) (string, error) {
+	if _, err := p.idTokenVerifier(ctx); err != nil {
+		return "", err
+	}
+	return authCodeURL(
+		p.discovery.AuthorizationEndpoint,
+		p.config.ClientID,
+		p.config.Scopes,
+		state,
+		codeChallenge,
+		nonce,
+		redirectURL,
+	)
+}
+
+func (p *oidcProvider) Authenticate(ctx context.Context, code, codeVerifier, nonce, redirectURL string) (*Profile, error) {
+	if _, err := p.idTokenVerifier(ctx); err != nil {
+		return nil, err
+	}
+	t, err := exchangeCode(ctx, p.client, p.discovery.TokenEndpoint, p.config.ClientID, p.config.ClientSecret, code, codeVerifier, redirectURL)
+	if err != nil {
+		return nil, err
+	}
+	if t.IDToken == "" {
+		return nil, errors.Wrap(ErrInvalidIDToken, "token response has no id_token")
+	}
+	return p.VerifyIDToken(ctx, t.IDToken, nonce)
+}
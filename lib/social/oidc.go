@@ -0,0 +1,192 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+	"github.com/rlawnsxo131/madre-server-v3/lib/logger"
+)
+
+var (
+	oidcProviders     map[string]*oidcProvider
+	onceOIDCProviders sync.Once
+
+	// stored in social_account.provider, so it must fit in the column
+	oidcProviderNameRegex = regexp.MustCompile("^[A-Z][A-Z0-9_]{0,49}$")
+	reservedProviderNames = []string{"GOOGLE", "EMAIL"}
+)
+
+type OIDCConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+func (c *OIDCConfig) Validate() error {
+	if !oidcProviderNameRegex.MatchString(c.Name) {
+		return errors.Errorf("OIDCConfig Validate invalid provider name %q", c.Name)
+	}
+	for _, name := range reservedProviderNames {
+		if c.Name == name {
+			return errors.Errorf("OIDCConfig Validate reserved provider name %q", c.Name)
+		}
+	}
+	if c.Issuer == "" {
+		return errors.Errorf("OIDCConfig Validate %s issuer is empty", c.Name)
+	}
+	if c.ClientID == "" {
+		return errors.Errorf("OIDCConfig Validate %s client id is empty", c.Name)
+	}
+	return nil
+}
+
+type oidcDiscoveryDocument struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint"`
+	JwksURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+type oidcProvider struct {
+	config    OIDCConfig
+	client    *http.Client
+	mu        sync.Mutex
+	discovery *oidcDiscoveryDocument
+	verifier  *idTokenVerifier
+}
+
+func NewOIDCProvider(config OIDCConfig, client *http.Client) *oidcProvider {
+	return &oidcProvider{
+		config: config,
+		client: client,
+	}
+}
+
+// OIDCProvider returns the provider configured by OIDC_PROVIDERS,
+// the name is case insensitive as it usually comes from the url.
+func OIDCProvider(name string) (*oidcProvider, bool) {
+	onceOIDCProviders.Do(func() {
+		oidcProviders = map[string]*oidcProvider{}
+		for _, name := range env.OIDCProviders() {
+			config := OIDCConfig{
+				Name:         strings.ToUpper(name),
+				Issuer:       env.OIDCIssuer(name),
+				ClientID:     env.OIDCClientID(name),
+				ClientSecret: env.OIDCClientSecret(name),
+				Scopes:       env.OIDCScopes(name),
+			}
+			if err := config.Validate(); err != nil {
+				logger.DefaultLogger().Err(err).Timestamp().Send()
+				continue
+			}
+			oidcProviders[config.Name] = NewOIDCProvider(config, DefaultHTTPClient())
+		}
+	})
+
+	p, ok := oidcProviders[strings.ToUpper(name)]
+	return p, ok
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+// VerifyIDToken verifies the id token issued by the provider for our client
+// and maps its claims to the social profile.
+func (p *oidcProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Profile, error) {
+	if nonce == "" {
+		return nil, errors.Wrap(ErrInvalidIDToken, "nonce is required")
+	}
+
+	v, err := p.idTokenVerifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := v.verify(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims.profile(), nil
+}
+
+func (p *oidcProvider) idTokenVerifier(ctx context.Context) (*idTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.verifier != nil {
+		return p.verifier, nil
+	}
+
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	algs := defaultIDTokenSigningAlgs
+	if len(doc.IDTokenSigningAlgValuesSupported) > 0 {
+		algs = []string{}
+		for _, alg := range doc.IDTokenSigningAlgValuesSupported {
+			// never trust a symmetric or unsigned token from the discovery document
+			if alg == "none" || strings.HasPrefix(alg, "HS") {
+				continue
+			}
+			algs = append(algs, alg)
+		}
+	}
+
+	p.discovery = doc
+	p.verifier = &idTokenVerifier{
+		issuers:  []string{doc.Issuer},
+		clientId: p.config.ClientID,
+		algs:     algs,
+		keys:     newJWKSCache(doc.JwksURI, p.client),
+		now:      time.Now,
+	}
+	return p.verifier, nil
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscoveryDocument, error) {
+	url := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "social oidcProvider discover createRequest")
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "social oidcProvider discover excuteRequest")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("social oidcProvider discover unexpected status %d", res.StatusCode)
+	}
+
+	var doc oidcDiscoveryDocument
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, errors.Wrap(err, "social oidcProvider discover Decode")
+	}
+
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, errors.Errorf("social oidcProvider discover issuer mismatch %q", doc.Issuer)
+	}
+	if doc.JwksURI == "" {
+		return nil, errors.New("social oidcProvider discover jwks_uri is empty")
+	}
+
+	return &doc, nil
+}
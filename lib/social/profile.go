@@ -0,0 +1,43 @@
+package social
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+var (
+	defaultHTTPClient     *http.Client
+	onceDefaultHTTPClient sync.Once
+)
+
+// errors returned by the providers apis, mapped to http responses by the auth routes
+var (
+	ErrProviderUnauthorized = errors.New("provider unauthorized")
+	ErrProviderForbidden    = errors.New("provider forbidden")
+	ErrProviderRateLimited  = errors.New("provider rate limited")
+	ErrProviderUnavailable  = errors.New("provider unavailable")
+	ErrInvalidProfile       = errors.New("invalid provider profile")
+)
+
+// DefaultHTTPClient is shared by the providers, the timeout is SOCIAL_HTTP_TIMEOUT
+func DefaultHTTPClient() *http.Client {
+	onceDefaultHTTPClient.Do(func() {
+		defaultHTTPClient = &http.Client{
+			Timeout: env.SocialHTTPTimeout(),
+		}
+	})
+	return defaultHTTPClient
+}
+
+// Profile is the user information given by a social or oidc provider,
+// it is mapped to the user and social_account on sign-up
+type Profile struct {
+	SocialID      string
+	Email         string
+	EmailVerified bool
+	PhotoUrl      string
+	DisplayName   string
+}
@@ -0,0 +1,53 @@
+package social_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/social"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GoogleIDTokenVerifier_Verify_IsValid(t *testing.T) {
+	assert := assert.New(t)
+	idp := newTestIdP(t)
+
+	c := idp.claims()
+	c["iss"] = "https://accounts.google.com"
+	delete(c, "nonce")
+
+	v := social.NewGoogleIDTokenVerifier("madre", idp.srv.URL+"/jwks", idp.srv.Client())
+	sp, err := v.Verify(context.Background(), idp.sign(t, c), "")
+
+	assert.Nil(err)
+	assert.Equal("subject", sp.SocialID)
+	assert.Equal("user@example.com", sp.Email)
+}
+
+func Test_GoogleIDTokenVerifier_Verify_UnverifiedEmailIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	idp := newTestIdP(t)
+
+	c := idp.claims()
+	c["iss"] = "accounts.google.com"
+	c["email_verified"] = false
+
+	v := social.NewGoogleIDTokenVerifier("madre", idp.srv.URL+"/jwks", idp.srv.Client())
+	_, err := v.Verify(context.Background(), idp.sign(t, c), "")
+
+	assert.True(errors.Is(err, social.ErrEmailNotVerified))
+}
+
+func Test_GoogleIDTokenVerifier_Verify_OtherClientIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	idp := newTestIdP(t)
+
+	c := idp.claims()
+	c["iss"] = "accounts.google.com"
+
+	v := social.NewGoogleIDTokenVerifier("other-client", idp.srv.URL+"/jwks", idp.srv.Client())
+	_, err := v.Verify(context.Background(), idp.sign(t, c), "")
+
+	assert.True(errors.Is(err, social.ErrInvalidIDToken))
+}
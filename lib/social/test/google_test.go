@@ -0,0 +1,147 @@
+package social_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/social"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGooglePeopleResponse = `{
+	"resourceName": "people/1234",
+	"names": [{"metadata": {"primary": true}, "displayName": "Madre User"}],
+	"photos": [{"metadata": {"primary": true}, "url": "https://example.com/photo.png"}],
+	"emailAddresses": [{"metadata": {"primary": true, "verified": true}, "value": "user@gmail.com"}]
+}`
+
+// newTestGooglePeopleServer answers with the statuses in order, then with body
+func newTestGooglePeopleServer(t *testing.T, body string, statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if int(n) <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func Test_GooglePeopleAPI_Do_IsValid(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := newTestGooglePeopleServer(t, testGooglePeopleResponse)
+
+	ggp, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.Nil(err)
+	assert.Equal("1234", ggp.SocialID)
+	assert.Equal("user@gmail.com", ggp.Email)
+}
+
+func Test_GooglePeopleAPI_Do_UnauthorizedIsNotRetried(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := newTestGooglePeopleServer(t, testGooglePeopleResponse, http.StatusUnauthorized)
+
+	_, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.True(errors.Is(err, social.ErrProviderUnauthorized))
+	assert.Equal(int32(1), atomic.LoadInt32(calls))
+}
+
+func Test_GooglePeopleAPI_Do_ForbiddenIsTyped(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := newTestGooglePeopleServer(t, testGooglePeopleResponse, http.StatusForbidden)
+
+	_, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.True(errors.Is(err, social.ErrProviderForbidden))
+}
+
+func Test_GooglePeopleAPI_Do_UnavailableIsRetried(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := newTestGooglePeopleServer(t, testGooglePeopleResponse, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	ggp, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.Nil(err)
+	assert.Equal("1234", ggp.SocialID)
+	assert.Equal(int32(3), atomic.LoadInt32(calls))
+}
+
+func Test_GooglePeopleAPI_Do_UnavailableAfterAllAttempts(t *testing.T) {
+	assert := assert.New(t)
+	srv, calls := newTestGooglePeopleServer(
+		t,
+		testGooglePeopleResponse,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+	)
+
+	_, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.True(errors.Is(err, social.ErrProviderUnavailable))
+	assert.Equal(int32(social.GOOGLE_PEOPLE_API_MAX_ATTEMPTS), atomic.LoadInt32(calls))
+}
+
+func Test_GooglePeopleAPI_Do_MissingResourceNameIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := newTestGooglePeopleServer(t, `{"emailAddresses": [{"value": "user@gmail.com"}]}`)
+
+	ggp, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.Nil(ggp)
+	assert.True(errors.Is(err, social.ErrInvalidProfile))
+}
+
+func Test_GooglePeopleAPI_Do_SelectsPrimaryAndVerified(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := newTestGooglePeopleServer(t, `{
+		"resourceName": "people/1234",
+		"names": [
+			{"metadata": {"primary": false}, "displayName": "Other Name"},
+			{"metadata": {"primary": true}, "displayName": "Madre Full Name"}
+		],
+		"photos": [
+			{"metadata": {"primary": false}, "url": "https://example.com/other.png"},
+			{"metadata": {"primary": true}, "url": "https://example.com/primary.png"}
+		],
+		"emailAddresses": [
+			{"metadata": {"primary": true, "verified": false}, "value": "unverified@gmail.com"},
+			{"metadata": {"primary": false, "verified": true}, "value": "verified@gmail.com"}
+		]
+	}`)
+
+	ggp, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.Nil(err)
+	assert.Equal("verified@gmail.com", ggp.Email)
+	assert.True(ggp.EmailVerified)
+	assert.Equal("https://example.com/primary.png", ggp.PhotoUrl)
+	assert.Equal("Madre Full Name", ggp.DisplayName)
+}
+
+func Test_GooglePeopleAPI_Do_WithoutVerifiedEmail(t *testing.T) {
+	assert := assert.New(t)
+	srv, _ := newTestGooglePeopleServer(t, `{
+		"resourceName": "people/1234",
+		"emailAddresses": [{"metadata": {"primary": true, "verified": false}, "value": "user@gmail.com"}]
+	}`)
+
+	ggp, err := social.NewGooglePeopleAPI(srv.Client(), srv.URL).Do(context.Background(), "access-token")
+
+	assert.Nil(err)
+	assert.Equal("", ggp.Email)
+	assert.False(ggp.EmailVerified)
+}
@@ -0,0 +1,208 @@
+package social_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/social"
+	"github.com/stretchr/testify/assert"
+)
+
+type testIdP struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+	kid string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, kid: "test-key"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.srv.URL,
+			"authorization_endpoint":                idp.srv.URL + "/authorize",
+			"token_endpoint":                        idp.srv.URL + "/token",
+			"jwks_uri":                              idp.srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256", "HS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": idp.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+			}},
+		})
+	})
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+
+	return idp
+}
+
+func (idp *testIdP) provider() social.OIDCConfig {
+	return social.OIDCConfig{
+		Name:     "KEYCLOAK",
+		Issuer:   idp.srv.URL,
+		ClientID: "madre",
+	}
+}
+
+func (idp *testIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	tk := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tk.Header["kid"] = idp.kid
+	ss, err := tk.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss
+}
+
+func (idp *testIdP) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.srv.URL,
+		"sub":            "subject",
+		"aud":            "madre",
+		"exp":            now.Add(time.Minute * 5).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          "nonce",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Madre User",
+		"picture":        "https://example.com/photo.png",
+	}
+}
+
+func Test_OIDCProvider_VerifyIDToken_IsValid(t *testing.T) {
+	assert := assert.New(t)
+	idp := newTestIdP(t)
+
+	p := social.NewOIDCProvider(idp.provider(), idp.srv.Client())
+	sp, err := p.VerifyIDToken(context.Background(), idp.sign(t, idp.claims()), "nonce")
+
+	assert.Nil(err)
+	assert.Equal("subject", sp.SocialID)
+	assert.Equal("user@example.com", sp.Email)
+	assert.True(sp.EmailVerified)
+	assert.Equal("Madre User", sp.DisplayName)
+	assert.Equal("https://example.com/photo.png", sp.PhotoUrl)
+}
+
+func Test_OIDCProvider_VerifyIDToken_IsInvalid(t *testing.T) {
+	idp := newTestIdP(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		nonce string
+	}{
+		{"wrong issuer", func() string {
+			c := idp.claims()
+			c["iss"] = "https://evil.example.com"
+			return idp.sign(t, c)
+		}, "nonce"},
+		{"wrong audience", func() string {
+			c := idp.claims()
+			c["aud"] = "other"
+			return idp.sign(t, c)
+		}, "nonce"},
+		{"multiple audiences without azp", func() string {
+			c := idp.claims()
+			c["aud"] = []string{"madre", "other"}
+			return idp.sign(t, c)
+		}, "nonce"},
+		{"expired", func() string {
+			c := idp.claims()
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+			return idp.sign(t, c)
+		}, "nonce"},
+		{"nonce mismatch", func() string {
+			return idp.sign(t, idp.claims())
+		}, "other"},
+		{"missing nonce", func() string {
+			return idp.sign(t, idp.claims())
+		}, ""},
+		{"wrong signature", func() string {
+			tk := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims())
+			tk.Header["kid"] = idp.kid
+			ss, _ := tk.SignedString(otherKey)
+			return ss
+		}, "nonce"},
+		{"symmetric algorithm", func() string {
+			tk := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims())
+			tk.Header["kid"] = idp.kid
+			ss, _ := tk.SignedString([]byte("secret"))
+			return ss
+		}, "nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := social.NewOIDCProvider(idp.provider(), idp.srv.Client())
+			_, err := p.VerifyIDToken(context.Background(), tt.token(), tt.nonce)
+
+			assert.True(t, errors.Is(err, social.ErrInvalidIDToken))
+		})
+	}
+}
+
+func Test_OIDCConfig_Validate_ReservedNameIsInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	c := social.OIDCConfig{
+		Name:     "GOOGLE",
+		Issuer:   "https://accounts.google.com",
+		ClientID: "madre",
+	}
+
+	assert.NotNil(c.Validate())
+}
+
+func Test_OIDCProvider_AuthCodeURL_HasStateNonceAndPKCE(t *testing.T) {
+	assert := assert.New(t)
+	idp := newTestIdP(t)
+
+	verifier, challenge, err := social.NewPKCE()
+	assert.Nil(err)
+	sum := sha256.Sum256([]byte(verifier))
+	assert.Equal(base64.RawURLEncoding.EncodeToString(sum[:]), challenge)
+
+	p := social.NewOIDCProvider(idp.provider(), idp.srv.Client())
+	raw, err := p.AuthCodeURL(context.Background(), "state", challenge, "nonce", "http://localhost:5000/api/v1/auth/keycloak/callback")
+	assert.Nil(err)
+
+	u, err := url.Parse(raw)
+	assert.Nil(err)
+	assert.Equal(idp.srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal("code", u.Query().Get("response_type"))
+	assert.Equal("state", u.Query().Get("state"))
+	assert.Equal("nonce", u.Query().Get("nonce"))
+	assert.Equal(challenge, u.Query().Get("code_challenge"))
+	assert.Equal("S256", u.Query().Get("code_challenge_method"))
+}
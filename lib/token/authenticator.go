@@ -0,0 +1,137 @@
+package token
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+)
+
+// AuthOutcome tells what Authenticate did with the cookies of a request
+type AuthOutcome string
+
+const (
+	// AUTH_OUTCOME_ANONYMOUS is a request without auth cookies
+	AUTH_OUTCOME_ANONYMOUS AuthOutcome = "anonymous"
+	// AUTH_OUTCOME_AUTHENTICATED is a valid access token
+	AUTH_OUTCOME_AUTHENTICATED AuthOutcome = "authenticated"
+	// AUTH_OUTCOME_REFRESHED is an expired or missing access token replaced with the refresh token
+	AUTH_OUTCOME_REFRESHED AuthOutcome = "refreshed"
+	// AUTH_OUTCOME_EXPIRED is an expired access token without a usable refresh token
+	AUTH_OUTCOME_EXPIRED AuthOutcome = "expired"
+	// AUTH_OUTCOME_MALFORMED is a cookie which is not a token
+	AUTH_OUTCOME_MALFORMED AuthOutcome = "malformed"
+	// AUTH_OUTCOME_INVALID_SIGNATURE is a token signed with an unknown key, forged or of a removed key
+	AUTH_OUTCOME_INVALID_SIGNATURE AuthOutcome = "invalid_signature"
+	// AUTH_OUTCOME_INVALID_CLAIMS is a token of another issuer or audience, or of the other token type
+	AUTH_OUTCOME_INVALID_CLAIMS AuthOutcome = "invalid_claims"
+	// AUTH_OUTCOME_SESSION_INVALID is a refresh token of a revoked session or used twice
+	AUTH_OUTCOME_SESSION_INVALID AuthOutcome = "session_invalid"
+	// AUTH_OUTCOME_ROTATED is a refresh token a concurrent request just rotated, the request goes on
+	// anonymously and the cookies are kept for the ones the other request sets
+	AUTH_OUTCOME_ROTATED AuthOutcome = "rotated"
+	// AUTH_OUTCOME_ERROR is a failure of the session store, the cookies are kept to retry
+	AUTH_OUTCOME_ERROR AuthOutcome = "error"
+)
+
+// Authentication is the result of Authenticate,
+// Profile is only set when the outcome is authenticated or refreshed
+type Authentication struct {
+	Outcome AuthOutcome
+	Profile *profile
+	// Err is set when the outcome is worth logging, an expected expiry has none
+	Err error
+}
+
+// CookiesReset tells if Authenticate removed the cookies, they can not authenticate again
+func (a *Authentication) CookiesReset() bool {
+	switch a.Outcome {
+	case AUTH_OUTCOME_ANONYMOUS, AUTH_OUTCOME_AUTHENTICATED, AUTH_OUTCOME_REFRESHED, AUTH_OUTCOME_ROTATED, AUTH_OUTCOME_ERROR:
+		return false
+	}
+	return true
+}
+
+// Authenticate reads the auth cookies: the access token is decoded, when it expired or its cookie did
+// the refresh token rotates the session and the new tokens are set in the cookies.
+// Unusable cookies are reset so that the browser stops sending them.
+func (m *manager) Authenticate(r *http.Request, w http.ResponseWriter) *Authentication {
+	actk := cookieValue(r, ACCESS_TOKEN)
+	rftk := cookieValue(r, REFRESH_TOKEN)
+	if actk == "" && rftk == "" {
+		return &Authentication{Outcome: AUTH_OUTCOME_ANONYMOUS}
+	}
+
+	if actk != "" {
+		claims, err := m.DecodeAccessToken(actk)
+		if err == nil {
+			return &Authentication{Outcome: AUTH_OUTCOME_AUTHENTICATED, Profile: claims.toProfile()}
+		}
+		if outcome := tokenErrorOutcome(err); outcome != AUTH_OUTCOME_EXPIRED {
+			return m.failAuthentication(w, outcome, errors.Wrap(err, "Authenticate access token"))
+		}
+	}
+
+	if rftk == "" {
+		return m.failAuthentication(w, AUTH_OUTCOME_EXPIRED, nil)
+	}
+
+	claims, err := m.Decode(rftk)
+	if err != nil {
+		outcome := tokenErrorOutcome(err)
+		if outcome == AUTH_OUTCOME_EXPIRED {
+			return m.failAuthentication(w, outcome, nil)
+		}
+		return m.failAuthentication(w, outcome, errors.Wrap(err, "Authenticate refresh token"))
+	}
+	if claims.TokenType == TOKEN_TYPE_ACCESS {
+		return m.failAuthentication(w, AUTH_OUTCOME_INVALID_CLAIMS, errors.New("Authenticate: not a refresh token"))
+	}
+
+	p, nextActk, nextRftk, err := m.refreshSession(claims)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenRotated) {
+			return &Authentication{Outcome: AUTH_OUTCOME_ROTATED}
+		}
+		if errors.Is(err, ErrSessionInvalid) {
+			return m.failAuthentication(w, AUTH_OUTCOME_SESSION_INVALID, err)
+		}
+		return &Authentication{Outcome: AUTH_OUTCOME_ERROR, Err: err}
+	}
+	m.setCookies(w, nextActk, nextRftk)
+
+	return &Authentication{Outcome: AUTH_OUTCOME_REFRESHED, Profile: p}
+}
+
+func (m *manager) failAuthentication(w http.ResponseWriter, outcome AuthOutcome, err error) *Authentication {
+	m.ResetCookies(w)
+	return &Authentication{Outcome: outcome, Err: err}
+}
+
+// tokenErrorOutcome classifies an error of Decode, the signature is checked before the expiry
+// because a forged token which also expired has both errors
+func tokenErrorOutcome(err error) AuthOutcome {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return AUTH_OUTCOME_MALFORMED
+	}
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return AUTH_OUTCOME_MALFORMED
+	case ve.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable) != 0:
+		return AUTH_OUTCOME_INVALID_SIGNATURE
+	case ve.Errors&(jwt.ValidationErrorIssuer|jwt.ValidationErrorAudience|jwt.ValidationErrorClaimsInvalid) != 0:
+		return AUTH_OUTCOME_INVALID_CLAIMS
+	case ve.Errors&jwt.ValidationErrorExpired != 0:
+		return AUTH_OUTCOME_EXPIRED
+	}
+	return AUTH_OUTCOME_INVALID_CLAIMS
+}
+
+func cookieValue(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
@@ -0,0 +1,93 @@
+package token
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	TOKEN_TYPE_BEARER = "Bearer"
+
+	// TOKEN_DELIVERY_BODY is asked by the clients without cookies, e.g. ?token_delivery=body
+	TOKEN_DELIVERY       = "token_delivery"
+	TOKEN_DELIVERY_BODY  = "body"
+	AUTHORIZATION_HEADER = "Authorization"
+)
+
+// Tokens are answered in the body to the clients which send them back in the Authorization header
+type Tokens struct {
+	TokenType    string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// BearerToken returns the token of the Authorization header, it is empty for another scheme
+func BearerToken(r *http.Request) string {
+	scheme, t, ok := strings.Cut(r.Header.Get(AUTHORIZATION_HEADER), " ")
+	if !ok || !strings.EqualFold(scheme, TOKEN_TYPE_BEARER) {
+		return ""
+	}
+	return strings.TrimSpace(t)
+}
+
+// GenerateTokens is GenerateAndSetCookies for the clients without cookies
+func (m *manager) GenerateTokens(p *profile, client SessionClient) (*Tokens, error) {
+	actk, rftk, err := m.issueTokens(p, client)
+	if err != nil {
+		return nil, err
+	}
+	return m.newTokens(actk, rftk), nil
+}
+
+// Issue answers the tokens when inBody, otherwise they are set in the cookies and nil is returned
+func (m *manager) Issue(p *profile, client SessionClient, w http.ResponseWriter, inBody bool) (*Tokens, error) {
+	if inBody {
+		return m.GenerateTokens(p, client)
+	}
+	return nil, m.GenerateAndSetCookies(p, client, w)
+}
+
+// RefreshTokens is Refresh for the clients without cookies
+func (m *manager) RefreshTokens(refreshToken string) (*profile, *Tokens, error) {
+	p, actk, rftk, err := m.refresh(refreshToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, m.newTokens(actk, rftk), nil
+}
+
+// DecodeAccessToken is Decode refusing the refresh tokens, they must not authenticate a request
+func (m *manager) DecodeAccessToken(token string) (*authTokenClaims, error) {
+	claims, err := m.Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType == TOKEN_TYPE_REFRESH {
+		return nil, jwt.NewValidationError("DecodeAccessToken: not an access token", jwt.ValidationErrorClaimsInvalid)
+	}
+	return claims, nil
+}
+
+// ProfileFromAccessToken returns the profile of the request authenticated by the Authorization header
+func (m *manager) ProfileFromAccessToken(token string) (*profile, error) {
+	claims, err := m.DecodeAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	p := claims.toProfile()
+	p.Bearer = true
+	return p, nil
+}
+
+func (m *manager) newTokens(actk, rftk string) *Tokens {
+	return &Tokens{
+		TokenType:    TOKEN_TYPE_BEARER,
+		AccessToken:  actk,
+		RefreshToken: rftk,
+		ExpiresIn:    int64(m.opts.AccessTokenTTL.Seconds()),
+	}
+}
@@ -0,0 +1,70 @@
+package token
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+const (
+	// the csrf token is a double submit cookie, the client reads it and sends it back in CSRF_HEADER
+	CSRF_TOKEN  = "Csrf_token"
+	CSRF_HEADER = "X-CSRF-Token"
+
+	KEY_CSRF_TOKEN_CTX = "KEY_CSRF_TOKEN_CTX"
+)
+
+// SetCSRFCookie issues a new csrf token, it is readable by the scripts of the client unlike the auth tokens
+func (m *manager) SetCSRFCookie(w http.ResponseWriter) (string, error) {
+	raw, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	c := m.opts.cookie(CSRF_TOKEN, raw, time.Now().Add(m.opts.RefreshTokenTTL))
+	c.HttpOnly = false
+	http.SetCookie(w, c)
+	return raw, nil
+}
+
+// CSRFCookie is empty when the request has no csrf cookie
+func CSRFCookie(r *http.Request) string {
+	c, err := r.Cookie(CSRF_TOKEN)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// CSRFTokenMatches compares the csrf header with the cookie in constant time
+func CSRFTokenMatches(r *http.Request) bool {
+	cookie := CSRFCookie(r)
+	header := r.Header.Get(CSRF_HEADER)
+	if cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// HasAuthCookies tells if the request can be authenticated by the cookies,
+// the browser sends them with the requests of the other sites too
+func HasAuthCookies(r *http.Request) bool {
+	for _, name := range tokenTypes {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CSRFTokenCtx is the csrf token of the client, the one of its cookie or the one issued for this request
+func CSRFTokenCtx(ctx context.Context) string {
+	if v, ok := ctx.Value(KEY_CSRF_TOKEN_CTX).(string); ok {
+		return v
+	}
+	return ""
+}
+
+func SetCSRFToken(ctx context.Context, v string) context.Context {
+	return context.WithValue(ctx, KEY_CSRF_TOKEN_CTX, v)
+}
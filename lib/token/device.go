@@ -0,0 +1,37 @@
+package token
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// DEVICE_ID tells the devices of a user apart in the login history, the clients without cookies
+	// send the id they keep in DEVICE_ID_HEADER
+	DEVICE_ID            = "Device_id"
+	DEVICE_ID_HEADER     = "X-Device-ID"
+	DEVICE_ID_TTL        = time.Hour * 24 * 365
+	DEVICE_ID_MAX_LENGTH = 128
+)
+
+// DeviceID returns the id of the device of the request, a device without one gets a new cookie
+func (m *manager) DeviceID(r *http.Request, w http.ResponseWriter) (string, error) {
+	if v := cookieValue(r, DEVICE_ID); v != "" && len(v) <= DEVICE_ID_MAX_LENGTH {
+		return v, nil
+	}
+	if v := r.Header.Get(DEVICE_ID_HEADER); v != "" && len(v) <= DEVICE_ID_MAX_LENGTH {
+		return v, nil
+	}
+
+	raw, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, m.opts.cookie(DEVICE_ID, raw, time.Now().Add(DEVICE_ID_TTL)))
+	return raw, nil
+}
+
+// DeviceFingerprint is stored instead of the device id, which authenticates nothing but is kept private
+func DeviceFingerprint(deviceId string) string {
+	return HashOpaqueToken(deviceId)
+}
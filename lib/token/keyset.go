@@ -0,0 +1,240 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	RSA_MIN_KEY_BITS = 2048
+)
+
+var (
+	ErrUnsupportedKey = errors.New("key is not an rsa or ed25519 key")
+	ErrUnknownKey     = errors.New("token is signed by an unknown key")
+
+	keySet *KeySet
+)
+
+// KeySet signs the auth tokens with its active key and verifies them with any of its keys by kid,
+// a rotation adds the next key as a previous one first so that it is published before it signs
+type KeySet struct {
+	active *signingKey
+	keys   []*signingKey
+}
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// JWK is a public key of the key set as published by the jwks endpoint
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// SetKeySet is called once at startup, without a key set the auth tokens are signed with JWT_SECRET_KEY
+func SetKeySet(ks *KeySet) {
+	keySet = ks
+}
+
+// DefaultKeySet is the key set given to SetKeySet, it is nil when the tokens are signed with JWT_SECRET_KEY
+func DefaultKeySet() *KeySet {
+	return keySet
+}
+
+// LoadKeySet reads PEM files, the active one must hold a private key,
+// the previous ones are only used to verify and may hold a public key
+func LoadKeySet(activeFile string, previousFiles []string) (*KeySet, error) {
+	active, err := os.ReadFile(activeFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "LoadKeySet")
+	}
+
+	previous := make([][]byte, 0, len(previousFiles))
+	for _, f := range previousFiles {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "LoadKeySet")
+		}
+		previous = append(previous, b)
+	}
+
+	return NewKeySet(active, previous...)
+}
+
+func NewKeySet(active []byte, previous ...[]byte) (*KeySet, error) {
+	k, err := parseSigningKey(active)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewKeySet active key")
+	}
+	if k.private == nil {
+		return nil, errors.New("NewKeySet: active key has no private key")
+	}
+
+	ks := &KeySet{active: k, keys: []*signingKey{k}}
+	for _, b := range previous {
+		k, err := parseSigningKey(b)
+		if err != nil {
+			return nil, errors.Wrap(err, "NewKeySet previous key")
+		}
+		if ks.key(k.id) != nil {
+			continue
+		}
+		ks.keys = append(ks.keys, k)
+	}
+
+	return ks, nil
+}
+
+// ActiveKeyID is the kid of the tokens signed now
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.id
+}
+
+func (ks *KeySet) JWKS() *JWKS {
+	jwks := &JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		jwks.Keys = append(jwks.Keys, k.jwk())
+	}
+	return jwks
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	t := jwt.NewWithClaims(ks.active.method, claims)
+	t.Header["kid"] = ks.active.id
+	return t.SignedString(ks.active.private)
+}
+
+// verificationKey is the jwt.Keyfunc of the asymmetric tokens, the algorithm must be the one of the kid
+func (ks *KeySet) verificationKey(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	k := ks.key(kid)
+	if k == nil {
+		return nil, ErrUnknownKey
+	}
+	if t.Method.Alg() != k.method.Alg() {
+		return nil, errors.New("verificationKey: algorithm does not match the key")
+	}
+	return k.public, nil
+}
+
+func (ks *KeySet) key(kid string) *signingKey {
+	for _, k := range ks.keys {
+		if k.id == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// parseSigningKey reads a PKCS#8, PKCS#1 or PKIX PEM block
+func parseSigningKey(b []byte) (*signingKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("parseSigningKey: no PEM block")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, errors.Errorf("parseSigningKey: unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parseSigningKey")
+	}
+
+	k := &signingKey{}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, key
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, key
+	default:
+		return nil, ErrUnsupportedKey
+	}
+	if pub, ok := k.public.(*rsa.PublicKey); ok && pub.N.BitLen() < RSA_MIN_KEY_BITS {
+		return nil, errors.Errorf("parseSigningKey: rsa key is shorter than %d bits", RSA_MIN_KEY_BITS)
+	}
+
+	k.id = k.thumbprint()
+	return k, nil
+}
+
+func (k *signingKey) jwk() JWK {
+	jwk := JWK{Kid: k.id, Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// thumbprint is the RFC 7638 thumbprint of the public key, so the kid does not have to be configured
+func (k *signingKey) thumbprint() string {
+	jwk := k.jwk()
+
+	// the members are the required ones in lexicographic order
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
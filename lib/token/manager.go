@@ -13,6 +13,9 @@ import (
 const (
 	ACCESS_TOKEN  = "Access_token"
 	REFRESH_TOKEN = "Refresh_token"
+
+	TOKEN_TYPE_ACCESS  = "access"
+	TOKEN_TYPE_REFRESH = "refresh"
 )
 
 var (
@@ -20,21 +23,37 @@ var (
 )
 
 type authTokenClaims struct {
+	// TokenType is empty in the tokens issued before it was added
+	TokenType string `json:"token_type,omitempty"`
 	TokenUUID string `json:"token_uuid"`
+	SessionID string `json:"session_id"`
 	UserID    string `json:"user_id"`
 	Username  string `json:"username"`
 	PhotoUrl  string `json:"photo_url"`
+	// the refresh token does not grant them, they are read again by the session store
+	Roles []string `json:"roles,omitempty"`
 	jwt.StandardClaims
 }
 
-type manager struct{}
+type manager struct {
+	sessions SessionStore
+	keys     *KeySet
+	opts     Options
+}
 
+// NewManager uses the options given to SetOptions, NewManagerWithOptions the ones given
 func NewManager() *manager {
-	return &manager{}
+	return NewManagerWithOptions(options)
+}
+
+func NewManagerWithOptions(o Options) *manager {
+	return &manager{sessionStore, keySet, o}
 }
 
-func (m *manager) GenerateAndSetCookies(p *profile, w http.ResponseWriter) error {
-	actk, rftk, err := m.generateTokens(p)
+// GenerateAndSetCookies starts a session on the device of client when the profile has none, otherwise
+// the tokens are reissued in the session of the profile and its previous refresh token is retired
+func (m *manager) GenerateAndSetCookies(p *profile, client SessionClient, w http.ResponseWriter) error {
+	actk, rftk, err := m.issueTokens(p, client)
 	if err != nil {
 		return err
 	}
@@ -42,11 +61,40 @@ func (m *manager) GenerateAndSetCookies(p *profile, w http.ResponseWriter) error
 	return nil
 }
 
+// issueTokens starts or rotates the session of the profile and signs its tokens
+func (m *manager) issueTokens(p *profile, client SessionClient) (string, string, error) {
+	now := time.Now()
+	refreshTokenUUID, err := m.startSession(p, client, now.Add(m.opts.RefreshTokenTTL))
+	if err != nil {
+		return "", "", err
+	}
+	return m.generateTokens(p, refreshTokenUUID, now)
+}
+
+// toProfile is the profile the tokens were issued for, in their session
+func (c *authTokenClaims) toProfile() *profile {
+	p := NewProfile(
+		c.UserID,
+		c.Username,
+		c.PhotoUrl,
+		c.Roles,
+	)
+	p.SessionID = c.SessionID
+	return p
+}
+
 func (m *manager) Decode(token string) (*authTokenClaims, error) {
 	claims := authTokenClaims{}
 	t, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
-			return []byte(env.JWTSecretKey()), nil
+			// the tokens signed before the key set stay valid until JWT_ACCEPT_HS256 is turned off
+			if m.keys == nil || env.JWTAcceptHS256() {
+				return []byte(env.JWTSecretKey()), nil
+			}
+			return nil, errors.New("Decode: hs256 is not accepted")
+		}
+		if m.keys != nil {
+			return m.keys.verificationKey(t)
 		}
 		return nil, errors.New("Docode: ParseWithClaims")
 	})
@@ -54,6 +102,12 @@ func (m *manager) Decode(token string) (*authTokenClaims, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !claims.VerifyIssuer(m.opts.Issuer, true) {
+		return nil, jwt.NewValidationError("Decode: issuer mismatch", jwt.ValidationErrorIssuer)
+	}
+	if m.opts.Audience != "" && !claims.VerifyAudience(m.opts.Audience, true) {
+		return nil, jwt.NewValidationError("Decode: audience mismatch", jwt.ValidationErrorAudience)
+	}
 
 	if t.Valid {
 		return &claims, nil
@@ -63,61 +117,38 @@ func (m *manager) Decode(token string) (*authTokenClaims, error) {
 }
 
 func (m *manager) ResetCookies(w http.ResponseWriter) {
-	now := time.Now()
-	expires := now.AddDate(0, 0, -1)
-
-	http.SetCookie(w, &http.Cookie{
-		Name:  ACCESS_TOKEN,
-		Value: "",
-		Path:  "/",
-		// Domain:   ".juntae.kim",
-		Expires:  expires,
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: 2,
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:  REFRESH_TOKEN,
-		Value: "",
-		Path:  "/",
-		// Domain:   ".juntae.kim",
-		Expires:  expires,
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: 2,
-	})
+	expires := time.Now().AddDate(0, 0, -1)
+
+	http.SetCookie(w, m.opts.cookie(ACCESS_TOKEN, "", expires))
+	http.SetCookie(w, m.opts.cookie(REFRESH_TOKEN, "", expires))
 }
 
-func (m *manager) generateTokens(p *profile) (string, string, error) {
-	now := time.Now()
+func (m *manager) generateTokens(p *profile, refreshTokenUUID string, now time.Time) (string, string, error) {
 	var actk string
 	var rftk string
 
 	for _, tokenType := range tokenTypes {
 		claims := &authTokenClaims{
 			TokenUUID: uuid.NewString(),
+			SessionID: p.SessionID,
 			UserID:    p.UserID,
 			Username:  p.Username,
 			PhotoUrl:  p.PhotoUrl,
+			Roles:     p.Roles,
 		}
 
 		if tokenType == ACCESS_TOKEN {
-			claims.StandardClaims = jwt.StandardClaims{
-				ExpiresAt: now.AddDate(0, 0, 1).Unix(),
-				Issuer:    "madre",
-				IssuedAt:  now.Unix(),
-			}
+			claims.TokenType = TOKEN_TYPE_ACCESS
+			claims.StandardClaims = m.standardClaims(now, m.opts.AccessTokenTTL)
 		}
 		if tokenType == REFRESH_TOKEN {
-			claims.StandardClaims = jwt.StandardClaims{
-				ExpiresAt: now.AddDate(0, 0, 30).Unix(),
-				Issuer:    "madre",
-				IssuedAt:  now.Unix(),
-			}
+			// the uuid of the refresh token is the one stored in the session
+			claims.TokenType = TOKEN_TYPE_REFRESH
+			claims.TokenUUID = refreshTokenUUID
+			claims.StandardClaims = m.standardClaims(now, m.opts.RefreshTokenTTL)
 		}
 
-		t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		ss, err := t.SignedString([]byte(env.JWTSecretKey()))
+		ss, err := m.sign(claims)
 		if err != nil {
 			return "", "", errors.Wrap(err, "generateTokens")
 		}
@@ -132,27 +163,27 @@ func (m *manager) generateTokens(p *profile) (string, string, error) {
 	return actk, rftk, nil
 }
 
+func (m *manager) standardClaims(now time.Time, ttl time.Duration) jwt.StandardClaims {
+	return jwt.StandardClaims{
+		Audience:  m.opts.Audience,
+		ExpiresAt: now.Add(ttl).Unix(),
+		Issuer:    m.opts.Issuer,
+		IssuedAt:  now.Unix(),
+	}
+}
+
+// sign uses the active key of the key set, or JWT_SECRET_KEY when there is no key set
+func (m *manager) sign(claims *authTokenClaims) (string, error) {
+	if m.keys != nil {
+		return m.keys.sign(claims)
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return t.SignedString([]byte(env.JWTSecretKey()))
+}
+
 func (m *manager) setCookies(w http.ResponseWriter, actk, rftk string) {
 	now := time.Now()
 
-	http.SetCookie(w, &http.Cookie{
-		Name:  ACCESS_TOKEN,
-		Value: actk,
-		Path:  "/",
-		// Domain:   ".juntae.kim",
-		Expires:  now.AddDate(0, 0, 1),
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: 2,
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:  REFRESH_TOKEN,
-		Value: rftk,
-		Path:  "/",
-		// Domain:   ".juntae.kim",
-		Expires:  now.AddDate(0, 0, 30),
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: 2,
-	})
+	http.SetCookie(w, m.opts.cookie(ACCESS_TOKEN, actk, now.Add(m.opts.AccessTokenTTL)))
+	http.SetCookie(w, m.opts.cookie(REFRESH_TOKEN, rftk, now.Add(m.opts.RefreshTokenTTL)))
 }
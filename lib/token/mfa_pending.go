@@ -0,0 +1,87 @@
+package token
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+)
+
+const (
+	MFA_PENDING = "Mfa_pending"
+
+	MFA_PENDING_TTL = time.Minute * 5
+
+	TOKEN_TYPE_MFA_PENDING = "mfa_pending"
+)
+
+// mfaPendingClaims prove the first factor of the sign-in was passed,
+// they are signed with their own key so that they are never accepted as access tokens
+type mfaPendingClaims struct {
+	TokenType string `json:"token_type"`
+	UserID    string `json:"user_id"`
+	jwt.StandardClaims
+}
+
+func (m *manager) GenerateMFAPendingToken(userId string) (string, error) {
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, &mfaPendingClaims{
+		TokenType: TOKEN_TYPE_MFA_PENDING,
+		UserID:    userId,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: now.Add(MFA_PENDING_TTL).Unix(),
+			Issuer:    m.opts.Issuer,
+			IssuedAt:  now.Unix(),
+		},
+	})
+	ss, err := t.SignedString(deriveKey(TOKEN_TYPE_MFA_PENDING))
+	if err != nil {
+		return "", errors.Wrap(err, "GenerateMFAPendingToken")
+	}
+	return ss, nil
+}
+
+// DecodeMFAPendingToken returns the id of the user who passed the first factor
+func (m *manager) DecodeMFAPendingToken(token string) (string, error) {
+	claims := mfaPendingClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return deriveKey(TOKEN_TYPE_MFA_PENDING), nil
+		}
+		return nil, errors.New("DecodeMFAPendingToken: ParseWithClaims")
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "DecodeMFAPendingToken")
+	}
+	if claims.TokenType != TOKEN_TYPE_MFA_PENDING || claims.UserID == "" {
+		return "", errors.New("DecodeMFAPendingToken: not a mfa pending token")
+	}
+	return claims.UserID, nil
+}
+
+// SetMFAPendingCookie is for the oauth callback, which redirects instead of answering the token
+func (m *manager) SetMFAPendingCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     MFA_PENDING,
+		Value:    token,
+		Path:     OAUTH_COOKIE_PATH,
+		Expires:  time.Now().Add(MFA_PENDING_TTL),
+		Domain:   m.opts.CookieDomain,
+		Secure:   m.opts.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (m *manager) MFAPendingCookie(r *http.Request) string {
+	c, err := r.Cookie(MFA_PENDING)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+func (m *manager) ResetMFAPendingCookie(w http.ResponseWriter) {
+	m.resetSignedCookie(w, MFA_PENDING)
+}
@@ -0,0 +1,67 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	OPAQUE_TOKEN_BYTES = 32
+
+	// PERSONAL_ACCESS_TOKEN_PREFIX tells the personal access tokens from the jwts
+	// and lets the secret scanners find them
+	PERSONAL_ACCESS_TOKEN_PREFIX = "madre_pat_"
+	// PERSONAL_ACCESS_TOKEN_ID_LENGTH is the length of the start of a token kept in clear to identify it
+	PERSONAL_ACCESS_TOKEN_ID_LENGTH = len(PERSONAL_ACCESS_TOKEN_PREFIX) + 8
+)
+
+// NewOpaqueToken returns a random token for the user and its hash for the database,
+// so that a leak of the table does not leak usable tokens
+func NewOpaqueToken() (string, string, error) {
+	b := make([]byte, OPAQUE_TOKEN_BYTES)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errors.Wrap(err, "token NewOpaqueToken")
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+	return raw, HashOpaqueToken(raw), nil
+}
+
+func HashOpaqueToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewPersonalAccessToken returns an opaque token with PERSONAL_ACCESS_TOKEN_PREFIX,
+// its hash and the start of it which identifies the token in the lists
+func NewPersonalAccessToken() (string, string, string, error) {
+	raw, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	raw = PERSONAL_ACCESS_TOKEN_PREFIX + raw
+	return raw, HashOpaqueToken(raw), raw[:PERSONAL_ACCESS_TOKEN_ID_LENGTH], nil
+}
+
+func IsPersonalAccessToken(raw string) bool {
+	return strings.HasPrefix(raw, PERSONAL_ACCESS_TOKEN_PREFIX)
+}
+
+// NewNumericCode returns n random digits for the user to type, and its hash
+func NewNumericCode(n int) (string, string, error) {
+	digits := make([]byte, n)
+	for i := range digits {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", "", errors.Wrap(err, "token NewNumericCode")
+		}
+		digits[i] = byte('0' + d.Int64())
+	}
+	code := string(digits)
+	return code, HashOpaqueToken(code), nil
+}
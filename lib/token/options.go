@@ -0,0 +1,131 @@
+package token
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	DEFAULT_ACCESS_TOKEN_TTL  = time.Hour * 24
+	DEFAULT_REFRESH_TOKEN_TTL = time.Hour * 24 * 30
+	DEFAULT_ISSUER            = "madre"
+)
+
+var (
+	ErrInvalidOptions = errors.New("token options are invalid")
+
+	options = DefaultOptions()
+
+	sameSites = map[string]http.SameSite{
+		"lax":    http.SameSiteLaxMode,
+		"strict": http.SameSiteStrictMode,
+		"none":   http.SameSiteNoneMode,
+	}
+)
+
+// Options are the lifetimes and claims of the auth tokens and the attributes of their cookies,
+// they differ between local, staging and production
+type Options struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Issuer          string
+	// Audience is checked on decode when it is set
+	Audience string
+	// CookieDomain is empty for a host-only cookie, e.g. ".juntae.kim" shares the cookies with the subdomains
+	CookieDomain   string
+	CookiePath     string
+	CookieSameSite http.SameSite
+	// CookieSecure is turned off for local http only
+	CookieSecure bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		AccessTokenTTL:  DEFAULT_ACCESS_TOKEN_TTL,
+		RefreshTokenTTL: DEFAULT_REFRESH_TOKEN_TTL,
+		Issuer:          DEFAULT_ISSUER,
+		CookiePath:      "/",
+		CookieSameSite:  http.SameSiteLaxMode,
+		CookieSecure:    true,
+	}
+}
+
+// LoadOptions reads the options from lib/env and validates them, a malformed value fails the startup
+// as does a missing MFA_ENCRYPTION_KEY, which Seal requires
+func LoadOptions() (Options, error) {
+	if env.MFAEncryptionKey() == "" {
+		return Options{}, errors.Wrap(ErrInvalidOptions, ErrSealKeyNotSet.Error())
+	}
+	sameSite, ok := sameSites[strings.ToLower(env.AuthCookieSameSite())]
+	if !ok {
+		return Options{}, errors.Wrapf(ErrInvalidOptions, "unknown same site %s", env.AuthCookieSameSite())
+	}
+	accessTokenTTL, err := env.JWTAccessTokenTTL()
+	if err != nil {
+		return Options{}, errors.Wrap(ErrInvalidOptions, err.Error())
+	}
+	refreshTokenTTL, err := env.JWTRefreshTokenTTL()
+	if err != nil {
+		return Options{}, errors.Wrap(ErrInvalidOptions, err.Error())
+	}
+	cookieSecure, err := env.AuthCookieSecure()
+	if err != nil {
+		return Options{}, errors.Wrap(ErrInvalidOptions, err.Error())
+	}
+	o := Options{
+		AccessTokenTTL:  accessTokenTTL,
+		RefreshTokenTTL: refreshTokenTTL,
+		Issuer:          env.JWTIssuer(),
+		Audience:        env.JWTAudience(),
+		CookieDomain:    env.AuthCookieDomain(),
+		CookiePath:      env.AuthCookiePath(),
+		CookieSameSite:  sameSite,
+		CookieSecure:    cookieSecure,
+	}
+	if err := o.Validate(); err != nil {
+		return Options{}, err
+	}
+	return o, nil
+}
+
+func (o Options) Validate() error {
+	switch {
+	case o.AccessTokenTTL <= 0 || o.RefreshTokenTTL <= 0:
+		return errors.Wrap(ErrInvalidOptions, "the ttls must be positive")
+	case o.AccessTokenTTL > o.RefreshTokenTTL:
+		return errors.Wrap(ErrInvalidOptions, "the access token can not outlive the refresh token")
+	case o.Issuer == "":
+		return errors.Wrap(ErrInvalidOptions, "the issuer is required")
+	case !strings.HasPrefix(o.CookiePath, "/"):
+		return errors.Wrap(ErrInvalidOptions, "the cookie path must start with /")
+	case strings.ContainsAny(o.CookieDomain, "/: "):
+		return errors.Wrap(ErrInvalidOptions, "the cookie domain must be a bare domain")
+	case o.CookieSameSite == http.SameSiteNoneMode && !o.CookieSecure:
+		// the browsers drop the cookies with SameSite=None which are not secure
+		return errors.Wrap(ErrInvalidOptions, "same site none requires secure cookies")
+	}
+	return nil
+}
+
+// SetOptions is called once at startup, the managers use DefaultOptions until then
+func SetOptions(o Options) {
+	options = o
+}
+
+// cookie applies the options to an auth cookie, an expiry in the past removes it
+func (o Options) cookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.CookiePath,
+		Domain:   o.CookieDomain,
+		Expires:  expires,
+		Secure:   o.CookieSecure,
+		HttpOnly: true,
+		SameSite: o.CookieSameSite,
+	}
+}
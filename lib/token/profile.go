@@ -7,13 +7,75 @@ const (
 )
 
 type profile struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	PhotoUrl string `json:"photo_url"`
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	PhotoUrl string   `json:"photo_url"`
+	Roles    []string `json:"roles"`
+	// SessionID is set from the tokens, a profile without session starts a new one
+	SessionID string `json:"-"`
+	// Bearer is set when the access token came in the Authorization header,
+	// the tokens reissued for the profile are answered in the body
+	Bearer bool `json:"-"`
+	// PersonalAccessTokenID is set when a personal access token authenticated the request,
+	// the profile is then limited to Scopes
+	PersonalAccessTokenID string   `json:"-"`
+	Scopes                []string `json:"-"`
 }
 
-func NewProfile(userId, username, photoUrl string) *profile {
-	return &profile{userId, username, photoUrl}
+func NewProfile(userId, username, photoUrl string, roles []string) *profile {
+	if roles == nil {
+		roles = []string{}
+	}
+	return &profile{
+		UserID:   userId,
+		Username: username,
+		PhotoUrl: photoUrl,
+		Roles:    roles,
+	}
+}
+
+func (p *profile) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonalAccessTokenOwner is what a personal access token resolves to
+type PersonalAccessTokenOwner struct {
+	TokenID  string
+	UserID   string
+	Username string
+	PhotoUrl string
+	Scopes   []string
+}
+
+// NewPersonalAccessTokenProfile is the profile of a request authenticated by a personal access token,
+// it has no roles and no session
+func NewPersonalAccessTokenProfile(o *PersonalAccessTokenOwner) *profile {
+	p := NewProfile(o.UserID, o.Username, o.PhotoUrl, nil)
+	p.PersonalAccessTokenID = o.TokenID
+	p.Scopes = o.Scopes
+	return p
+}
+
+func (p *profile) IsPersonalAccessToken() bool {
+	return p.PersonalAccessTokenID != ""
+}
+
+// HasScope is true for the profiles of a sign-in, which are not limited
+func (p *profile) HasScope(scope string) bool {
+	if !p.IsPersonalAccessToken() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 func ProfileCtx(ctx context.Context) *profile {
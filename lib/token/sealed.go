@@ -0,0 +1,86 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+var (
+	ErrInvalidSealed = errors.New("invalid sealed value")
+	ErrSealKeyNotSet = errors.New("MFA_ENCRYPTION_KEY is not set")
+)
+
+// Seal encrypts a secret which must be read back, e.g. a totp secret,
+// with AES-GCM and a key of MFA_ENCRYPTION_KEY dedicated to purpose
+func Seal(purpose, plaintext string) (string, error) {
+	if env.MFAEncryptionKey() == "" {
+		return "", ErrSealKeyNotSet
+	}
+	aead, err := sealAEAD(sealKey(purpose))
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.Wrap(err, "token Seal rand")
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(purpose))
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open also tries the key of JWT_SECRET_KEY, which sealed the values before MFA_ENCRYPTION_KEY was required,
+// it is only kept to read them until they are enrolled again
+func Open(purpose, sealed string) (string, error) {
+	if env.MFAEncryptionKey() != "" {
+		if plaintext, err := open(sealKey(purpose), purpose, sealed); err == nil {
+			return plaintext, nil
+		}
+	}
+	return open(deriveKey("seal:"+purpose), purpose, sealed)
+}
+
+func open(key []byte, purpose, sealed string) (string, error) {
+	aead, err := sealAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < aead.NonceSize() {
+		return "", ErrInvalidSealed
+	}
+
+	plaintext, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], []byte(purpose))
+	if err != nil {
+		return "", ErrInvalidSealed
+	}
+	return string(plaintext), nil
+}
+
+// sealKey returns a key of MFA_ENCRYPTION_KEY dedicated to purpose
+func sealKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(env.MFAEncryptionKey()))
+	mac.Write([]byte("seal:" + purpose))
+	return mac.Sum(nil)
+}
+
+func sealAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "token sealAEAD NewCipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "token sealAEAD NewGCM")
+	}
+	return aead, nil
+}
@@ -0,0 +1,158 @@
+package token
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSessionInvalid is returned when the session of a refresh token was revoked, expired
+	// or when the refresh token was already used, the cookies have to be reset
+	ErrSessionInvalid = errors.New("session is invalid")
+	// ErrRefreshTokenRotated is returned when a concurrent request of the same client
+	// has just rotated the refresh token, its next tokens are kept and the session goes on
+	ErrRefreshTokenRotated = errors.New("refresh token was just rotated")
+
+	sessionStore SessionStore
+)
+
+// SessionClient is the device a session is signed in from
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionUserStatusError is returned by the session store when the user of the session
+// is suspended or banned, the session is revoked. It is an ErrSessionInvalid wrapping the status error.
+type SessionUserStatusError struct {
+	Err error
+}
+
+func (e *SessionUserStatusError) Error() string {
+	return "session user status: " + e.Err.Error()
+}
+
+func (e *SessionUserStatusError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SessionUserStatusError) Is(target error) bool {
+	return target == ErrSessionInvalid
+}
+
+// SessionUser is what the tokens carry of their user, it is read again on every refresh
+// so that a removed role or a renamed user is not carried on by the refresh tokens
+type SessionUser struct {
+	Username string
+	PhotoUrl string
+	Roles    []string
+}
+
+// SessionStore keeps the sessions server side, each refresh token of a session is used once
+type SessionStore interface {
+	CreateSession(userId, refreshTokenUUID string, client SessionClient, expiresAt time.Time) (string, error)
+	// RotateSession makes refreshTokenUUID the only refresh token of the session
+	RotateSession(sessionId, refreshTokenUUID string, expiresAt time.Time) error
+	// UseRefreshToken returns ErrRefreshTokenRotated for a reuse the store tells from a replay
+	UseRefreshToken(sessionId, refreshTokenUUID string) error
+	RevokeSession(sessionId string) error
+	// SessionUser returns an error wrapping ErrSessionInvalid when the user does not exist anymore
+	// and a SessionUserStatusError when it may not use the session
+	SessionUser(sessionId, userId string) (*SessionUser, error)
+}
+
+// SetSessionStore is called once at startup, the tokens can not be issued without a store
+func SetSessionStore(s SessionStore) {
+	sessionStore = s
+}
+
+// Refresh uses the refresh token and issues the next tokens of its session,
+// the returned error wraps ErrSessionInvalid when the refresh token must not be used again
+// and ErrRefreshTokenRotated when another request got the next tokens
+func (m *manager) Refresh(refreshToken string, w http.ResponseWriter) (*profile, error) {
+	p, actk, rftk, err := m.refresh(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	m.setCookies(w, actk, rftk)
+	return p, nil
+}
+
+func (m *manager) refresh(refreshToken string) (*profile, string, string, error) {
+	claims, err := m.Decode(refreshToken)
+	if err != nil {
+		return nil, "", "", errors.Wrap(ErrSessionInvalid, err.Error())
+	}
+	if claims.TokenType == TOKEN_TYPE_ACCESS {
+		return nil, "", "", errors.Wrap(ErrSessionInvalid, "not a refresh token")
+	}
+	return m.refreshSession(claims)
+}
+
+// refreshSession uses the refresh token of claims in its session and issues the next tokens
+func (m *manager) refreshSession(claims *authTokenClaims) (*profile, string, string, error) {
+	if m.sessions == nil {
+		return nil, "", "", errors.New("Refresh: session store is not set")
+	}
+
+	// a suspended or banned user does not get the next tokens
+	su, err := m.sessions.SessionUser(claims.SessionID, claims.UserID)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	err = m.sessions.UseRefreshToken(claims.SessionID, claims.TokenUUID)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	p := NewProfile(claims.UserID, su.Username, su.PhotoUrl, su.Roles)
+	p.SessionID = claims.SessionID
+	// the session exists, its device is kept up to date by the requests
+	actk, rftk, err := m.issueTokens(p, SessionClient{})
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return p, actk, rftk, nil
+}
+
+// SignOut revokes the session of the profile and resets the cookies
+func (m *manager) SignOut(p *profile, w http.ResponseWriter) error {
+	m.ResetCookies(w)
+	if p.SessionID == "" {
+		return nil
+	}
+	if m.sessions == nil {
+		return errors.New("SignOut: session store is not set")
+	}
+	return m.sessions.RevokeSession(p.SessionID)
+}
+
+// startSession creates the session of a new sign-in or rotates the one of the profile,
+// it returns the uuid of the refresh token to issue
+func (m *manager) startSession(p *profile, client SessionClient, expiresAt time.Time) (string, error) {
+	if m.sessions == nil {
+		return "", errors.New("startSession: session store is not set")
+	}
+
+	refreshTokenUUID := uuid.NewString()
+	if p.SessionID != "" {
+		err := m.sessions.RotateSession(p.SessionID, refreshTokenUUID, expiresAt)
+		if err != nil {
+			return "", err
+		}
+		return refreshTokenUUID, nil
+	}
+
+	sessionId, err := m.sessions.CreateSession(p.UserID, refreshTokenUUID, client, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	p.SessionID = sessionId
+
+	return refreshTokenUUID, nil
+}
@@ -0,0 +1,213 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	OAUTH_STATE    = "Oauth_state"
+	SIGN_UP_TICKET = "Sign_up_ticket"
+	OIDC_NONCE     = "Oidc_nonce"
+	// the challenge of a passkey sign-in, nothing is stored before the user is known
+	WEBAUTHN_SIGN_IN = "Webauthn_sign_in"
+
+	OAUTH_COOKIE_PATH = "/api/v1/auth"
+	OAUTH_COOKIE_TTL  = time.Minute * 10
+)
+
+// OAuthState is kept in a signed cookie between the authorize and callback requests
+type OAuthState struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	RedirectPath string `json:"redirect_path"`
+}
+
+// SignUpTicket is the provider profile of a user who finished the oauth flow
+// without an account, it is consumed when the username is chosen
+type SignUpTicket struct {
+	Provider      string `json:"provider"`
+	SocialID      string `json:"social_id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	PhotoUrl      string `json:"photo_url"`
+	DisplayName   string `json:"display_name"`
+}
+
+// OIDCNonce is the nonce the server issued for an id token the client obtains itself,
+// the token is only accepted when it carries this nonce
+type OIDCNonce struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+}
+
+// WebAuthnSignIn is the challenge of a discoverable passkey sign-in
+type WebAuthnSignIn struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type signedCookieClaims struct {
+	Data json.RawMessage `json:"data"`
+	jwt.StandardClaims
+}
+
+func (m *manager) SetOAuthStateCookie(w http.ResponseWriter, s *OAuthState) error {
+	return m.setSignedCookie(w, OAUTH_STATE, s)
+}
+
+func (m *manager) OAuthStateCookie(r *http.Request) (*OAuthState, error) {
+	var s OAuthState
+	if err := m.signedCookie(r, OAUTH_STATE, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (m *manager) ResetOAuthStateCookie(w http.ResponseWriter) {
+	m.resetSignedCookie(w, OAUTH_STATE)
+}
+
+func (m *manager) SetSignUpTicketCookie(w http.ResponseWriter, t *SignUpTicket) error {
+	return m.setSignedCookie(w, SIGN_UP_TICKET, t)
+}
+
+func (m *manager) SignUpTicketCookie(r *http.Request) (*SignUpTicket, error) {
+	var t SignUpTicket
+	if err := m.signedCookie(r, SIGN_UP_TICKET, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (m *manager) ResetSignUpTicketCookie(w http.ResponseWriter) {
+	m.resetSignedCookie(w, SIGN_UP_TICKET)
+}
+
+func (m *manager) SetOIDCNonceCookie(w http.ResponseWriter, n *OIDCNonce) error {
+	return m.setSignedCookie(w, OIDC_NONCE, n)
+}
+
+func (m *manager) OIDCNonceCookie(r *http.Request) (*OIDCNonce, error) {
+	var n OIDCNonce
+	if err := m.signedCookie(r, OIDC_NONCE, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (m *manager) ResetOIDCNonceCookie(w http.ResponseWriter) {
+	m.resetSignedCookie(w, OIDC_NONCE)
+}
+
+func (m *manager) SetWebAuthnSignInCookie(w http.ResponseWriter, s *WebAuthnSignIn) error {
+	return m.setSignedCookie(w, WEBAUTHN_SIGN_IN, s)
+}
+
+func (m *manager) WebAuthnSignInCookie(r *http.Request) (*WebAuthnSignIn, error) {
+	var s WebAuthnSignIn
+	if err := m.signedCookie(r, WEBAUTHN_SIGN_IN, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (m *manager) ResetWebAuthnSignInCookie(w http.ResponseWriter) {
+	m.resetSignedCookie(w, WEBAUTHN_SIGN_IN)
+}
+
+func (m *manager) setSignedCookie(w http.ResponseWriter, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "setSignedCookie Marshal")
+	}
+
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, &signedCookieClaims{
+		Data: data,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   name,
+			ExpiresAt: now.Add(OAUTH_COOKIE_TTL).Unix(),
+			Issuer:    m.opts.Issuer,
+			IssuedAt:  now.Unix(),
+		},
+	})
+	ss, err := t.SignedString(signedCookieKey(name))
+	if err != nil {
+		return errors.Wrap(err, "setSignedCookie SignedString")
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    ss,
+		Path:     OAUTH_COOKIE_PATH,
+		Expires:  now.Add(OAUTH_COOKIE_TTL),
+		Domain:   m.opts.CookieDomain,
+		Secure:   m.opts.CookieSecure,
+		HttpOnly: true,
+		// the provider redirects back with a top level navigation
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (m *manager) signedCookie(r *http.Request, name string, v any) error {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return errors.Wrapf(err, "signedCookie get %s", name)
+	}
+
+	claims := signedCookieClaims{}
+	_, err = jwt.ParseWithClaims(c.Value, &claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return signedCookieKey(name), nil
+		}
+		return nil, errors.New("signedCookie: ParseWithClaims")
+	})
+	if err != nil {
+		return errors.Wrapf(err, "signedCookie decode %s", name)
+	}
+	if claims.Subject != name {
+		return errors.Errorf("signedCookie %s subject mismatch", name)
+	}
+
+	if err := json.Unmarshal(claims.Data, v); err != nil {
+		return errors.Wrapf(err, "signedCookie Unmarshal %s", name)
+	}
+	return nil
+}
+
+func (m *manager) resetSignedCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     OAUTH_COOKIE_PATH,
+		Expires:  time.Now().AddDate(0, 0, -1),
+		Domain:   m.opts.CookieDomain,
+		Secure:   m.opts.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// signedCookieKey derives a key per cookie name from JWT_SECRET_KEY,
+// so that these cookies can never be decoded as access or refresh tokens
+func signedCookieKey(name string) []byte {
+	return deriveKey("signed-cookie:" + name)
+}
+
+// deriveKey returns a key of JWT_SECRET_KEY dedicated to purpose
+func deriveKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(env.JWTSecretKey()))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
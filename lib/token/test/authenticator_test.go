@@ -0,0 +1,171 @@
+package token_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// issueCookies signs in a new session with the options and returns its access and refresh tokens
+func issueCookies(t *testing.T, o token.Options) (string, string) {
+	w := httptest.NewRecorder()
+	p := token.NewProfile("user-id", "madre", "", nil)
+	assert.Nil(t, token.NewManagerWithOptions(o).GenerateAndSetCookies(p, token.SessionClient{}, w))
+
+	var actk string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == token.ACCESS_TOKEN {
+			actk = c.Value
+		}
+	}
+	return actk, refreshCookie(w)
+}
+
+// forgeCookies are the tokens of a session signed with another secret
+func forgeCookies(t *testing.T, o token.Options) (string, string) {
+	t.Setenv("JWT_SECRET_KEY", "forger-secret")
+	defer t.Setenv("JWT_SECRET_KEY", "test-secret")
+	return issueCookies(t, o)
+}
+
+func Test_Authenticate(t *testing.T) {
+	signIn(t)
+
+	valid := token.DefaultOptions()
+	accessExpired := token.DefaultOptions()
+	accessExpired.AccessTokenTTL = -time.Minute
+	allExpired := token.DefaultOptions()
+	allExpired.AccessTokenTTL = -time.Minute * 2
+	allExpired.RefreshTokenTTL = -time.Minute
+	otherIssuer := token.DefaultOptions()
+	otherIssuer.Issuer = "madre-staging"
+
+	type cookies func(t *testing.T) (string, string)
+	with := func(o token.Options) cookies {
+		return func(t *testing.T) (string, string) { return issueCookies(t, o) }
+	}
+	used := func(t *testing.T) (string, string) {
+		actk, rftk := issueCookies(t, valid)
+		_, err := token.NewManager().Refresh(rftk, httptest.NewRecorder())
+		assert.Nil(t, err)
+		return actk, rftk
+	}
+
+	cases := []struct {
+		name    string
+		cookies cookies
+		// pick keeps the access and the refresh cookie of the issued tokens, or replaces them
+		pick    func(actk, rftk string) (string, string)
+		outcome token.AuthOutcome
+	}{
+		{"no cookies", with(valid), func(a, r string) (string, string) { return "", "" }, token.AUTH_OUTCOME_ANONYMOUS},
+		{"valid access", with(valid), func(a, r string) (string, string) { return a, "" }, token.AUTH_OUTCOME_AUTHENTICATED},
+		{"valid access and refresh", with(valid), func(a, r string) (string, string) { return a, r }, token.AUTH_OUTCOME_AUTHENTICATED},
+		{"expired access, valid refresh", with(accessExpired), func(a, r string) (string, string) { return a, r }, token.AUTH_OUTCOME_REFRESHED},
+		{"missing access, valid refresh", with(valid), func(a, r string) (string, string) { return "", r }, token.AUTH_OUTCOME_REFRESHED},
+		{"expired access, missing refresh", with(accessExpired), func(a, r string) (string, string) { return a, "" }, token.AUTH_OUTCOME_EXPIRED},
+		{"expired access, expired refresh", with(allExpired), func(a, r string) (string, string) { return a, r }, token.AUTH_OUTCOME_EXPIRED},
+		{"missing access, expired refresh", with(allExpired), func(a, r string) (string, string) { return "", r }, token.AUTH_OUTCOME_EXPIRED},
+		{"malformed access, valid refresh", with(valid), func(a, r string) (string, string) { return "not-a-token", r }, token.AUTH_OUTCOME_MALFORMED},
+		{"expired access, malformed refresh", with(accessExpired), func(a, r string) (string, string) { return a, "not-a-token" }, token.AUTH_OUTCOME_MALFORMED},
+		{"forged access", func(t *testing.T) (string, string) { return forgeCookies(t, valid) }, func(a, r string) (string, string) { return a, "" }, token.AUTH_OUTCOME_INVALID_SIGNATURE},
+		{"forged expired access", func(t *testing.T) (string, string) { return forgeCookies(t, accessExpired) }, func(a, r string) (string, string) { return a, "" }, token.AUTH_OUTCOME_INVALID_SIGNATURE},
+		{"missing access, forged refresh", func(t *testing.T) (string, string) { return forgeCookies(t, valid) }, func(a, r string) (string, string) { return "", r }, token.AUTH_OUTCOME_INVALID_SIGNATURE},
+		{"refresh token as access", with(valid), func(a, r string) (string, string) { return r, "" }, token.AUTH_OUTCOME_INVALID_CLAIMS},
+		{"access token as refresh", with(valid), func(a, r string) (string, string) { return "", a }, token.AUTH_OUTCOME_INVALID_CLAIMS},
+		{"access of another issuer", with(otherIssuer), func(a, r string) (string, string) { return a, "" }, token.AUTH_OUTCOME_INVALID_CLAIMS},
+		{"missing access, used refresh", used, func(a, r string) (string, string) { return "", r }, token.AUTH_OUTCOME_SESSION_INVALID},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert := assert.New(t)
+			actk, rftk := c.pick(c.cookies(t))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if actk != "" {
+				r.AddCookie(&http.Cookie{Name: token.ACCESS_TOKEN, Value: actk})
+			}
+			if rftk != "" {
+				r.AddCookie(&http.Cookie{Name: token.REFRESH_TOKEN, Value: rftk})
+			}
+			w := httptest.NewRecorder()
+			a := token.NewManager().Authenticate(r, w)
+
+			assert.Equal(c.outcome, a.Outcome)
+			signedIn := c.outcome == token.AUTH_OUTCOME_AUTHENTICATED || c.outcome == token.AUTH_OUTCOME_REFRESHED
+			assert.Equal(signedIn, a.Profile != nil)
+
+			set := map[string]string{}
+			for _, ck := range w.Result().Cookies() {
+				set[ck.Name] = ck.Value
+			}
+			switch {
+			case c.outcome == token.AUTH_OUTCOME_REFRESHED:
+				assert.NotEmpty(set[token.ACCESS_TOKEN])
+				assert.NotEqual(rftk, set[token.REFRESH_TOKEN])
+			case a.CookiesReset():
+				assert.Contains(set, token.ACCESS_TOKEN)
+				assert.Empty(set[token.ACCESS_TOKEN])
+				assert.Empty(set[token.REFRESH_TOKEN])
+			default:
+				assert.Empty(set)
+			}
+		})
+	}
+}
+
+func Test_Authenticate_ConcurrentRefreshes(t *testing.T) {
+	assert := assert.New(t)
+	store, _ := signIn(t)
+	store.grace = time.Minute
+
+	accessExpired := token.DefaultOptions()
+	accessExpired.AccessTokenTTL = -time.Minute
+	actk, rftk := issueCookies(t, accessExpired)
+
+	// two tabs of the same browser send the expired access token at once
+	var wg sync.WaitGroup
+	outcomes := make([]*token.Authentication, 2)
+	recorders := make([]*httptest.ResponseRecorder, 2)
+	for i := range outcomes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: token.ACCESS_TOKEN, Value: actk})
+			r.AddCookie(&http.Cookie{Name: token.REFRESH_TOKEN, Value: rftk})
+			recorders[i] = httptest.NewRecorder()
+			outcomes[i] = token.NewManager().Authenticate(r, recorders[i])
+		}(i)
+	}
+	wg.Wait()
+
+	refreshed, rotated := 0, 0
+	for i, a := range outcomes {
+		assert.False(a.CookiesReset())
+		switch a.Outcome {
+		case token.AUTH_OUTCOME_REFRESHED:
+			refreshed++
+			assert.NotEmpty(refreshCookie(recorders[i]))
+		case token.AUTH_OUTCOME_ROTATED:
+			rotated++
+			assert.Nil(a.Profile)
+			// the cookies set by the other request are not overwritten
+			assert.Empty(recorders[i].Result().Cookies())
+		}
+	}
+	assert.Equal(1, refreshed)
+	assert.Equal(1, rotated)
+	assert.False(store.revoked["session-2"])
+
+	// the replay after the grace still revokes the session
+	store.grace = 0
+	_, err := token.NewManager().Refresh(rftk, httptest.NewRecorder())
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+	assert.True(store.revoked["session-2"])
+}
@@ -0,0 +1,60 @@
+package token_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BearerToken(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := map[string]string{
+		"":                   "",
+		"Bearer abc":         "abc",
+		"bearer  abc ":       "abc",
+		"Basic dXNlcjpwdw==": "",
+		"Bearer":             "",
+	}
+	for header, expected := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", header)
+		assert.Equal(expected, token.BearerToken(r), header)
+	}
+}
+
+func Test_GenerateTokens(t *testing.T) {
+	assert := assert.New(t)
+	store, _ := signIn(t)
+
+	w := httptest.NewRecorder()
+	p := token.NewProfile("user-id", "madre", "", nil)
+	tokens, err := token.NewManager().Issue(p, token.SessionClient{UserAgent: "madre-cli"}, w, true)
+	assert.Nil(err)
+	assert.Empty(w.Result().Cookies(), "the tokens are not set in the cookies")
+	assert.Equal("Bearer", tokens.TokenType)
+	assert.Equal(int64(token.DEFAULT_ACCESS_TOKEN_TTL.Seconds()), tokens.ExpiresIn)
+	assert.Equal("madre-cli", store.clients[p.SessionID].UserAgent)
+
+	bp, err := token.NewManager().ProfileFromAccessToken(tokens.AccessToken)
+	assert.Nil(err)
+	assert.True(bp.Bearer)
+	assert.Equal(p.SessionID, bp.SessionID)
+
+	// a refresh token does not authenticate a request, an access token does not refresh
+	_, err = token.NewManager().ProfileFromAccessToken(tokens.RefreshToken)
+	assert.NotNil(err)
+	_, _, err = token.NewManager().RefreshTokens(tokens.AccessToken)
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+
+	rp, next, err := token.NewManager().RefreshTokens(tokens.RefreshToken)
+	assert.Nil(err)
+	assert.Equal(p.SessionID, rp.SessionID)
+	assert.NotEqual(tokens.RefreshToken, next.RefreshToken)
+
+	_, _, err = token.NewManager().RefreshTokens(tokens.RefreshToken)
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+}
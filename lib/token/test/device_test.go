@@ -0,0 +1,42 @@
+package token_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DeviceID(t *testing.T) {
+	assert := assert.New(t)
+
+	// a new device gets a cookie
+	w := httptest.NewRecorder()
+	id, err := token.NewManager().DeviceID(httptest.NewRequest(http.MethodPost, "/", nil), w)
+	assert.Nil(err)
+	cookies := w.Result().Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal(token.DEVICE_ID, cookies[0].Name)
+	assert.Equal(id, cookies[0].Value)
+
+	// the cookie is kept
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	kept, err := token.NewManager().DeviceID(r, w)
+	assert.Nil(err)
+	assert.Equal(id, kept)
+	assert.Empty(w.Result().Cookies())
+
+	// the clients without cookies send it in the header
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(token.DEVICE_ID_HEADER, "cli-device")
+	header, err := token.NewManager().DeviceID(r, httptest.NewRecorder())
+	assert.Nil(err)
+	assert.Equal("cli-device", header)
+
+	assert.Equal(token.DeviceFingerprint(id), token.DeviceFingerprint(kept))
+	assert.NotEqual(id, token.DeviceFingerprint(id))
+}
@@ -0,0 +1,144 @@
+package token_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func rsaKeyPEM(t *testing.T, bits int) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func ed25519KeyPEM(t *testing.T) ([]byte, []byte) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Nil(t, err)
+	pubDer, err := x509.MarshalPKIXPublicKey(pub)
+	assert.Nil(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer})
+}
+
+// issue signs the tokens of a new session with the key set and returns the access token
+func issue(t *testing.T, ks *token.KeySet) string {
+	token.SetKeySet(ks)
+	t.Cleanup(func() { token.SetKeySet(nil) })
+
+	w := httptest.NewRecorder()
+	p := token.NewProfile("user-id", "madre", "", nil)
+	assert.Nil(t, token.NewManager().GenerateAndSetCookies(p, token.SessionClient{}, w))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == token.ACCESS_TOKEN {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func header(t *testing.T, tk string) map[string]any {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(tk, jwt.MapClaims{})
+	assert.Nil(t, err)
+	return parsed.Header
+}
+
+func Test_KeySet_SignAndVerify(t *testing.T) {
+	assert := assert.New(t)
+	signIn(t)
+
+	edKey, _ := ed25519KeyPEM(t)
+	for alg, key := range map[string][]byte{"RS256": rsaKeyPEM(t, 2048), "EdDSA": edKey} {
+		ks, err := token.NewKeySet(key)
+		assert.Nil(err)
+
+		actk := issue(t, ks)
+		h := header(t, actk)
+		assert.Equal(alg, h["alg"])
+		assert.Equal(ks.ActiveKeyID(), h["kid"])
+
+		claims, err := token.NewManager().Decode(actk)
+		assert.Nil(err, alg)
+		assert.Equal("user-id", claims.UserID)
+
+		jwks := ks.JWKS()
+		assert.Len(jwks.Keys, 1)
+		assert.Equal(ks.ActiveKeyID(), jwks.Keys[0].Kid)
+		assert.Equal(alg, jwks.Keys[0].Alg)
+	}
+}
+
+func Test_KeySet_Rotation(t *testing.T) {
+	assert := assert.New(t)
+	signIn(t)
+
+	oldKey := rsaKeyPEM(t, 2048)
+	newKey, newPub := ed25519KeyPEM(t)
+
+	// the next key is published before it signs
+	before, err := token.NewKeySet(oldKey, newPub)
+	assert.Nil(err)
+	assert.Len(before.JWKS().Keys, 2)
+	actk := issue(t, before)
+
+	// swapped, the tokens of the previous key stay valid
+	after, err := token.NewKeySet(newKey, oldKey)
+	assert.Nil(err)
+	token.SetKeySet(after)
+	_, err = token.NewManager().Decode(actk)
+	assert.Nil(err)
+
+	// dropped, they are refused
+	dropped, err := token.NewKeySet(newKey)
+	assert.Nil(err)
+	token.SetKeySet(dropped)
+	_, err = token.NewManager().Decode(actk)
+	assert.NotNil(err)
+}
+
+func Test_KeySet_HS256(t *testing.T) {
+	assert := assert.New(t)
+	signIn(t)
+
+	hs256 := issue(t, nil)
+	assert.Equal("HS256", header(t, hs256)["alg"])
+
+	ks, err := token.NewKeySet(rsaKeyPEM(t, 2048))
+	assert.Nil(err)
+	token.SetKeySet(ks)
+
+	_, err = token.NewManager().Decode(hs256)
+	assert.Nil(err)
+
+	t.Setenv("JWT_ACCEPT_HS256", "false")
+	_, err = token.NewManager().Decode(hs256)
+	assert.NotNil(err)
+}
+
+func Test_NewKeySet_Invalid(t *testing.T) {
+	assert := assert.New(t)
+
+	_, pub := ed25519KeyPEM(t)
+	_, err := token.NewKeySet(pub)
+	assert.NotNil(err, "the active key must be private")
+
+	_, err = token.NewKeySet(rsaKeyPEM(t, 1024))
+	assert.NotNil(err)
+
+	_, err = token.NewKeySet([]byte("not a key"))
+	assert.NotNil(err)
+
+	_, err = token.NewKeySet([]byte(strings.Replace(string(pub), "PUBLIC KEY", "CERTIFICATE", -1)))
+	assert.NotNil(err)
+}
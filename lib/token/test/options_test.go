@@ -0,0 +1,119 @@
+package token_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Options_Validate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(token.DefaultOptions().Validate())
+
+	cases := map[string]func(o *token.Options){
+		"zero ttl":           func(o *token.Options) { o.AccessTokenTTL = 0 },
+		"access outlives":    func(o *token.Options) { o.AccessTokenTTL = o.RefreshTokenTTL + time.Hour },
+		"no issuer":          func(o *token.Options) { o.Issuer = "" },
+		"relative path":      func(o *token.Options) { o.CookiePath = "api" },
+		"domain with scheme": func(o *token.Options) { o.CookieDomain = "https://juntae.kim" },
+		"insecure same site none": func(o *token.Options) {
+			o.CookieSameSite = http.SameSiteNoneMode
+			o.CookieSecure = false
+		},
+	}
+	for name, change := range cases {
+		o := token.DefaultOptions()
+		change(&o)
+		assert.ErrorIs(o.Validate(), token.ErrInvalidOptions, name)
+	}
+}
+
+func Test_LoadOptions(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("JWT_ACCESS_TOKEN_TTL", "15m")
+	t.Setenv("JWT_AUDIENCE", "madre-web")
+	t.Setenv("AUTH_COOKIE_DOMAIN", ".juntae.kim")
+	t.Setenv("AUTH_COOKIE_SAME_SITE", "Strict")
+	t.Setenv("AUTH_COOKIE_SECURE", "false")
+	t.Setenv("MFA_ENCRYPTION_KEY", "test-mfa-key")
+
+	o, err := token.LoadOptions()
+	assert.Nil(err)
+	assert.Equal(time.Minute*15, o.AccessTokenTTL)
+	assert.Equal(token.DEFAULT_REFRESH_TOKEN_TTL, o.RefreshTokenTTL)
+	assert.Equal("madre-web", o.Audience)
+	assert.Equal(http.SameSiteStrictMode, o.CookieSameSite)
+	assert.False(o.CookieSecure)
+
+	t.Setenv("AUTH_COOKIE_SAME_SITE", "sometimes")
+	_, err = token.LoadOptions()
+	assert.ErrorIs(err, token.ErrInvalidOptions)
+
+	// the totp secrets can not be sealed without it
+	t.Setenv("AUTH_COOKIE_SAME_SITE", "Strict")
+	t.Setenv("MFA_ENCRYPTION_KEY", "")
+	_, err = token.LoadOptions()
+	assert.ErrorIs(err, token.ErrInvalidOptions)
+}
+
+func Test_LoadOptions_MalformedValues(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("MFA_ENCRYPTION_KEY", "test-mfa-key")
+
+	for key, value := range map[string]string{
+		"JWT_ACCESS_TOKEN_TTL":  "15 minutes",
+		"JWT_REFRESH_TOKEN_TTL": "30d",
+		"AUTH_COOKIE_SECURE":    "flase",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			_, err := token.LoadOptions()
+			assert.ErrorIs(err, token.ErrInvalidOptions)
+		})
+	}
+}
+
+func Test_Manager_Options(t *testing.T) {
+	assert := assert.New(t)
+	signIn(t)
+
+	o := token.DefaultOptions()
+	o.AccessTokenTTL = time.Minute * 15
+	o.Audience = "madre-web"
+	o.CookieDomain = ".juntae.kim"
+	o.CookieSecure = false
+	m := token.NewManagerWithOptions(o)
+
+	w := httptest.NewRecorder()
+	p := token.NewProfile("user-id", "madre", "", nil)
+	assert.Nil(m.GenerateAndSetCookies(p, token.SessionClient{}, w))
+
+	var actk string
+	for _, c := range w.Result().Cookies() {
+		assert.Equal("juntae.kim", c.Domain, c.Name)
+		assert.False(c.Secure, c.Name)
+		if c.Name == token.ACCESS_TOKEN {
+			actk = c.Value
+			assert.WithinDuration(time.Now().Add(o.AccessTokenTTL), c.Expires, time.Minute)
+		}
+	}
+
+	_, err := m.Decode(actk)
+	assert.Nil(err)
+
+	// a token of another audience or issuer is refused
+	other := o
+	other.Audience = "madre-admin"
+	_, err = token.NewManagerWithOptions(other).Decode(actk)
+	assert.NotNil(err)
+
+	other = o
+	other.Issuer = "madre-staging"
+	_, err = token.NewManagerWithOptions(other).Decode(actk)
+	assert.NotNil(err)
+}
@@ -0,0 +1,37 @@
+package token_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewPersonalAccessToken(t *testing.T) {
+	assert := assert.New(t)
+
+	raw, hash, prefix, err := token.NewPersonalAccessToken()
+	assert.Nil(err)
+	assert.True(token.IsPersonalAccessToken(raw))
+	assert.True(strings.HasPrefix(raw, prefix))
+	assert.Len(prefix, token.PERSONAL_ACCESS_TOKEN_ID_LENGTH)
+	assert.Equal(token.HashOpaqueToken(raw), hash)
+	assert.False(token.IsPersonalAccessToken("eyJhbGciOiJIUzI1NiJ9.e30.sig"))
+}
+
+func Test_PersonalAccessTokenProfile_HasScope(t *testing.T) {
+	assert := assert.New(t)
+
+	p := token.NewPersonalAccessTokenProfile(&token.PersonalAccessTokenOwner{
+		TokenID: "token-id",
+		UserID:  "user-id",
+		Scopes:  []string{"data:read"},
+	})
+	assert.True(p.IsPersonalAccessToken())
+	assert.True(p.HasScope("data:read"))
+	assert.False(p.HasScope("data:write"))
+
+	// a sign-in is not limited by scopes
+	assert.True(token.NewProfile("user-id", "madre", "", nil).HasScope("data:write"))
+}
@@ -0,0 +1,39 @@
+package token_test
+
+import (
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Seal_MFAEncryptionKey(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	t.Setenv("MFA_ENCRYPTION_KEY", "")
+
+	_, err := token.Seal("totp-secret", "secret")
+	assert.ErrorIs(err, token.ErrSealKeyNotSet)
+
+	t.Setenv("MFA_ENCRYPTION_KEY", "test-mfa-key")
+	sealed, err := token.Seal("totp-secret", "secret")
+	assert.Nil(err)
+
+	// sealed with the key of JWT_SECRET_KEY before MFA_ENCRYPTION_KEY was required
+	plaintext, err := token.Open("totp-secret", "yby+vl9mVLRxDgYv7dEHqLegXBBY9wq8/+XUAqi4wU494Q")
+	assert.Nil(err)
+	assert.Equal("legacy", plaintext)
+
+	// the token keys can be rotated
+	t.Setenv("JWT_SECRET_KEY", "rotated-secret")
+	plaintext, err = token.Open("totp-secret", sealed)
+	assert.Nil(err)
+	assert.Equal("secret", plaintext)
+
+	_, err = token.Open("other-purpose", sealed)
+	assert.ErrorIs(err, token.ErrInvalidSealed)
+
+	t.Setenv("MFA_ENCRYPTION_KEY", "other-mfa-key")
+	_, err = token.Open("totp-secret", sealed)
+	assert.ErrorIs(err, token.ErrInvalidSealed)
+}
@@ -0,0 +1,220 @@
+package token_test
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+// memorySessionStore follows the rules of the database store,
+// a reuse is in the grace when it happens within grace of the first use
+type memorySessionStore struct {
+	sync.Mutex
+	sessions map[string]string              // session id -> user id
+	clients  map[string]token.SessionClient // session id
+	revoked  map[string]bool                // session id
+	tokens   map[string]map[string]bool     // session id -> refresh token uuid -> used
+	users    map[string]*token.SessionUser  // user id
+	usedAt   map[string]time.Time           // refresh token uuid
+	statuses map[string]error               // user id -> why the user may not use its sessions
+	grace    time.Duration
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{
+		sessions: map[string]string{},
+		clients:  map[string]token.SessionClient{},
+		revoked:  map[string]bool{},
+		tokens:   map[string]map[string]bool{},
+		users:    map[string]*token.SessionUser{},
+		usedAt:   map[string]time.Time{},
+		statuses: map[string]error{},
+	}
+}
+
+func (s *memorySessionStore) CreateSession(userId, refreshTokenUUID string, client token.SessionClient, expiresAt time.Time) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	id := "session-" + strconv.Itoa(len(s.sessions)+1)
+	s.sessions[id] = userId
+	s.clients[id] = client
+	s.tokens[id] = map[string]bool{refreshTokenUUID: false}
+	return id, nil
+}
+
+func (s *memorySessionStore) RotateSession(sessionId, refreshTokenUUID string, expiresAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.sessions[sessionId]; !ok || s.revoked[sessionId] {
+		return token.ErrSessionInvalid
+	}
+	for t := range s.tokens[sessionId] {
+		s.tokens[sessionId][t] = true
+	}
+	s.tokens[sessionId][refreshTokenUUID] = false
+	return nil
+}
+
+func (s *memorySessionStore) UseRefreshToken(sessionId, refreshTokenUUID string) error {
+	s.Lock()
+	defer s.Unlock()
+	used, ok := s.tokens[sessionId][refreshTokenUUID]
+	if !ok || s.revoked[sessionId] {
+		return token.ErrSessionInvalid
+	}
+	if used {
+		if time.Since(s.usedAt[refreshTokenUUID]) < s.grace {
+			return token.ErrRefreshTokenRotated
+		}
+		s.revoked[sessionId] = true
+		return errors.Wrap(token.ErrSessionInvalid, "reused")
+	}
+	s.tokens[sessionId][refreshTokenUUID] = true
+	s.usedAt[refreshTokenUUID] = time.Now()
+	return nil
+}
+
+func (s *memorySessionStore) RevokeSession(sessionId string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.revoked[sessionId] = true
+	return nil
+}
+
+func (s *memorySessionStore) SessionUser(sessionId, userId string) (*token.SessionUser, error) {
+	s.Lock()
+	defer s.Unlock()
+	if err, ok := s.statuses[userId]; ok {
+		s.revoked[sessionId] = true
+		return nil, &token.SessionUserStatusError{Err: err}
+	}
+	su, ok := s.users[userId]
+	if !ok {
+		return nil, token.ErrSessionInvalid
+	}
+	return su, nil
+}
+
+func refreshCookie(w *httptest.ResponseRecorder) string {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == token.REFRESH_TOKEN {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func signIn(t *testing.T) (*memorySessionStore, string) {
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	store := newMemorySessionStore()
+	store.users["user-id"] = &token.SessionUser{Username: "madre", Roles: []string{"admin"}}
+	token.SetSessionStore(store)
+
+	w := httptest.NewRecorder()
+	p := token.NewProfile("user-id", "madre", "", []string{"admin"})
+	client := token.SessionClient{UserAgent: "Mozilla/5.0", IP: "203.0.113.7"}
+	assert.Nil(t, token.NewManager().GenerateAndSetCookies(p, client, w))
+	assert.Equal(t, "session-1", p.SessionID)
+	assert.Equal(t, client, store.clients["session-1"])
+
+	return store, refreshCookie(w)
+}
+
+func Test_Refresh_RotatesInTheSession(t *testing.T) {
+	assert := assert.New(t)
+	store, rftk := signIn(t)
+
+	w := httptest.NewRecorder()
+	p, err := token.NewManager().Refresh(rftk, w)
+	assert.Nil(err)
+	assert.Equal("session-1", p.SessionID)
+	assert.Equal([]string{"admin"}, p.Roles)
+	assert.Len(store.sessions, 1)
+
+	next := refreshCookie(w)
+	assert.NotEqual(rftk, next)
+	_, err = token.NewManager().Refresh(next, httptest.NewRecorder())
+	assert.Nil(err)
+}
+
+func Test_Refresh_ReloadsTheUser(t *testing.T) {
+	assert := assert.New(t)
+	store, rftk := signIn(t)
+
+	// the role was removed and the user renamed since the sign-in
+	store.users["user-id"] = &token.SessionUser{Username: "renamed"}
+
+	w := httptest.NewRecorder()
+	p, err := token.NewManager().Refresh(rftk, w)
+	assert.Nil(err)
+	assert.Equal("renamed", p.Username)
+	assert.Empty(p.Roles)
+	assert.False(p.HasRole("admin"))
+
+	// the user was deleted
+	delete(store.users, "user-id")
+	_, err = token.NewManager().Refresh(refreshCookie(w), httptest.NewRecorder())
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+}
+
+func Test_Refresh_UserStatusRevokesTheSession(t *testing.T) {
+	assert := assert.New(t)
+	store, rftk := signIn(t)
+
+	banned := errors.New("user is banned")
+	store.statuses["user-id"] = banned
+
+	_, err := token.NewManager().Refresh(rftk, httptest.NewRecorder())
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+	assert.ErrorIs(err, banned)
+	assert.True(store.revoked["session-1"])
+	// the session is revoked before its refresh token is used
+	for _, used := range store.tokens["session-1"] {
+		assert.False(used)
+	}
+}
+
+func Test_Refresh_ReuseRevokesTheSession(t *testing.T) {
+	assert := assert.New(t)
+	store, rftk := signIn(t)
+
+	w := httptest.NewRecorder()
+	_, err := token.NewManager().Refresh(rftk, w)
+	assert.Nil(err)
+	next := refreshCookie(w)
+
+	_, err = token.NewManager().Refresh(rftk, httptest.NewRecorder())
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+	assert.True(store.revoked["session-1"])
+
+	// the rotated token of the legitimate client is revoked with its session
+	_, err = token.NewManager().Refresh(next, httptest.NewRecorder())
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+}
+
+func Test_SignOut_RevokesTheSession(t *testing.T) {
+	assert := assert.New(t)
+	store, rftk := signIn(t)
+
+	p, err := token.NewManager().Refresh(rftk, httptest.NewRecorder())
+	assert.Nil(err)
+
+	w := httptest.NewRecorder()
+	assert.Nil(token.NewManager().SignOut(p, w))
+	assert.True(store.revoked["session-1"])
+	assert.Equal("", refreshCookie(w))
+}
+
+func Test_Refresh_InvalidToken(t *testing.T) {
+	assert := assert.New(t)
+	signIn(t)
+
+	_, err := token.NewManager().Refresh("not a token", httptest.NewRecorder())
+	assert.ErrorIs(err, token.ErrSessionInvalid)
+}
@@ -0,0 +1,52 @@
+package token_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OIDCNonceCookie(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+
+	w := httptest.NewRecorder()
+	err := token.NewManager().SetOIDCNonceCookie(w, &token.OIDCNonce{
+		Provider: "Apple",
+		Nonce:    "server-nonce",
+	})
+	assert.Nil(err)
+	cookies := w.Result().Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal(token.OIDC_NONCE, cookies[0].Name)
+	assert.True(cookies[0].HttpOnly)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(cookies[0])
+	n, err := token.NewManager().OIDCNonceCookie(r)
+	assert.Nil(err)
+	assert.Equal("Apple", n.Provider)
+	assert.Equal("server-nonce", n.Nonce)
+
+	// a nonce chosen by the client is not accepted
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(&http.Cookie{Name: token.OIDC_NONCE, Value: "client-nonce"})
+	_, err = token.NewManager().OIDCNonceCookie(r)
+	assert.NotNil(err)
+
+	// the other signed cookies can not be used as a nonce
+	w = httptest.NewRecorder()
+	err = token.NewManager().SetSignUpTicketCookie(w, &token.SignUpTicket{Provider: "Apple"})
+	assert.Nil(err)
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	ticket := w.Result().Cookies()[0]
+	r.AddCookie(&http.Cookie{Name: token.OIDC_NONCE, Value: ticket.Value})
+	_, err = token.NewManager().OIDCNonceCookie(r)
+	assert.NotNil(err)
+
+	_, err = token.NewManager().OIDCNonceCookie(httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.NotNil(err)
+}
@@ -0,0 +1,40 @@
+package totp
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	RECOVERY_CODES = 10
+	// 10 base32 characters are 50 bits
+	RECOVERY_CODE_LENGTH = 10
+)
+
+var (
+	recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateRecoveryCodes returns n single use codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.Wrap(err, "totp GenerateRecoveryCodes")
+		}
+		c := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:RECOVERY_CODE_LENGTH]
+		codes = append(codes, c[:RECOVERY_CODE_LENGTH/2]+"-"+c[RECOVERY_CODE_LENGTH/2:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode ignores the case, spaces and dashes people type
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
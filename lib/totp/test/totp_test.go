@@ -0,0 +1,120 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/totp"
+	"github.com/stretchr/testify/assert"
+)
+
+// the sha1 secret of https://datatracker.ietf.org/doc/html/rfc6238#appendix-B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func Test_Code_RFC6238(t *testing.T) {
+	assert := assert.New(t)
+
+	// the last 6 digits of the 8 digits codes of the rfc
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, code := range cases {
+		c, err := totp.Code(rfcSecret, time.Unix(unix, 0))
+		assert.Nil(err)
+		assert.Equal(code, c, unix)
+	}
+}
+
+func Test_Validate_AcceptsSkew(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1111111111, 0)
+	previous, _ := totp.Code(rfcSecret, now.Add(-totp.PERIOD*time.Second))
+
+	step, ok, err := totp.Validate(previous, rfcSecret, now, 0)
+
+	assert.Nil(err)
+	assert.True(ok)
+	assert.Equal(totp.Step(now)-1, step)
+}
+
+func Test_Validate_RefusesOutOfSkew(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1111111111, 0)
+	old, _ := totp.Code(rfcSecret, now.Add(-2*totp.PERIOD*time.Second))
+
+	_, ok, err := totp.Validate(old, rfcSecret, now, 0)
+
+	assert.Nil(err)
+	assert.False(ok)
+}
+
+func Test_Validate_RefusesReplay(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1111111111, 0)
+	code, _ := totp.Code(rfcSecret, now)
+
+	step, ok, _ := totp.Validate(code, rfcSecret, now, 0)
+	assert.True(ok)
+
+	_, ok, _ = totp.Validate(code, rfcSecret, now, step)
+	assert.False(ok)
+}
+
+func Test_Validate_InvalidSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	_, ok, err := totp.Validate("123456", "not base32!", time.Now(), 0)
+
+	assert.False(ok)
+	assert.Equal(totp.ErrInvalidSecret, err)
+}
+
+func Test_GenerateSecret(t *testing.T) {
+	assert := assert.New(t)
+
+	secret, err := totp.GenerateSecret()
+	assert.Nil(err)
+	assert.Len(secret, 32)
+
+	_, err = totp.Code(secret, time.Now())
+	assert.Nil(err)
+}
+
+func Test_URI(t *testing.T) {
+	assert := assert.New(t)
+
+	uri := totp.URI("madre", "madre user", "SECRET")
+
+	assert.True(strings.HasPrefix(uri, "otpauth://totp/madre:madre%20user?"))
+	assert.Contains(uri, "secret=SECRET")
+	assert.Contains(uri, "issuer=madre")
+}
+
+func Test_GenerateRecoveryCodes(t *testing.T) {
+	assert := assert.New(t)
+
+	codes, err := totp.GenerateRecoveryCodes(totp.RECOVERY_CODES)
+	assert.Nil(err)
+	assert.Len(codes, totp.RECOVERY_CODES)
+
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.Len(c, totp.RECOVERY_CODE_LENGTH+1)
+		assert.Equal(strings.ToLower(c), c)
+		seen[c] = true
+	}
+	assert.Len(seen, totp.RECOVERY_CODES)
+}
+
+func Test_NormalizeRecoveryCode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("abcdefghij", totp.NormalizeRecoveryCode(" ABCDE-fghij "))
+}
@@ -0,0 +1,117 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc6238, with the defaults every authenticator app supports
+const (
+	PERIOD       = 30
+	DIGITS       = 6
+	SECRET_BYTES = 20
+	// a code of the previous or the next period is accepted for the clock drift of the phone
+	SKEW = 1
+)
+
+var (
+	ErrInvalidSecret = errors.New("invalid totp secret")
+
+	secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// GenerateSecret returns a random secret encoded as base32 without padding
+func GenerateSecret() (string, error) {
+	b := make([]byte, SECRET_BYTES)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "totp GenerateSecret")
+	}
+	return secretEncoding.EncodeToString(b), nil
+}
+
+// URI is the otpauth uri shown as a qr code to the authenticator app
+func URI(issuer, accountName, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(DIGITS))
+	q.Set("period", fmt.Sprint(PERIOD))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step is the number of periods since the unix epoch
+func Step(t time.Time) int64 {
+	return t.Unix() / PERIOD
+}
+
+// Code returns the code of the period of t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, Step(t)), nil
+}
+
+// Validate returns the step of the matching code, codes of lastUsedStep or before are refused
+// so that a code can not be replayed
+func Validate(code, secret string, t time.Time, lastUsedStep int64) (int64, bool, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(code) != DIGITS {
+		return 0, false, nil
+	}
+
+	current := Step(t)
+	for step := current - SKEW; step <= current+SKEW; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+// https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < DIGITS; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", DIGITS, value%mod)
+}
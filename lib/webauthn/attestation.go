@@ -0,0 +1,133 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ATTESTATION_FORMAT_NONE   = "none"
+	ATTESTATION_FORMAT_PACKED = "packed"
+)
+
+var (
+	ErrUnsupportedAttestation = errors.New("unsupported attestation format")
+	ErrInvalidAttestation     = errors.New("invalid attestation statement")
+
+	// id-fido-gen-ce-aaguid
+	oidFIDOAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+)
+
+type attestationObject struct {
+	format   string
+	attStmt  map[any]any
+	authData *authenticatorData
+}
+
+func parseAttestationObject(b []byte) (*attestationObject, error) {
+	v, n, err := decodeCBOR(b)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[any]any)
+	if !ok || n != len(b) {
+		return nil, errors.Wrap(ErrInvalidCBOR, "attestation object")
+	}
+
+	format, _ := m["fmt"].(string)
+	attStmt, _ := m["attStmt"].(map[any]any)
+	rawAuthData, _ := m["authData"].([]byte)
+	if format == "" || attStmt == nil || rawAuthData == nil {
+		return nil, errors.Wrap(ErrInvalidCBOR, "attestation object fields")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attestationObject{format, attStmt, authData}, nil
+}
+
+// verify checks the attestation statement, the certificates of a packed statement are not chained
+// to a metadata service, they only tell which model of authenticator made the credential
+func (ao *attestationObject) verify(clientDataHash []byte, credentialKey *publicKey) error {
+	switch ao.format {
+	case ATTESTATION_FORMAT_NONE:
+		if len(ao.attStmt) != 0 {
+			return errors.Wrap(ErrInvalidAttestation, "none with a statement")
+		}
+		return nil
+	case ATTESTATION_FORMAT_PACKED:
+		return ao.verifyPacked(clientDataHash, credentialKey)
+	}
+	return ErrUnsupportedAttestation
+}
+
+// https://www.w3.org/TR/webauthn-2/#sctn-packed-attestation
+func (ao *attestationObject) verifyPacked(clientDataHash []byte, credentialKey *publicKey) error {
+	alg, _ := ao.attStmt["alg"].(int64)
+	sig, _ := ao.attStmt["sig"].([]byte)
+	if sig == nil {
+		return errors.Wrap(ErrInvalidAttestation, "packed without sig")
+	}
+	signed := append(append([]byte{}, ao.authData.raw...), clientDataHash...)
+
+	x5c, ok := ao.attStmt["x5c"].([]any)
+	if !ok {
+		// self attestation, signed by the credential itself
+		if alg != credentialKey.alg {
+			return errors.Wrap(ErrInvalidAttestation, "self attestation algorithm")
+		}
+		return credentialKey.verify(signed, sig)
+	}
+
+	if len(x5c) == 0 {
+		return errors.Wrap(ErrInvalidAttestation, "empty x5c")
+	}
+	der, _ := x5c[0].([]byte)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return errors.Wrap(ErrInvalidAttestation, "attestation certificate")
+	}
+	if alg == 0 || certificateAlgorithm(cert) != alg {
+		return errors.Wrap(ErrInvalidAttestation, "attestation certificate algorithm")
+	}
+	if err := verifySignature(alg, cert.PublicKey, signed, sig); err != nil {
+		return err
+	}
+
+	// https://www.w3.org/TR/webauthn-2/#sctn-packed-attestation-cert-requirements
+	if cert.Version != 3 || cert.IsCA || !contains(cert.Subject.OrganizationalUnit, "Authenticator Attestation") {
+		return errors.Wrap(ErrInvalidAttestation, "attestation certificate requirements")
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOAAGUID) {
+			continue
+		}
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(ext.Value, &aaguid); err != nil || !bytes.Equal(aaguid, ao.authData.aaguid) {
+			return errors.Wrap(ErrInvalidAttestation, "attestation certificate aaguid")
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func hashClientData(clientDataJSON []byte) []byte {
+	sum := sha256.Sum256(clientDataJSON)
+	return sum[:]
+}
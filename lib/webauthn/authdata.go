@@ -0,0 +1,87 @@
+package webauthn
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+const (
+	FLAG_USER_PRESENT        = 0x01
+	FLAG_USER_VERIFIED       = 0x04
+	FLAG_BACKUP_ELIGIBLE     = 0x08
+	FLAG_BACKED_UP           = 0x10
+	FLAG_ATTESTED_CREDENTIAL = 0x40
+	FLAG_EXTENSION_DATA      = 0x80
+
+	authDataMinLength = 37
+	aaguidLength      = 16
+)
+
+var (
+	ErrInvalidAuthData = errors.New("invalid authenticator data")
+)
+
+type authenticatorData struct {
+	raw          []byte
+	rpIdHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialId []byte
+	publicKey    []byte
+}
+
+func (ad *authenticatorData) has(flag byte) bool {
+	return ad.flags&flag == flag
+}
+
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < authDataMinLength {
+		return nil, errors.Wrap(ErrInvalidAuthData, "too short")
+	}
+
+	ad := &authenticatorData{
+		raw:       b,
+		rpIdHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[authDataMinLength:]
+
+	if ad.has(FLAG_ATTESTED_CREDENTIAL) {
+		if len(rest) < aaguidLength+2 {
+			return nil, errors.Wrap(ErrInvalidAuthData, "attested credential data too short")
+		}
+		ad.aaguid = rest[:aaguidLength]
+		n := int(binary.BigEndian.Uint16(rest[aaguidLength : aaguidLength+2]))
+		rest = rest[aaguidLength+2:]
+		if n == 0 || n > 1023 || len(rest) < n {
+			return nil, errors.Wrap(ErrInvalidAuthData, "credential id length")
+		}
+		ad.credentialId = rest[:n]
+		rest = rest[n:]
+
+		_, used, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Wrap(err, "credential public key")
+		}
+		ad.publicKey = rest[:used]
+		rest = rest[used:]
+	}
+
+	if ad.has(FLAG_EXTENSION_DATA) {
+		_, used, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Wrap(err, "extensions")
+		}
+		rest = rest[used:]
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.Wrap(ErrInvalidAuthData, "trailing bytes")
+	}
+
+	return ad, nil
+}
@@ -0,0 +1,199 @@
+package webauthn
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	cborMaxDepth = 16
+)
+
+var (
+	ErrInvalidCBOR = errors.New("invalid cbor")
+)
+
+// decodeCBOR decodes the first item of b and returns the number of bytes it used.
+// It covers what the authenticators send (https://www.w3.org/TR/webauthn-2/#sctn-conforming-all-classes):
+// integers are int64, byte strings []byte, maps map[any]any with int64 or string keys,
+// indefinite lengths are refused.
+func decodeCBOR(b []byte) (any, int, error) {
+	d := &cborDecoder{b: b}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, d.off, nil
+}
+
+type cborDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, errors.Wrap(ErrInvalidCBOR, "too deep")
+	}
+
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errors.Wrap(ErrInvalidCBOR, "integer overflow")
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errors.Wrap(ErrInvalidCBOR, "integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case 3:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		// every item takes at least a byte
+		if arg > uint64(len(d.b)-d.off) {
+			return nil, errors.Wrap(ErrInvalidCBOR, "array length")
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.b)-d.off)/2 {
+			return nil, errors.Wrap(ErrInvalidCBOR, "map length")
+		}
+		m := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, errors.Wrap(ErrInvalidCBOR, "map key type")
+			}
+			if _, ok := m[k]; ok {
+				return nil, errors.Wrap(ErrInvalidCBOR, "duplicate map key")
+			}
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case 6:
+		// tags only annotate the item
+		return d.decode(depth + 1)
+	default:
+		return d.simple(info, arg)
+	}
+}
+
+// head reads the major type, the additional information and the argument
+func (d *cborDecoder) head() (byte, byte, uint64, error) {
+	if d.off >= len(d.b) {
+		return 0, 0, 0, errors.Wrap(ErrInvalidCBOR, "unexpected end")
+	}
+	ib := d.b[d.off]
+	d.off++
+	major, info := ib>>5, ib&0x1f
+
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, 0, 0, errors.Wrap(ErrInvalidCBOR, "indefinite or reserved length")
+	}
+
+	if len(d.b)-d.off < n {
+		return 0, 0, 0, errors.Wrap(ErrInvalidCBOR, "unexpected end")
+	}
+	var arg uint64
+	for _, c := range d.b[d.off : d.off+n] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.off += n
+
+	return major, info, arg, nil
+}
+
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, errors.Wrap(ErrInvalidCBOR, "unexpected end")
+	}
+	b := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+func (d *cborDecoder) simple(info byte, arg uint64) (any, error) {
+	switch info {
+	case 25:
+		return float64(halfToFloat32(uint16(arg))), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	}
+
+	switch arg {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	}
+	return nil, errors.Wrap(ErrInvalidCBOR, "unsupported simple value")
+}
+
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+
+	switch {
+	case exp == 0:
+		f := float32(frac) / 1024 * float32(math.Pow(2, -14))
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+	}
+}
@@ -0,0 +1,143 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	COSE_ALG_ES256 = -7
+	COSE_ALG_EDDSA = -8
+	COSE_ALG_RS256 = -257
+
+	coseKty        = 1
+	coseAlg        = 3
+	coseCrv        = -1
+	coseX          = -2
+	coseY          = -3
+	coseRSAN       = -1
+	coseRSAE       = -2
+	coseKtyOKP     = 1
+	coseKtyEC2     = 2
+	coseKtyRSA     = 3
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+var (
+	ErrUnsupportedKey   = errors.New("unsupported credential public key")
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// in the order of preference of the creation options
+	SupportedAlgorithms = []int64{COSE_ALG_ES256, COSE_ALG_EDDSA, COSE_ALG_RS256}
+)
+
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey parses a COSE_Key, https://www.w3.org/TR/webauthn-2/#sctn-encoded-credPubKey-examples
+func parsePublicKey(cose []byte) (*publicKey, error) {
+	v, n, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(cose) {
+		return nil, errors.Wrap(ErrInvalidCBOR, "trailing bytes after the public key")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == COSE_ALG_ES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		k := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !k.Curve.IsOnCurve(k.X, k.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg, k}, nil
+	case kty == coseKtyOKP && alg == COSE_ALG_EDDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg, ed25519.PublicKey(x)}, nil
+	case kty == coseKtyRSA && alg == COSE_ALG_RS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedKey
+		}
+		return &publicKey{alg, &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+func (k *publicKey) verify(data, sig []byte) error {
+	return verifySignature(k.alg, k.key, data, sig)
+}
+
+func verifySignature(alg int64, key crypto.PublicKey, data, sig []byte) error {
+	switch alg {
+	case COSE_ALG_ES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		sum := sha256.Sum256(data)
+		if ok && ecdsa.VerifyASN1(k, sum[:], sig) {
+			return nil
+		}
+	case COSE_ALG_EDDSA:
+		k, ok := key.(ed25519.PublicKey)
+		if ok && ed25519.Verify(k, data, sig) {
+			return nil
+		}
+	case COSE_ALG_RS256:
+		k, ok := key.(*rsa.PublicKey)
+		sum := sha256.Sum256(data)
+		if ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// certificateAlgorithm tells the COSE algorithm a certificate key can verify
+func certificateAlgorithm(c *x509.Certificate) int64 {
+	switch c.PublicKeyAlgorithm {
+	case x509.ECDSA:
+		return COSE_ALG_ES256
+	case x509.Ed25519:
+		return COSE_ALG_EDDSA
+	case x509.RSA:
+		return COSE_ALG_RS256
+	}
+	return 0
+}
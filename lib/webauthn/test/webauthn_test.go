@@ -0,0 +1,415 @@
+package webauthn_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rlawnsxo131/madre-server-v3/lib/webauthn"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func newRelyingParty() *webauthn.RelyingParty {
+	return webauthn.NewRelyingParty(webauthn.Config{
+		RPID:    testRPID,
+		RPName:  "example",
+		Origins: []string{testOrigin},
+	})
+}
+
+// authenticator is a software passkey with a P-256 key
+type authenticator struct {
+	key       *ecdsa.PrivateKey
+	selfKey   *ecdsa.PrivateKey
+	counting  bool
+	id        []byte
+	aaguid    []byte
+	signCount uint32
+	flags     byte
+	origin    string
+	rpId      string
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &authenticator{
+		key:      key,
+		selfKey:  key,
+		counting: true,
+		id:       id,
+		aaguid:   make([]byte, 16),
+		flags:    webauthn.FLAG_USER_PRESENT | webauthn.FLAG_USER_VERIFIED,
+		origin:   testOrigin,
+		rpId:     testRPID,
+	}
+}
+
+func (a *authenticator) coseKey() []byte {
+	return cbor(map[int64]any{
+		1:  int64(2),
+		3:  int64(webauthn.COSE_ALG_ES256),
+		-1: int64(1),
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+}
+
+func (a *authenticator) authData(attested bool) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+	b := append([]byte{}, rpIdHash[:]...)
+	flags := a.flags
+	if attested {
+		flags |= webauthn.FLAG_ATTESTED_CREDENTIAL
+	}
+	b = append(b, flags)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], a.signCount)
+	if attested {
+		b = append(b, a.aaguid...)
+		b = append(b, byte(len(a.id)>>8), byte(len(a.id)))
+		b = append(b, a.id...)
+		b = append(b, a.coseKey()...)
+	}
+	return b
+}
+
+func (a *authenticator) clientData(typ, challenge string) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+	return b
+}
+
+func (a *authenticator) sign(t *testing.T, key *ecdsa.PrivateKey, authData, clientData []byte) []byte {
+	sum := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), sum[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	assert.Nil(t, err)
+	return sig
+}
+
+func (a *authenticator) create(t *testing.T, challenge, format string, x5c []byte, x5cKey *ecdsa.PrivateKey) *webauthn.RegistrationResponse {
+	authData := a.authData(true)
+	clientData := a.clientData("webauthn.create", challenge)
+
+	attStmt := map[string]any{}
+	switch {
+	case format == "packed" && x5c != nil:
+		attStmt["alg"] = int64(webauthn.COSE_ALG_ES256)
+		attStmt["sig"] = a.sign(t, x5cKey, authData, clientData)
+		attStmt["x5c"] = []any{x5c}
+	case format == "packed":
+		attStmt["alg"] = int64(webauthn.COSE_ALG_ES256)
+		attStmt["sig"] = a.sign(t, a.selfKey, authData, clientData)
+	}
+
+	res := &webauthn.RegistrationResponse{
+		ID:    webauthn.EncodeBase64URL(a.id),
+		RawID: webauthn.EncodeBase64URL(a.id),
+		Type:  "public-key",
+	}
+	res.Response.ClientDataJSON = webauthn.EncodeBase64URL(clientData)
+	res.Response.AttestationObject = webauthn.EncodeBase64URL(cbor(map[string]any{
+		"fmt":      format,
+		"attStmt":  attStmt,
+		"authData": authData,
+	}))
+	return res
+}
+
+func (a *authenticator) get(t *testing.T, challenge, userHandle string) *webauthn.AssertionResponse {
+	if a.counting {
+		a.signCount++
+	}
+	authData := a.authData(false)
+	clientData := a.clientData("webauthn.get", challenge)
+
+	res := &webauthn.AssertionResponse{
+		ID:    webauthn.EncodeBase64URL(a.id),
+		RawID: webauthn.EncodeBase64URL(a.id),
+		Type:  "public-key",
+	}
+	res.Response.ClientDataJSON = webauthn.EncodeBase64URL(clientData)
+	res.Response.AuthenticatorData = webauthn.EncodeBase64URL(authData)
+	res.Response.Signature = webauthn.EncodeBase64URL(a.sign(t, a.key, authData, clientData))
+	res.Response.UserHandle = webauthn.EncodeBase64URL([]byte(userHandle))
+	return res
+}
+
+// cbor encodes the few types the authenticator needs, map keys are sorted canonically
+func cbor(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n <= 0xff:
+			return []byte{major<<5 | 24, byte(n)}
+		case n <= 0xffff:
+			return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+		}
+		return []byte{major<<5 | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	entries := func(keys [][]byte, values [][]byte) []byte {
+		idx := make([]int, len(keys))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.Slice(idx, func(i, j int) bool {
+			a, b := keys[idx[i]], keys[idx[j]]
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return string(a) < string(b)
+		})
+		b := head(5, uint64(len(keys)))
+		for _, i := range idx {
+			b = append(b, keys[i]...)
+			b = append(b, values[i]...)
+		}
+		return b
+	}
+
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case []any:
+		b := head(4, uint64(len(v)))
+		for _, e := range v {
+			b = append(b, cbor(e)...)
+		}
+		return b
+	case map[int64]any:
+		keys, values := [][]byte{}, [][]byte{}
+		for k, e := range v {
+			keys, values = append(keys, cbor(k)), append(values, cbor(e))
+		}
+		return entries(keys, values)
+	case map[string]any:
+		keys, values := [][]byte{}, [][]byte{}
+		for k, e := range v {
+			keys, values = append(keys, cbor(k)), append(values, cbor(e))
+		}
+		return entries(keys, values)
+	}
+	panic("cbor: unsupported type")
+}
+
+func register(t *testing.T, rp *webauthn.RelyingParty, a *authenticator) *webauthn.Credential {
+	challenge, err := webauthn.NewChallenge()
+	assert.Nil(t, err)
+	c, err := rp.FinishRegistration(challenge, a.create(t, challenge, "none", nil, nil))
+	assert.Nil(t, err)
+	return c
+}
+
+func Test_Registration_None(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	a := newAuthenticator(t)
+
+	challenge, _ := webauthn.NewChallenge()
+	options := rp.BeginRegistration(challenge, webauthn.User{ID: "user-id", Name: "madre"}, nil)
+	assert.Equal(testRPID, options.RP.ID)
+	assert.Equal("required", options.AuthenticatorSelection.UserVerification)
+	assert.Equal(webauthn.EncodeBase64URL([]byte("user-id")), options.User.ID)
+
+	res := a.create(t, challenge, "none", nil, nil)
+	answered, err := res.Challenge()
+	assert.Nil(err)
+	assert.Equal(challenge, answered)
+
+	c, err := rp.FinishRegistration(challenge, res)
+	assert.Nil(err)
+	assert.Equal(webauthn.EncodeBase64URL(a.id), c.ID)
+	assert.Equal("none", c.Format)
+	assert.Equal(uint32(0), c.SignCount)
+}
+
+func Test_Registration_PackedSelf(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	a := newAuthenticator(t)
+
+	challenge, _ := webauthn.NewChallenge()
+	c, err := rp.FinishRegistration(challenge, a.create(t, challenge, "packed", nil, nil))
+	assert.Nil(err)
+	assert.Equal("packed", c.Format)
+
+	// signed by another key than the credential
+	a.selfKey = newAuthenticator(t).key
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "packed", nil, nil))
+	assert.ErrorIs(err, webauthn.ErrInvalidSignature)
+}
+
+func attestationCertificate(t *testing.T, aaguid []byte, ou string) ([]byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	ext, _ := asn1.Marshal(aaguid)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"KR"},
+			Organization:       []string{"madre"},
+			OrganizationalUnit: []string{ou},
+			CommonName:         "madre test authenticator",
+		},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}, Value: ext},
+		},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	return der, key
+}
+
+func Test_Registration_PackedX5C(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	a := newAuthenticator(t)
+	a.aaguid = []byte("madre-test-aagui")
+
+	challenge, _ := webauthn.NewChallenge()
+	der, key := attestationCertificate(t, a.aaguid, "Authenticator Attestation")
+	c, err := rp.FinishRegistration(challenge, a.create(t, challenge, "packed", der, key))
+	assert.Nil(err)
+	assert.Equal(a.aaguid, c.AAGUID)
+
+	der, key = attestationCertificate(t, a.aaguid, "Somewhere Else")
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "packed", der, key))
+	assert.ErrorIs(err, webauthn.ErrInvalidAttestation)
+
+	der, key = attestationCertificate(t, []byte("another-aaguid00"), "Authenticator Attestation")
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "packed", der, key))
+	assert.ErrorIs(err, webauthn.ErrInvalidAttestation)
+
+	// signed by another key than the certificate
+	der, _ = attestationCertificate(t, a.aaguid, "Authenticator Attestation")
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "packed", der, key))
+	assert.ErrorIs(err, webauthn.ErrInvalidSignature)
+}
+
+func Test_Registration_Rejects(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	challenge, _ := webauthn.NewChallenge()
+
+	a := newAuthenticator(t)
+	other, _ := webauthn.NewChallenge()
+	_, err := rp.FinishRegistration(challenge, a.create(t, other, "none", nil, nil))
+	assert.ErrorIs(err, webauthn.ErrChallengeMismatch)
+
+	a.origin = "https://evil.example"
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "none", nil, nil))
+	assert.ErrorIs(err, webauthn.ErrOriginMismatch)
+
+	a = newAuthenticator(t)
+	a.rpId = "evil.example"
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "none", nil, nil))
+	assert.ErrorIs(err, webauthn.ErrRPIDMismatch)
+
+	a = newAuthenticator(t)
+	a.flags = webauthn.FLAG_USER_PRESENT
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "none", nil, nil))
+	assert.ErrorIs(err, webauthn.ErrUserNotVerified)
+
+	a = newAuthenticator(t)
+	_, err = rp.FinishRegistration(challenge, a.create(t, challenge, "fido-u2f", nil, nil))
+	assert.ErrorIs(err, webauthn.ErrUnsupportedAttestation)
+}
+
+func Test_Login(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	a := newAuthenticator(t)
+	c := register(t, rp, a)
+
+	challenge, _ := webauthn.NewChallenge()
+	options := rp.BeginLogin(challenge, nil)
+	assert.Equal(testRPID, options.RPID)
+	assert.Empty(options.AllowCredentials)
+
+	as, err := rp.FinishLogin(challenge, a.get(t, challenge, "user-id"), c)
+	assert.Nil(err)
+	assert.Equal("user-id", as.UserHandle)
+	assert.Equal(uint32(1), as.SignCount)
+
+	c.SignCount = as.SignCount
+	_, err = rp.FinishLogin(challenge, a.get(t, challenge, "user-id"), c)
+	assert.Nil(err)
+}
+
+func Test_Login_SignCount(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	a := newAuthenticator(t)
+	c := register(t, rp, a)
+	challenge, _ := webauthn.NewChallenge()
+
+	// a cloned authenticator replays a counter the server has already seen
+	c.SignCount = 5
+	a.signCount = 4
+	_, err := rp.FinishLogin(challenge, a.get(t, challenge, "user-id"), c)
+	assert.ErrorIs(err, webauthn.ErrSignCount)
+
+	// authenticators without a counter always send 0
+	c.SignCount = 0
+	a.signCount = 0
+	a.counting = false
+	_, err = rp.FinishLogin(challenge, a.get(t, challenge, "user-id"), c)
+	assert.Nil(err)
+}
+
+func Test_Login_Rejects(t *testing.T) {
+	assert := assert.New(t)
+	rp := newRelyingParty()
+	a := newAuthenticator(t)
+	c := register(t, rp, a)
+	challenge, _ := webauthn.NewChallenge()
+
+	res := a.get(t, challenge, "user-id")
+	res.Response.Signature = webauthn.EncodeBase64URL([]byte("not a signature"))
+	_, err := rp.FinishLogin(challenge, res, c)
+	assert.ErrorIs(err, webauthn.ErrInvalidSignature)
+
+	other, _ := webauthn.NewChallenge()
+	_, err = rp.FinishLogin(challenge, a.get(t, other, "user-id"), c)
+	assert.ErrorIs(err, webauthn.ErrChallengeMismatch)
+
+	// the credential of another authenticator
+	_, err = rp.FinishLogin(challenge, newAuthenticator(t).get(t, challenge, "user-id"), c)
+	assert.ErrorIs(err, webauthn.ErrInvalidResponse)
+
+	a.flags = webauthn.FLAG_USER_PRESENT
+	_, err = rp.FinishLogin(challenge, a.get(t, challenge, "user-id"), c)
+	assert.ErrorIs(err, webauthn.ErrUserNotVerified)
+}
@@ -0,0 +1,402 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rlawnsxo131/madre-server-v3/lib/env"
+)
+
+const (
+	CEREMONY_REGISTRATION   = "registration"
+	CEREMONY_AUTHENTICATION = "authentication"
+
+	CHALLENGE_LENGTH = 32
+	CEREMONY_TIMEOUT = time.Minute * 5
+
+	CREDENTIAL_TYPE_PUBLIC_KEY = "public-key"
+
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+)
+
+var (
+	ErrInvalidResponse   = errors.New("invalid webauthn response")
+	ErrChallengeMismatch = errors.New("webauthn challenge mismatch")
+	ErrOriginMismatch    = errors.New("webauthn origin mismatch")
+	ErrRPIDMismatch      = errors.New("webauthn rp id mismatch")
+	ErrUserNotVerified   = errors.New("webauthn user not verified")
+	ErrSignCount         = errors.New("webauthn sign count did not increase")
+
+	defaultRelyingParty     *RelyingParty
+	onceDefaultRelyingParty sync.Once
+)
+
+type Config struct {
+	RPID    string
+	RPName  string
+	Origins []string
+	// Attestation is the conveyance preference, "none" or "direct"
+	Attestation string
+}
+
+type RelyingParty struct {
+	config   Config
+	rpIdHash []byte
+}
+
+func NewRelyingParty(config Config) *RelyingParty {
+	sum := sha256.Sum256([]byte(config.RPID))
+	return &RelyingParty{config, sum[:]}
+}
+
+// DefaultRelyingParty is configured by WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME, WEBAUTHN_ORIGINS and WEBAUTHN_ATTESTATION
+func DefaultRelyingParty() *RelyingParty {
+	onceDefaultRelyingParty.Do(func() {
+		defaultRelyingParty = NewRelyingParty(Config{
+			RPID:        env.WebAuthnRPID(),
+			RPName:      env.WebAuthnRPName(),
+			Origins:     env.WebAuthnOrigins(),
+			Attestation: env.WebAuthnAttestation(),
+		})
+	})
+	return defaultRelyingParty
+}
+
+// User is the account a credential is created for, ID is the user handle returned by discoverable credentials
+type User struct {
+	ID          string
+	Name        string
+	DisplayName string
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     CreationOptionsRP      `json:"rp"`
+	User                   CreationOptionsUser    `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	Attestation            string                 `json:"attestation"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+}
+
+type CreationOptionsRP struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CreationOptionsUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	UserVerification string                 `json:"userVerification"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+}
+
+// RegistrationResponse is the PublicKeyCredential returned by navigator.credentials.create, binary fields are base64url
+type RegistrationResponse struct {
+	ID       string `json:"id" validate:"required"`
+	RawID    string `json:"rawId" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+		AttestationObject string `json:"attestationObject" validate:"required"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the PublicKeyCredential returned by navigator.credentials.get, binary fields are base64url
+type AssertionResponse struct {
+	ID       string `json:"id" validate:"required"`
+	RawID    string `json:"rawId" validate:"required"`
+	Type     string `json:"type" validate:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" validate:"required"`
+		AuthenticatorData string `json:"authenticatorData" validate:"required"`
+		Signature         string `json:"signature" validate:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is what has to be stored to verify the later assertions
+type Credential struct {
+	ID        string
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+	Format    string
+}
+
+// Assertion is the verified result of an authentication ceremony
+type Assertion struct {
+	CredentialID string
+	UserHandle   string
+	SignCount    uint32
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// NewChallenge returns a random base64url challenge
+func NewChallenge() (string, error) {
+	b := make([]byte, CHALLENGE_LENGTH)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "NewChallenge")
+	}
+	return EncodeBase64URL(b), nil
+}
+
+func (rp *RelyingParty) BeginRegistration(challenge string, u User, exclude []string) *CreationOptions {
+	params := []CredentialParameter{}
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{CREDENTIAL_TYPE_PUBLIC_KEY, alg})
+	}
+	attestation := ATTESTATION_FORMAT_NONE
+	if rp.config.Attestation == "direct" {
+		attestation = "direct"
+	}
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        CreationOptionsRP{rp.config.RPID, rp.config.RPName},
+		User: CreationOptionsUser{
+			ID:          EncodeBase64URL([]byte(u.ID)),
+			Name:        u.Name,
+			DisplayName: u.DisplayName,
+		},
+		PubKeyCredParams: params,
+		Timeout:          CEREMONY_TIMEOUT.Milliseconds(),
+		Attestation:      attestation,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		ExcludeCredentials: descriptors(exclude),
+	}
+}
+
+// FinishRegistration verifies the response to the challenge, https://www.w3.org/TR/webauthn-2/#sctn-registering-a-new-credential
+func (rp *RelyingParty) FinishRegistration(challenge string, res *RegistrationResponse) (*Credential, error) {
+	if res.Type != CREDENTIAL_TYPE_PUBLIC_KEY {
+		return nil, errors.Wrap(ErrInvalidResponse, "credential type")
+	}
+	rawClientData, err := DecodeBase64URL(res.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "clientDataJSON")
+	}
+	if err := rp.verifyClientData(rawClientData, clientDataTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+	rawAttestation, err := DecodeBase64URL(res.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "attestationObject")
+	}
+	ao, err := parseAttestationObject(rawAttestation)
+	if err != nil {
+		return nil, err
+	}
+
+	ad := ao.authData
+	if err := rp.verifyAuthData(ad); err != nil {
+		return nil, err
+	}
+	if !ad.has(FLAG_ATTESTED_CREDENTIAL) {
+		return nil, errors.Wrap(ErrInvalidAuthData, "no attested credential data")
+	}
+	rawId, err := DecodeBase64URL(res.RawID)
+	if err != nil || !bytes.Equal(rawId, ad.credentialId) {
+		return nil, errors.Wrap(ErrInvalidResponse, "rawId")
+	}
+
+	key, err := parsePublicKey(ad.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := ao.verify(hashClientData(rawClientData), key); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        EncodeBase64URL(ad.credentialId),
+		PublicKey: ad.publicKey,
+		SignCount: ad.signCount,
+		AAGUID:    ad.aaguid,
+		Format:    ao.format,
+	}, nil
+}
+
+// BeginLogin leaves allowCredentials empty unless given, so that discoverable credentials are offered
+func (rp *RelyingParty) BeginLogin(challenge string, allow []string) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.config.RPID,
+		Timeout:          CEREMONY_TIMEOUT.Milliseconds(),
+		UserVerification: "required",
+		AllowCredentials: descriptors(allow),
+	}
+}
+
+// FinishLogin verifies the assertion with the stored credential, https://www.w3.org/TR/webauthn-2/#sctn-verifying-assertion
+func (rp *RelyingParty) FinishLogin(challenge string, res *AssertionResponse, c *Credential) (*Assertion, error) {
+	if res.Type != CREDENTIAL_TYPE_PUBLIC_KEY {
+		return nil, errors.Wrap(ErrInvalidResponse, "credential type")
+	}
+	rawId, err := DecodeBase64URL(res.RawID)
+	if err != nil || EncodeBase64URL(rawId) != c.ID {
+		return nil, errors.Wrap(ErrInvalidResponse, "rawId")
+	}
+	rawClientData, err := DecodeBase64URL(res.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "clientDataJSON")
+	}
+	if err := rp.verifyClientData(rawClientData, clientDataTypeGet, challenge); err != nil {
+		return nil, err
+	}
+	rawAuthData, err := DecodeBase64URL(res.Response.AuthenticatorData)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "authenticatorData")
+	}
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthData(ad); err != nil {
+		return nil, err
+	}
+	sig, err := DecodeBase64URL(res.Response.Signature)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "signature")
+	}
+
+	key, err := parsePublicKey(c.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := key.verify(append(rawAuthData, hashClientData(rawClientData)...), sig); err != nil {
+		return nil, err
+	}
+
+	// a counter that does not move forward tells the credential may have been cloned,
+	// authenticators that do not count always return 0
+	if (ad.signCount != 0 || c.SignCount != 0) && ad.signCount <= c.SignCount {
+		return nil, ErrSignCount
+	}
+
+	userHandle := ""
+	if res.Response.UserHandle != "" {
+		b, err := DecodeBase64URL(res.Response.UserHandle)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidResponse, "userHandle")
+		}
+		userHandle = string(b)
+	}
+
+	return &Assertion{
+		CredentialID: c.ID,
+		UserHandle:   userHandle,
+		SignCount:    ad.signCount,
+	}, nil
+}
+
+// Challenge reads the challenge the authenticator answered, so that the stored one can be looked up.
+// It is not verified yet, FinishRegistration does it.
+func (res *RegistrationResponse) Challenge() (string, error) {
+	return responseChallenge(res.Response.ClientDataJSON)
+}
+
+// Challenge reads the challenge the authenticator answered, so that the stored one can be looked up.
+// It is not verified yet, FinishLogin does it.
+func (res *AssertionResponse) Challenge() (string, error) {
+	return responseChallenge(res.Response.ClientDataJSON)
+}
+
+func responseChallenge(clientDataJSON string) (string, error) {
+	raw, err := DecodeBase64URL(clientDataJSON)
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidResponse, "clientDataJSON")
+	}
+	cd := clientData{}
+	if err := json.Unmarshal(raw, &cd); err != nil || cd.Challenge == "" {
+		return "", errors.Wrap(ErrInvalidResponse, "clientDataJSON")
+	}
+	return cd.Challenge, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, typ, challenge string) error {
+	cd := clientData{}
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return errors.Wrap(ErrInvalidResponse, "clientDataJSON")
+	}
+	if cd.Type != typ {
+		return errors.Wrap(ErrInvalidResponse, "clientData type")
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	for _, o := range rp.config.Origins {
+		if cd.Origin == o {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+func (rp *RelyingParty) verifyAuthData(ad *authenticatorData) error {
+	if !bytes.Equal(ad.rpIdHash, rp.rpIdHash) {
+		return ErrRPIDMismatch
+	}
+	if !ad.has(FLAG_USER_PRESENT) || !ad.has(FLAG_USER_VERIFIED) {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+func descriptors(ids []string) []CredentialDescriptor {
+	list := []CredentialDescriptor{}
+	for _, id := range ids {
+		list = append(list, CredentialDescriptor{CREDENTIAL_TYPE_PUBLIC_KEY, id})
+	}
+	return list
+}
+
+func EncodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBase64URL accepts padded and unpadded values, browsers differ
+func DecodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}